@@ -0,0 +1,317 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// WebDriver BiDi is the bidirectional protocol of W3C drivers. A driver
+// opens a BiDi WebSocket for a session when the session is created with the
+// capability "webSocketUrl" set to true, which NewSession requests if BiDi
+// is set on the driver:
+//
+//	driver.BiDi = true
+//	session, err := driver.NewSession(nil, nil)
+//	bidi, err := session.DialBiDi()
+//	bidi.OnLogEntry(func(e webdriver.BiDiLogEntry) { log.Println(e.Text) })
+//	err = bidi.Subscribe("log.entryAdded")
+
+// BiDiConn is a WebDriver BiDi connection. It is safe for concurrent use.
+type BiDiConn struct {
+	rpc *rpcConn
+}
+
+// BiDiError is an error returned by a BiDi command.
+type BiDiError struct {
+	ErrorCode  string `json:"error"`
+	Message    string `json:"message"`
+	Stacktrace string `json:"stacktrace"`
+}
+
+func (e *BiDiError) Error() string {
+	if e.Message == "" {
+		return "bidi: " + e.ErrorCode
+	}
+	return "bidi: " + e.ErrorCode + ": " + e.Message
+}
+
+// BiDiEvent is an event as delivered to channels.
+type BiDiEvent struct {
+	Method string
+	Params json.RawMessage
+}
+
+// DialBiDi opens the BiDi connection of the session, at the URL returned by
+// the driver in the webSocketUrl capability.
+func (s *Session) DialBiDi() (*BiDiConn, error) {
+	url, _ := s.capability("webSocketUrl").(string)
+	if url == "" {
+		return nil, errors.New("bidi: session has no webSocketUrl, set BiDi on the driver or the capability webSocketUrl: true")
+	}
+	return DialBiDiURL(url)
+}
+
+// DialBiDiURL opens a BiDi connection to url.
+func DialBiDiURL(url string) (*BiDiConn, error) {
+	ws, err := dialWebSocket(url)
+	if err != nil {
+		return nil, err
+	}
+	return &BiDiConn{newRPCConn(ws, bidiResponseError)}, nil
+}
+
+func bidiResponseError(m *rpcMessage) error {
+	if m.Type != "error" {
+		return nil
+	}
+	e := &BiDiError{Message: m.Message}
+	if err := json.Unmarshal(m.Error, &e.ErrorCode); err != nil {
+		e.ErrorCode = string(m.Error)
+	}
+	return e
+}
+
+// Send the command method with params and decode its result into result, which may be nil.
+// The response is waited for up to a minute, see CallContext.
+func (b *BiDiConn) Call(method string, params, result interface{}) error {
+	return b.rpc.call(method, params, result)
+}
+
+// CallContext is Call waiting for the response until ctx is done.
+func (b *BiDiConn) CallContext(ctx context.Context, method string, params, result interface{}) error {
+	return b.rpc.callContext(ctx, method, params, result)
+}
+
+// Subscribe asks the browser to send the given events, e.g. "log.entryAdded",
+// or all the events of a module, e.g. "network".
+func (b *BiDiConn) Subscribe(events ...string) error {
+	return b.Call("session.subscribe", map[string]interface{}{"events": events}, nil)
+}
+
+// Unsubscribe stops the given events.
+func (b *BiDiConn) Unsubscribe(events ...string) error {
+	return b.Call("session.unsubscribe", map[string]interface{}{"events": events}, nil)
+}
+
+// On registers handler for the event named method. Handlers are called one
+// at a time, in the order the events arrive, and may send commands. The
+// returned function unregisters the handler.
+func (b *BiDiConn) On(method string, handler func(params json.RawMessage)) func() {
+	return b.rpc.on(method, handler)
+}
+
+// Events returns a channel that receives the events named method. Events are
+// not dropped: while the channel is full, the delivery of all the events of
+// the connection waits. The returned function stops the delivery; the
+// channel is not closed.
+func (b *BiDiConn) Events(method string, buffer int) (<-chan BiDiEvent, func()) {
+	ch := make(chan BiDiEvent, buffer)
+	done := make(chan struct{})
+	remove := b.On(method, func(params json.RawMessage) {
+		select {
+		case ch <- BiDiEvent{method, params}:
+		case <-done:
+		}
+	})
+	return ch, func() {
+		remove()
+		close(done)
+	}
+}
+
+// Close the connection. The session is not deleted.
+func (b *BiDiConn) Close() error {
+	return b.rpc.close()
+}
+
+// BiDiRemoteValue is a value serialized by the browser, e.g. an argument of a console call.
+type BiDiRemoteValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// BiDiLogEntry is the payload of log.entryAdded.
+type BiDiLogEntry struct {
+	Type   string `json:"type"` // "console" or "javascript"
+	Level  string `json:"level"`
+	Text   string `json:"text"`
+	Method string `json:"method"`
+	Source struct {
+		Realm   string `json:"realm"`
+		Context string `json:"context"`
+	} `json:"source"`
+	Args       []BiDiRemoteValue `json:"args"`
+	Timestamp  BiDiTime          `json:"timestamp"`
+	StackTrace *struct {
+		CallFrames []struct {
+			URL          string `json:"url"`
+			FunctionName string `json:"functionName"`
+			LineNumber   int    `json:"lineNumber"`
+			ColumnNumber int    `json:"columnNumber"`
+		} `json:"callFrames"`
+	} `json:"stackTrace"`
+}
+
+// BiDiNavigation is the payload of browsingContext.load and the other navigation events.
+type BiDiNavigation struct {
+	Context    string   `json:"context"`
+	Navigation string   `json:"navigation"`
+	URL        string   `json:"url"`
+	Timestamp  BiDiTime `json:"timestamp"`
+}
+
+// BiDiHeader is an HTTP header of a network event.
+type BiDiHeader struct {
+	Name  string `json:"name"`
+	Value struct {
+		Type  string `json:"type"` // "string" or "base64"
+		Value string `json:"value"`
+	} `json:"value"`
+}
+
+// BiDiRequest is the request of a network event.
+type BiDiRequest struct {
+	Request     string       `json:"request"`
+	URL         string       `json:"url"`
+	Method      string       `json:"method"`
+	Headers     []BiDiHeader `json:"headers"`
+	HeadersSize int          `json:"headersSize"`
+	BodySize    *int         `json:"bodySize"`
+	Timings     struct {
+		TimeOrigin    float64 `json:"timeOrigin"`
+		RequestTime   float64 `json:"requestTime"`
+		RedirectStart float64 `json:"redirectStart"`
+		RedirectEnd   float64 `json:"redirectEnd"`
+		FetchStart    float64 `json:"fetchStart"`
+		DNSStart      float64 `json:"dnsStart"`
+		DNSEnd        float64 `json:"dnsEnd"`
+		ConnectStart  float64 `json:"connectStart"`
+		ConnectEnd    float64 `json:"connectEnd"`
+		TLSStart      float64 `json:"tlsStart"`
+		RequestStart  float64 `json:"requestStart"`
+		ResponseStart float64 `json:"responseStart"`
+		ResponseEnd   float64 `json:"responseEnd"`
+	} `json:"timings"`
+}
+
+// BiDiResponse is the response of a network event.
+type BiDiResponse struct {
+	URL           string       `json:"url"`
+	Protocol      string       `json:"protocol"`
+	Status        int          `json:"status"`
+	StatusText    string       `json:"statusText"`
+	FromCache     bool         `json:"fromCache"`
+	Headers       []BiDiHeader `json:"headers"`
+	MimeType      string       `json:"mimeType"`
+	BytesReceived int          `json:"bytesReceived"`
+	HeadersSize   *int         `json:"headersSize"`
+	BodySize      *int         `json:"bodySize"`
+}
+
+// BiDiNetworkEvent is the payload of network.beforeRequestSent,
+// network.responseStarted, network.responseCompleted and network.fetchError.
+type BiDiNetworkEvent struct {
+	Context       string        `json:"context"`
+	Navigation    string        `json:"navigation"`
+	RedirectCount int           `json:"redirectCount"`
+	IsBlocked     bool          `json:"isBlocked"`
+	Intercepts    []string      `json:"intercepts"`
+	Request       BiDiRequest   `json:"request"`
+	Response      *BiDiResponse `json:"response"`
+	ErrorText     string        `json:"errorText"`
+	Timestamp     BiDiTime      `json:"timestamp"`
+}
+
+// BiDiTime is a BiDi timestamp, in milliseconds since the epoch.
+type BiDiTime float64
+
+// Time converts the timestamp.
+func (t BiDiTime) Time() time.Time {
+	ms := float64(t)
+	return time.Unix(0, int64(ms*float64(time.Millisecond)))
+}
+
+// OnLogEntry registers handler for log.entryAdded events.
+// Events are sent by the browser after Subscribe("log.entryAdded").
+func (b *BiDiConn) OnLogEntry(handler func(e BiDiLogEntry)) func() {
+	return b.On("log.entryAdded", func(params json.RawMessage) {
+		var e BiDiLogEntry
+		if json.Unmarshal(params, &e) == nil {
+			handler(e)
+		}
+	})
+}
+
+// OnLoad registers handler for browsingContext.load events.
+// Events are sent by the browser after Subscribe("browsingContext.load").
+func (b *BiDiConn) OnLoad(handler func(n BiDiNavigation)) func() {
+	return b.On("browsingContext.load", func(params json.RawMessage) {
+		var n BiDiNavigation
+		if json.Unmarshal(params, &n) == nil {
+			handler(n)
+		}
+	})
+}
+
+// OnBeforeRequestSent registers handler for network.beforeRequestSent events.
+// Events are sent by the browser after Subscribe("network.beforeRequestSent").
+func (b *BiDiConn) OnBeforeRequestSent(handler func(e BiDiNetworkEvent)) func() {
+	return b.onNetwork("network.beforeRequestSent", handler)
+}
+
+// OnResponseCompleted registers handler for network.responseCompleted events.
+// Events are sent by the browser after Subscribe("network.responseCompleted").
+func (b *BiDiConn) OnResponseCompleted(handler func(e BiDiNetworkEvent)) func() {
+	return b.onNetwork("network.responseCompleted", handler)
+}
+
+func (b *BiDiConn) onNetwork(method string, handler func(e BiDiNetworkEvent)) func() {
+	return b.On(method, func(params json.RawMessage) {
+		var e BiDiNetworkEvent
+		if json.Unmarshal(params, &e) == nil {
+			handler(e)
+		}
+	})
+}
+
+// BiDiStatus is the result of session.status.
+type BiDiStatus struct {
+	Ready   bool   `json:"ready"`
+	Message string `json:"message"`
+}
+
+// Status returns whether the remote end can create new sessions.
+func (b *BiDiConn) Status() (BiDiStatus, error) {
+	var status BiDiStatus
+	err := b.Call("session.status", nil, &status)
+	return status, err
+}
+
+// BiDiContext is a browsing context (a tab, a window or a frame).
+type BiDiContext struct {
+	Context  string        `json:"context"`
+	URL      string        `json:"url"`
+	Parent   string        `json:"parent"`
+	Children []BiDiContext `json:"children"`
+}
+
+// GetTree returns the top-level browsing contexts and their children.
+func (b *BiDiConn) GetTree() ([]BiDiContext, error) {
+	var result struct {
+		Contexts []BiDiContext `json:"contexts"`
+	}
+	err := b.Call("browsingContext.getTree", nil, &result)
+	return result.Contexts, err
+}
+
+// Navigate the browsing context to url and wait for the page to load.
+func (b *BiDiConn) Navigate(context, url string) error {
+	p := map[string]interface{}{"context": context, "url": url, "wait": "complete"}
+	return b.Call("browsingContext.navigate", p, nil)
+}
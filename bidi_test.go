@@ -0,0 +1,90 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// a BiDi stand-in: session.subscribe succeeds and emits a log entry, every other command fails.
+func bidiStandIn(c *wsConn) {
+	for {
+		data, err := c.ReadMessage()
+		if err != nil {
+			return
+		}
+		var m rpcMessage
+		json.Unmarshal(data, &m)
+		switch m.Method {
+		case "session.subscribe":
+			c.WriteMessage([]byte(`{"type":"event","method":"log.entryAdded","params":{"type":"console","level":"error","text":"boom","timestamp":1700000000000}}`))
+			c.WriteMessage([]byte(fmt.Sprintf(`{"type":"success","id":%d,"result":{}}`, m.ID)))
+		default:
+			c.WriteMessage([]byte(fmt.Sprintf(`{"type":"error","id":%d,"error":"unknown command","message":"%s"}`, m.ID, m.Method)))
+		}
+	}
+}
+
+func TestBiDi(t *testing.T) {
+	wsURL := serveWebSocket(t, bidiStandIn)
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Capabilities struct {
+				AlwaysMatch map[string]interface{}
+			}
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Capabilities.AlwaysMatch["webSocketUrl"] != true {
+			writeError(w, 400, "invalid argument")
+			return
+		}
+		writeValue(w, map[string]interface{}{
+			"sessionId":    "s1",
+			"capabilities": map[string]interface{}{"browserName": "firefox", "webSocketUrl": wsURL},
+		})
+	})
+	session, err := d.NewSession(Capabilities{"webSocketUrl": true, "Platform": "Linux"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bidi, err := session.DialBiDi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bidi.Close()
+
+	entries := make(chan BiDiLogEntry, 1)
+	bidi.OnLogEntry(func(e BiDiLogEntry) { entries <- e })
+	events, stop := bidi.Events("log.entryAdded", 1)
+	defer stop()
+	if err := bidi.Subscribe("log.entryAdded"); err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range []func() string{
+		func() string { e := <-entries; return e.Text },
+		func() string { e := <-events; return e.Method },
+	} {
+		done := make(chan string)
+		go func() { done <- got() }()
+		select {
+		case v := <-done:
+			if v != "boom" && v != "log.entryAdded" {
+				t.Fatalf("unexpected event value %q", v)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not delivered")
+		}
+	}
+
+	var bidiErr *BiDiError
+	if _, err := bidi.GetTree(); !errors.As(err, &bidiErr) || bidiErr.ErrorCode != "unknown command" {
+		t.Fatalf("got %v, want an unknown command error", err)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is the version of a browser or of a driver, compared as a semantic
+// version: "120.0.6099.109" is 120.0.6099, "115.0a1" is 115.0.0.
+type Version struct {
+	Major, Minor, Patch int
+	// Raw is the version as returned by the driver.
+	Raw string
+}
+
+// ParseVersion parses a version made of dot separated numbers, with an
+// optional "v" prefix. Parts after the third and suffixes of the numbers are
+// ignored.
+func ParseVersion(s string) (Version, error) {
+	v := Version{Raw: s}
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	numbers := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i := 0; i < len(parts) && i < len(numbers); i++ {
+		digits := parts[i]
+		if i := strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }); i >= 0 {
+			digits = digits[:i]
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil {
+			return Version{Raw: s}, fmt.Errorf("invalid version %q", s)
+		}
+		*numbers[i] = n
+	}
+	return v, nil
+}
+
+// Compare returns -1, 0 or +1 if v is lower than, equal to or greater than w.
+func (v Version) Compare(w Version) int {
+	for _, d := range []int{v.Major - w.Major, v.Minor - w.Minor, v.Patch - w.Patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+// IsZero returns true for a missing or invalid version.
+func (v Version) IsZero() bool {
+	return v.Major == 0 && v.Minor == 0 && v.Patch == 0
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// BrowserCapabilities are the capabilities of a session, as returned by the
+// driver in either dialect, parsed.
+type BrowserCapabilities struct {
+	// Name is the lowercase name of the browser, e.g. "chrome", "firefox",
+	// "safari"; Microsoft Edge is always "msedge".
+	Name    string
+	Version Version
+	// Platform is the lowercase name of the platform, e.g. "linux", "mac".
+	Platform string
+	// W3C is true if the driver speaks the W3C dialect.
+	W3C bool
+	// WebSocketURL is the URL of the BiDi connection, if requested.
+	WebSocketURL string
+	// Extensions are the vendor capabilities, e.g. "goog:chromeOptions".
+	Extensions map[string]interface{}
+}
+
+// ParseCapabilities parses the capabilities of a session as returned by its
+// driver: missing or malformed values are left empty. Versions may be strings
+// or numbers.
+func ParseCapabilities(c Capabilities) BrowserCapabilities {
+	var b BrowserCapabilities
+	caps := map[string]interface{}(c)
+	if w3c, ok := c["capabilities"].(map[string]interface{}); ok {
+		b.W3C = true
+		caps = w3c
+	}
+	name, _ := caps["browserName"].(string)
+	b.Name = strings.ToLower(name)
+	if b.Name == "microsoftedge" {
+		b.Name = "msedge"
+	}
+	version := caps["browserVersion"]
+	if version == nil {
+		//JSON Wire Protocol
+		version = caps["version"]
+	}
+	switch version := version.(type) {
+	case string:
+		b.Version, _ = ParseVersion(version)
+	case float64:
+		b.Version, _ = ParseVersion(strconv.FormatFloat(version, 'f', -1, 64))
+	}
+	platform, ok := caps["platformName"].(string)
+	if !ok {
+		platform, _ = caps["platform"].(string)
+	}
+	b.Platform = strings.ToLower(platform)
+	b.WebSocketURL, _ = caps["webSocketUrl"].(string)
+	for k, v := range caps {
+		if strings.Contains(k, ":") {
+			if b.Extensions == nil {
+				b.Extensions = map[string]interface{}{}
+			}
+			b.Extensions[k] = v
+		}
+	}
+	return b
+}
+
+// Browser returns the capabilities of the session, parsed the first time.
+func (s *Session) Browser() BrowserCapabilities {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.browser == nil {
+		b := ParseCapabilities(s.Capabilities)
+		s.browser = &b
+	}
+	return *s.browser
+}
+
+// Feature is a behavior of a driver that depends on the browser and its
+// version, see Session.Supports.
+type Feature string
+
+const (
+	// FeatureW3CTimeouts: the timeouts command sets the timeouts by name
+	// ("script", "pageLoad", "implicit") rather than by type and ms.
+	FeatureW3CTimeouts Feature = "w3c timeouts"
+	// FeatureW3CWindowHandles: the window handles are at /window/handles.
+	FeatureW3CWindowHandles Feature = "w3c window handles"
+	// FeatureSwitchWindowByName: a window can be switched to by its name.
+	FeatureSwitchWindowByName Feature = "switch window by name"
+	// FeatureSendKeysText: the keys sent to an element are a "text" string
+	// rather than a "value" array.
+	FeatureSendKeysText Feature = "send keys text"
+	// FeatureElementScreenshot: the driver takes screenshots of elements.
+	FeatureElementScreenshot Feature = "element screenshot"
+	// FeatureFullPageScreenshot: the browser takes screenshots of the whole
+	// page, with no scrolling, through the geckodriver command
+	// moz/screenshot/full or the DevTools protocol.
+	FeatureFullPageScreenshot Feature = "full page screenshot"
+)
+
+// the versions of a browser that support a feature: from the first version
+// that does, until the first that doesn't; zero bounds are open. via names the
+// protocol of the feature when the browsers differ: "moz" for the geckodriver
+// commands, "cdp" for the DevTools protocol.
+type versionRange struct {
+	from, until string
+	via         string
+}
+
+// featureMatrix lists the browsers that support each feature, by name. The
+// "w3c" entry is for the browsers of W3C sessions, the "*" entry for the
+// browsers not listed.
+var featureMatrix = map[Feature]map[string]versionRange{
+	FeatureW3CTimeouts: {
+		"firefox": {},
+		"safari":  {from: "12"},
+		"w3c":     {},
+	},
+	FeatureW3CWindowHandles: {
+		"safari": {from: "12"},
+		"w3c":    {},
+	},
+	FeatureSwitchWindowByName: {
+		//safaridriver only switches by handle
+		"safari": {until: "12"},
+		"*":      {},
+	},
+	FeatureSendKeysText: {
+		"firefox": {},
+		"safari":  {from: "12"},
+		"w3c":     {},
+	},
+	FeatureElementScreenshot: {
+		"w3c": {},
+	},
+	FeatureFullPageScreenshot: {
+		"firefox": {via: "moz"},
+		"chrome":  {via: "cdp"},
+		"msedge":  {via: "cdp"},
+	},
+}
+
+// Supports returns true if the browser of the session supports feature,
+// according to its name and version.
+func (s *Session) Supports(feature Feature) bool {
+	return s.Browser().Supports(feature)
+}
+
+// Supports returns true if the browser supports feature.
+func (b BrowserCapabilities) Supports(feature Feature) bool {
+	_, ok := b.support(feature)
+	return ok
+}
+
+// returns the range of the matrix the browser supports feature by, if any.
+func (b BrowserCapabilities) support(feature Feature) (versionRange, bool) {
+	browsers := featureMatrix[feature]
+	if r, ok := browsers[b.Name]; ok {
+		if r.contains(b.Version) {
+			return r, true
+		}
+	} else if r, ok := browsers["*"]; ok && r.contains(b.Version) {
+		return r, true
+	}
+	r, ok := browsers["w3c"]
+	if ok && b.W3C && r.contains(b.Version) {
+		return r, true
+	}
+	return versionRange{}, false
+}
+
+func (r versionRange) contains(v Version) bool {
+	if r.from != "" {
+		from, _ := ParseVersion(r.from)
+		if v.Compare(from) < 0 {
+			return false
+		}
+	}
+	if r.until != "" {
+		until, _ := ParseVersion(r.until)
+		if v.Compare(until) >= 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	for _, c := range []struct {
+		s    string
+		want Version
+	}{
+		{"120.0.6099.109", Version{120, 0, 6099, "120.0.6099.109"}},
+		{"17.2", Version{17, 2, 0, "17.2"}},
+		{"115.0a1", Version{115, 0, 0, "115.0a1"}},
+		{"v1.2.3", Version{1, 2, 3, "v1.2.3"}},
+	} {
+		if got, err := ParseVersion(c.s); err != nil || got != c.want {
+			t.Errorf("ParseVersion(%q) = %v, %v, want %v", c.s, got, err, c.want)
+		}
+	}
+	if _, err := ParseVersion("latest"); err == nil {
+		t.Error("invalid version parsed")
+	}
+	a, _ := ParseVersion("12.1")
+	b, _ := ParseVersion("12.0.9")
+	if a.Compare(b) != 1 || b.Compare(a) != -1 || a.Compare(a) != 0 {
+		t.Error("wrong comparison of 12.1 and 12.0.9")
+	}
+}
+
+func TestParseCapabilities(t *testing.T) {
+	b := ParseCapabilities(Capabilities{"capabilities": map[string]interface{}{
+		"browserName":                "Safari",
+		"browserVersion":             12.1,
+		"platformName":               "Mac",
+		"safari:automaticInspection": false,
+	}})
+	if b.Name != "safari" || b.Version.Compare(Version{Major: 12, Minor: 1}) != 0 || b.Platform != "mac" || !b.W3C {
+		t.Errorf("wrong W3C capabilities: %+v", b)
+	}
+	if _, ok := b.Extensions["safari:automaticInspection"]; !ok {
+		t.Errorf("extension missing: %v", b.Extensions)
+	}
+	b = ParseCapabilities(Capabilities{"browserName": "MicrosoftEdge", "version": "18", "platform": "WINDOWS"})
+	if b.Name != "msedge" || b.Version.Major != 18 || b.Platform != "windows" || b.W3C {
+		t.Errorf("wrong JSON Wire Protocol capabilities: %+v", b)
+	}
+	//malformed capabilities don't panic
+	b = ParseCapabilities(Capabilities{"capabilities": map[string]interface{}{"browserName": 1, "browserVersion": true}})
+	if b.Name != "" || !b.Version.IsZero() {
+		t.Errorf("wrong malformed capabilities: %+v", b)
+	}
+}
+
+func TestSupports(t *testing.T) {
+	w3c := func(name string, version interface{}) *Session {
+		caps := map[string]interface{}{"browserName": name}
+		if version != nil {
+			caps["browserVersion"] = version
+		}
+		return &Session{Capabilities: Capabilities{"capabilities": caps}}
+	}
+	for _, c := range []struct {
+		s       *Session
+		feature Feature
+		want    bool
+	}{
+		{w3c("Safari", "13.1"), FeatureW3CWindowHandles, true},
+		{w3c("Safari", "13.1"), FeatureSwitchWindowByName, false},
+		{w3c("Safari", "13.1"), FeatureSendKeysText, true},
+		//W3C safaridriver is 12 or later
+		{w3c("Safari", nil), FeatureSendKeysText, true},
+		{w3c("chrome", "120.0.6099.109"), FeatureSendKeysText, true},
+		{&Session{Capabilities: Capabilities{"browserName": "chrome"}}, FeatureSendKeysText, false},
+		{w3c("chrome", "120.0.6099.109"), FeatureW3CTimeouts, true},
+		{w3c("chrome", "120.0.6099.109"), FeatureSwitchWindowByName, true},
+		{w3c("firefox", 115.0), FeatureSendKeysText, true},
+		{w3c("msedge", "120.0"), FeatureFullPageScreenshot, true},
+		{w3c("Safari", "17.0"), FeatureFullPageScreenshot, false},
+		{&Session{Capabilities: Capabilities{"browserName": "chrome"}}, FeatureW3CTimeouts, false},
+		{&Session{Capabilities: Capabilities{"browserName": "safari", "version": "10"}}, FeatureSwitchWindowByName, true},
+		{&Session{Capabilities: Capabilities{"browserName": "safari", "version": "12"}}, FeatureW3CWindowHandles, true},
+	} {
+		if got := c.s.Supports(c.feature); got != c.want {
+			t.Errorf("%v supports %q: got %v, want %v", c.s.Browser(), c.feature, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CDPExecutor runs Chrome DevTools Protocol commands. It is implemented by
+// Session, through the driver, and by CDPConn, over a direct connection.
+type CDPExecutor interface {
+	CDP(method string, params, result interface{}) error
+}
+
+// Execute the Chrome DevTools Protocol command method with params and decode
+// its result into result, which may be nil. The command is relayed by the
+// driver (ChromeDriver or EdgeDriver) with its cdp/execute vendor endpoint,
+// which doesn't deliver events: use DialCDP for that.
+func (s *Session) CDP(method string, params, result interface{}) error {
+	if params == nil {
+		params = struct{}{}
+	}
+	p := map[string]interface{}{"cmd": method, "params": params}
+	_, data, err := s.do(p, "POST", "/session/%s/%s/cdp/execute", s.Id, s.cdpVendor())
+	if err != nil {
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(data, result)
+}
+
+// DevTools returns typed access to the common CDP domains, relayed by the driver.
+func (s *Session) DevTools() DevTools {
+	return newDevTools(s)
+}
+
+// returns the vendor prefix of the CDP endpoint and of the browser options capability.
+func (s *Session) cdpVendor() string {
+	if s.Browser().Name == "msedge" {
+		return "ms"
+	}
+	return "goog"
+}
+
+// returns the address of the DevTools server of the browser, e.g. "localhost:9222".
+func (s *Session) debuggerAddress() (string, error) {
+	key := "goog:chromeOptions"
+	if s.cdpVendor() == "ms" {
+		key = "ms:edgeOptions"
+	}
+	options, _ := s.capability(key).(map[string]interface{})
+	address, _ := options["debuggerAddress"].(string)
+	if address == "" {
+		return "", errors.New("cdp: session has no " + key + ".debuggerAddress capability")
+	}
+	return address, nil
+}
+
+// CDPError is an error returned by a Chrome DevTools Protocol command.
+type CDPError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data"`
+}
+
+func (e *CDPError) Error() string {
+	m := fmt.Sprintf("cdp error %d: %s", e.Code, e.Message)
+	if e.Data != "" {
+		m += ": " + e.Data
+	}
+	return m
+}
+
+// the time given to the DevTools server to list its targets.
+var cdpListTimeout = 10 * time.Second
+
+// CDPConn is a direct WebSocket connection to the DevTools server of a
+// browser. Unlike Session.CDP it delivers events. It is safe for concurrent use.
+type CDPConn struct {
+	rpc *rpcConn
+}
+
+// DialCDP connects to the DevTools target of the current window of the
+// session, using the debuggerAddress reported in the browser options
+// capability (goog:chromeOptions or ms:edgeOptions). The targets are listed
+// within cdpListTimeout, and the context of the session.
+func (s *Session) DialCDP() (*CDPConn, error) {
+	address, err := s.debuggerAddress()
+	if err != nil {
+		return nil, err
+	}
+	var targets []struct {
+		ID                   string `json:"id"`
+		Type                 string `json:"type"`
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	request, err := http.NewRequestWithContext(s.Context(), "GET", "http://"+address+"/json/list", nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: cdpListTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if err := json.NewDecoder(response.Body).Decode(&targets); err != nil {
+		return nil, fmt.Errorf("cdp: unable to list targets: %w", err)
+	}
+	//chromedriver window handles are target ids, older versions add a prefix
+	var id string
+	if handle, err := s.WindowHandle(); err == nil {
+		id = strings.TrimPrefix(handle.id, "CDwindow-")
+	}
+	wsURL := ""
+	for _, t := range targets {
+		if t.Type != "page" || t.WebSocketDebuggerURL == "" {
+			continue
+		}
+		if strings.EqualFold(t.ID, id) {
+			wsURL = t.WebSocketDebuggerURL
+			break
+		}
+		if wsURL == "" {
+			wsURL = t.WebSocketDebuggerURL
+		}
+	}
+	if wsURL == "" {
+		return nil, errors.New("cdp: no page target at " + address)
+	}
+	return DialCDPURL(wsURL)
+}
+
+// DialCDPURL connects to the DevTools target with WebSocket URL wsURL, as
+// reported by the webSocketDebuggerUrl field of http://host:port/json.
+func DialCDPURL(wsURL string) (*CDPConn, error) {
+	ws, err := dialWebSocket(wsURL)
+	if err != nil {
+		return nil, err
+	}
+	return &CDPConn{newRPCConn(ws, cdpResponseError)}, nil
+}
+
+func cdpResponseError(m *rpcMessage) error {
+	if len(m.Error) == 0 {
+		return nil
+	}
+	e := &CDPError{}
+	if err := json.Unmarshal(m.Error, e); err != nil {
+		e.Message = string(m.Error)
+	}
+	return e
+}
+
+// Execute the command method with params and decode its result into result, which may be nil.
+// The response is waited for up to a minute, see CDPContext.
+func (c *CDPConn) CDP(method string, params, result interface{}) error {
+	return c.rpc.call(method, params, result)
+}
+
+// CDPContext is CDP waiting for the response until ctx is done.
+func (c *CDPConn) CDPContext(ctx context.Context, method string, params, result interface{}) error {
+	return c.rpc.callContext(ctx, method, params, result)
+}
+
+// On registers handler for the event named method, e.g. "Network.requestWillBeSent".
+// The domain of the event must be enabled for the browser to send it.
+// Handlers are called one at a time, in the order the events arrive, and may
+// send commands. The returned function unregisters the handler.
+func (c *CDPConn) On(method string, handler func(params json.RawMessage)) func() {
+	return c.rpc.on(method, handler)
+}
+
+// DevTools returns typed access to the common CDP domains over the connection.
+func (c *CDPConn) DevTools() DevTools {
+	return newDevTools(c)
+}
+
+// Close the connection.
+func (c *CDPConn) Close() error {
+	return c.rpc.close()
+}
+
+// DevTools groups typed helpers for the commonly used CDP domains. Commands
+// not covered can be sent with the CDP method of the executor.
+type DevTools struct {
+	Network   CDPNetwork
+	Page      CDPPage
+	Emulation CDPEmulation
+	Runtime   CDPRuntime
+}
+
+func newDevTools(c CDPExecutor) DevTools {
+	return DevTools{CDPNetwork{c}, CDPPage{c}, CDPEmulation{c}, CDPRuntime{c}}
+}
+
+// CDPNetwork is the Network domain.
+type CDPNetwork struct {
+	c CDPExecutor
+}
+
+// NetworkConditions describes the emulated network.
+// Throughputs are in bytes per second, -1 disables throttling.
+type NetworkConditions struct {
+	Offline            bool    `json:"offline"`
+	Latency            float64 `json:"latency"` // milliseconds
+	DownloadThroughput float64 `json:"downloadThroughput"`
+	UploadThroughput   float64 `json:"uploadThroughput"`
+}
+
+// Enable network tracking, network events are delivered to the client.
+func (n CDPNetwork) Enable() error {
+	return n.c.CDP("Network.enable", nil, nil)
+}
+
+// Disable network tracking.
+func (n CDPNetwork) Disable() error {
+	return n.c.CDP("Network.disable", nil, nil)
+}
+
+// Emulate the network conditions. The domain must be enabled.
+func (n CDPNetwork) EmulateNetworkConditions(conditions NetworkConditions) error {
+	return n.c.CDP("Network.emulateNetworkConditions", conditions, nil)
+}
+
+// Send headers with every request issued by the page.
+func (n CDPNetwork) SetExtraHTTPHeaders(headers map[string]string) error {
+	return n.c.CDP("Network.setExtraHTTPHeaders", map[string]interface{}{"headers": headers}, nil)
+}
+
+// Toggle ignoring the cache for each request.
+func (n CDPNetwork) SetCacheDisabled(disabled bool) error {
+	return n.c.CDP("Network.setCacheDisabled", map[string]interface{}{"cacheDisabled": disabled}, nil)
+}
+
+// Clear the browser cache.
+func (n CDPNetwork) ClearBrowserCache() error {
+	return n.c.CDP("Network.clearBrowserCache", nil, nil)
+}
+
+// Clear the browser cookies.
+func (n CDPNetwork) ClearBrowserCookies() error {
+	return n.c.CDP("Network.clearBrowserCookies", nil, nil)
+}
+
+// Get the body of the response to the request with the given id, as reported by network events.
+func (n CDPNetwork) GetResponseBody(requestID string) ([]byte, error) {
+	var result struct {
+		Body          string `json:"body"`
+		Base64Encoded bool   `json:"base64Encoded"`
+	}
+	err := n.c.CDP("Network.getResponseBody", map[string]interface{}{"requestId": requestID}, &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Base64Encoded {
+		return base64.StdEncoding.DecodeString(result.Body)
+	}
+	return []byte(result.Body), nil
+}
+
+// CDPPage is the Page domain.
+type CDPPage struct {
+	c CDPExecutor
+}
+
+// CaptureScreenshotParams are the parameters of Page.captureScreenshot.
+type CaptureScreenshotParams struct {
+	Format                string    `json:"format,omitempty"` // png (default), jpeg or webp
+	Quality               int       `json:"quality,omitempty"`
+	Clip                  *Viewport `json:"clip,omitempty"`
+	FromSurface           bool      `json:"fromSurface,omitempty"`
+	CaptureBeyondViewport bool      `json:"captureBeyondViewport,omitempty"`
+}
+
+// Viewport is an area of the page, in CSS pixels.
+type Viewport struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Scale  float64 `json:"scale"`
+}
+
+// LayoutMetrics are the sizes of the page, in CSS pixels.
+type LayoutMetrics struct {
+	CSSLayoutViewport struct {
+		PageX        float64 `json:"pageX"`
+		PageY        float64 `json:"pageY"`
+		ClientWidth  float64 `json:"clientWidth"`
+		ClientHeight float64 `json:"clientHeight"`
+	} `json:"cssLayoutViewport"`
+	CSSContentSize struct {
+		X      float64 `json:"x"`
+		Y      float64 `json:"y"`
+		Width  float64 `json:"width"`
+		Height float64 `json:"height"`
+	} `json:"cssContentSize"`
+}
+
+// Enable page events.
+func (p CDPPage) Enable() error {
+	return p.c.CDP("Page.enable", nil, nil)
+}
+
+// Navigate to url.
+func (p CDPPage) Navigate(url string) error {
+	var result struct {
+		ErrorText string `json:"errorText"`
+	}
+	if err := p.c.CDP("Page.navigate", map[string]interface{}{"url": url}, &result); err != nil {
+		return err
+	}
+	if result.ErrorText != "" {
+		return errors.New("cdp: navigation failed: " + result.ErrorText)
+	}
+	return nil
+}
+
+// Reload the page, optionally ignoring the cache.
+func (p CDPPage) Reload(ignoreCache bool) error {
+	return p.c.CDP("Page.reload", map[string]interface{}{"ignoreCache": ignoreCache}, nil)
+}
+
+// Capture a screenshot of the page and return the encoded image.
+func (p CDPPage) CaptureScreenshot(params CaptureScreenshotParams) ([]byte, error) {
+	var result struct {
+		Data string `json:"data"`
+	}
+	if err := p.c.CDP("Page.captureScreenshot", params, &result); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(result.Data)
+}
+
+// Get the layout metrics of the page.
+func (p CDPPage) GetLayoutMetrics() (LayoutMetrics, error) {
+	var metrics LayoutMetrics
+	err := p.c.CDP("Page.getLayoutMetrics", nil, &metrics)
+	return metrics, err
+}
+
+// Set the behavior of downloads: "deny", "allow" (to downloadPath) or "default".
+func (p CDPPage) SetDownloadBehavior(behavior, downloadPath string) error {
+	params := map[string]interface{}{"behavior": behavior}
+	if downloadPath != "" {
+		params["downloadPath"] = downloadPath
+	}
+	return p.c.CDP("Page.setDownloadBehavior", params, nil)
+}
+
+// CDPEmulation is the Emulation domain.
+type CDPEmulation struct {
+	c CDPExecutor
+}
+
+// DeviceMetrics are the emulated screen metrics.
+type DeviceMetrics struct {
+	Width             int     `json:"width"`
+	Height            int     `json:"height"`
+	DeviceScaleFactor float64 `json:"deviceScaleFactor"`
+	Mobile            bool    `json:"mobile"`
+}
+
+// Override the device screen metrics.
+func (e CDPEmulation) SetDeviceMetricsOverride(metrics DeviceMetrics) error {
+	return e.c.CDP("Emulation.setDeviceMetricsOverride", metrics, nil)
+}
+
+// Clear the overridden device metrics.
+func (e CDPEmulation) ClearDeviceMetricsOverride() error {
+	return e.c.CDP("Emulation.clearDeviceMetricsOverride", nil, nil)
+}
+
+// Override the time zone with an IANA id, e.g. "Europe/Rome". "" restores the default.
+func (e CDPEmulation) SetTimezoneOverride(timezoneID string) error {
+	return e.c.CDP("Emulation.setTimezoneOverride", map[string]interface{}{"timezoneId": timezoneID}, nil)
+}
+
+// Override the geolocation.
+func (e CDPEmulation) SetGeolocationOverride(location GeoLocation, accuracy float64) error {
+	p := map[string]interface{}{"latitude": location.Latitude, "longitude": location.Longitude, "accuracy": accuracy}
+	return e.c.CDP("Emulation.setGeolocationOverride", p, nil)
+}
+
+// Override the user agent.
+func (e CDPEmulation) SetUserAgentOverride(userAgent string) error {
+	return e.c.CDP("Emulation.setUserAgentOverride", map[string]interface{}{"userAgent": userAgent}, nil)
+}
+
+// Slow down the CPU by rate (1 is no throttling).
+func (e CDPEmulation) SetCPUThrottlingRate(rate float64) error {
+	return e.c.CDP("Emulation.setCPUThrottlingRate", map[string]interface{}{"rate": rate}, nil)
+}
+
+// CDPRuntime is the Runtime domain.
+type CDPRuntime struct {
+	c CDPExecutor
+}
+
+// Enable runtime events (console messages, exceptions).
+func (r CDPRuntime) Enable() error {
+	return r.c.CDP("Runtime.enable", nil, nil)
+}
+
+// Evaluate expression in the page, awaiting promises, and decode its value into result, which may be nil.
+func (r CDPRuntime) Evaluate(expression string, result interface{}) error {
+	var reply struct {
+		Result struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+		ExceptionDetails *struct {
+			Text      string `json:"text"`
+			Exception struct {
+				Description string `json:"description"`
+			} `json:"exception"`
+		} `json:"exceptionDetails"`
+	}
+	p := map[string]interface{}{"expression": expression, "returnByValue": true, "awaitPromise": true}
+	if err := r.c.CDP("Runtime.evaluate", p, &reply); err != nil {
+		return err
+	}
+	if e := reply.ExceptionDetails; e != nil {
+		if e.Exception.Description != "" {
+			return errors.New("cdp: " + e.Exception.Description)
+		}
+		return errors.New("cdp: " + e.Text)
+	}
+	if result == nil || len(reply.Result.Value) == 0 {
+		return nil
+	}
+	return json.Unmarshal(reply.Result.Value, result)
+}
+
+// Get the JavaScript heap usage, in bytes.
+func (r CDPRuntime) GetHeapUsage() (used, total float64, err error) {
+	var result struct {
+		UsedSize  float64 `json:"usedSize"`
+		TotalSize float64 `json:"totalSize"`
+	}
+	err = r.c.CDP("Runtime.getHeapUsage", nil, &result)
+	return result.UsedSize, result.TotalSize, err
+}
@@ -0,0 +1,120 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSessionCDP(t *testing.T) {
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/session/s1/goog/cdp/execute" {
+			http.NotFound(w, r)
+			return
+		}
+		var body struct {
+			Cmd    string
+			Params map[string]interface{}
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Cmd != "Runtime.getHeapUsage" {
+			writeError(w, 500, "unknown error")
+			return
+		}
+		writeValue(w, map[string]float64{"usedSize": 10, "totalSize": 20})
+	})
+	used, total, err := fakeSession(d).DevTools().Runtime.GetHeapUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used != 10 || total != 20 {
+		t.Fatalf("got %v/%v, want 10/20", used, total)
+	}
+}
+
+func TestCDPConn(t *testing.T) {
+	url := serveWebSocket(t, func(c *wsConn) {
+		for {
+			data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			var m rpcMessage
+			json.Unmarshal(data, &m)
+			switch m.Method {
+			case "Network.enable":
+				c.WriteMessage([]byte(`{"method":"Network.requestWillBeSent","params":{"requestId":"r1"}}`))
+				c.WriteMessage([]byte(`{"id":` + string(mustJSON(m.ID)) + `,"result":{}}`))
+			default:
+				c.WriteMessage([]byte(`{"id":` + string(mustJSON(m.ID)) + `,"error":{"code":-32601,"message":"not found"}}`))
+			}
+		}
+	})
+	conn, err := DialCDPURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	events := make(chan string, 1)
+	conn.On("Network.requestWillBeSent", func(params json.RawMessage) {
+		var p struct{ RequestID string }
+		json.Unmarshal(params, &p)
+		events <- p.RequestID
+	})
+	if err := conn.DevTools().Network.Enable(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case id := <-events:
+		if id != "r1" {
+			t.Fatalf("got request %q, want r1", id)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not delivered")
+	}
+	var cdpErr *CDPError
+	if err := conn.CDP("Foo.bar", nil, nil); !errors.As(err, &cdpErr) || cdpErr.Code != -32601 {
+		t.Fatalf("got %v, want a CDPError", err)
+	}
+}
+
+func mustJSON(v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+func TestDialCDPTimeout(t *testing.T) {
+	defer func(timeout time.Duration) { cdpListTimeout = timeout }(cdpListTimeout)
+	cdpListTimeout = 50 * time.Millisecond
+	hung := make(chan struct{})
+	debugger := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hung
+	}))
+	defer debugger.Close()
+	defer close(hung)
+
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {})
+	s := fakeSession(d)
+	s.Capabilities = Capabilities{"capabilities": map[string]interface{}{
+		"browserName":        "chrome",
+		"goog:chromeOptions": map[string]interface{}{"debuggerAddress": strings.TrimPrefix(debugger.URL, "http://")},
+	}}
+	start := time.Now()
+	if _, err := s.DialCDP(); err == nil {
+		t.Fatal("dialed an unresponsive debugger")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("gave up after %v", elapsed)
+	}
+}
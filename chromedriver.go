@@ -24,16 +24,31 @@ type ChromeDriver struct {
 	BaseUrl string
 	//The number of threads to use for handling HTTP requests. Default: 4
 	Threads int
-	//The path to use for the ChromeDriver server log. Default: ./chromedriver.log
+	//The path of the chromedriver log. Default: "" (no log file)
 	LogPath string
+	//The verbosity of the log: "ALL", "DEBUG", "INFO", "WARNING", "SEVERE" or "OFF" (--log-level). Default: "", the level of the driver
+	LogLevel string
+	//Log verbosely (--verbose).
+	Verbose bool
+	//Extra arguments of the driver, after those of the settings above, e.g. "--allowed-ips=10.0.0.2" or "--whitelisted-ips=" for older versions.
+	ExtraArgs []string
+	//Environment of the driver, "KEY=value" entries added to the inherited one, which they override. Default: nil
+	Env []string
+	//Working directory of the driver. Default: "", the current directory
+	Dir string
+	//Start the driver in a new process group, which includes the browsers it starts, and signal the whole group in Stop and Signal.
+	ProcessGroup bool
 	// Log file to dump chromedriver stdout/stderr. If "" send to terminal. Default: ""
 	LogFile string
 	// Start method fails if Chromedriver doesn't start in less than StartTimeout. Default 20s.
 	StartTimeout time.Duration
+	// Display, if set, is the X display of the browser. It is started with the driver, unless it is running already, and then stopped with it.
+	Display *Display
 
-	path    string
-	cmd     *exec.Cmd
-	logFile *os.File
+	path       string
+	cmd        *exec.Cmd
+	logFile    *os.File
+	ownDisplay bool
 }
 
 //create a new service using chromedriver.
@@ -43,6 +58,7 @@ type ChromeDriver struct {
 func NewChromeDriver(path string) *ChromeDriver {
 	d := &ChromeDriver{}
 	d.path = path
+	d.registry = newSessionRegistry()
 	d.Port = 9515
 	d.BaseUrl = ""
 	d.Threads = 4
@@ -51,8 +67,6 @@ func NewChromeDriver(path string) *ChromeDriver {
 	return d
 }
 
-var switchesFormat = "-port=%d -url-base=%s -log-path=%s -http-threads=%d"
-
 var cmdchan = make(chan error)
 
 func (d *ChromeDriver) Start() error {
@@ -73,37 +87,62 @@ func (d *ChromeDriver) Start() error {
 	d.url = fmt.Sprintf("http://127.0.0.1:%d%s", d.Port, d.BaseUrl)
 	var switches []string
 	switches = append(switches, "-port="+strconv.Itoa(d.Port))
-	// switches = append(switches, "-log-path="+d.LogPath)
 	switches = append(switches, "-http-threads="+strconv.Itoa(d.Threads))
 	if d.BaseUrl != "" {
 		switches = append(switches, "-url-base="+d.BaseUrl)
 	}
 
+	if d.LogPath != "" {
+		switches = append(switches, "--log-path="+d.LogPath)
+	}
+	if d.LogLevel != "" {
+		switches = append(switches, "--log-level="+d.LogLevel)
+	} else if d.Verbose {
+		switches = append(switches, "--verbose")
+	}
+	switches = append(switches, d.ExtraArgs...)
+
 	d.cmd = exec.Command(d.path, switches...)
+	setupProcess(d.cmd, d.Env, d.Dir, d.ProcessGroup)
+	if d.Display != nil {
+		started, err := d.Display.startFor(d.cmd)
+		if err != nil {
+			d.cmd = nil
+			return errors.New(csferr + err.Error())
+		}
+		d.ownDisplay = started
+	}
 	stdout, err := d.cmd.StdoutPipe()
 	if err != nil {
+		d.abortStart()
 		return errors.New(csferr + err.Error())
 	}
 	stderr, err := d.cmd.StderrPipe()
 	if err != nil {
+		d.abortStart()
 		return errors.New(csferr + err.Error())
 	}
 	if err := d.cmd.Start(); err != nil {
+		d.abortStart()
 		return errors.New(csferr + err.Error())
 	}
-	if d.LogFile != "" {
+	logFile := d.LogFile
+	d.logs = newRingBuffer(driverLogSize)
+	if logFile != "" {
 		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
-		d.logFile, err = os.OpenFile(d.LogFile, flags, 0640)
+		d.logFile, err = os.OpenFile(logFile, flags, 0640)
 		if err != nil {
+			d.abortStart()
 			return err
 		}
-		go io.Copy(d.logFile, stdout)
-		go io.Copy(d.logFile, stderr)
+		go io.Copy(io.MultiWriter(d.logFile, d.logs), stdout)
+		go io.Copy(io.MultiWriter(d.logFile, d.logs), stderr)
 	} else {
-		go io.Copy(os.Stdout, stdout)
-		go io.Copy(os.Stderr, stderr)
+		go io.Copy(io.MultiWriter(os.Stdout, d.logs), stdout)
+		go io.Copy(io.MultiWriter(os.Stderr, d.logs), stderr)
 	}
 	if err = probePort(d.Port, d.StartTimeout); err != nil {
+		d.abortStart()
 		return err
 	}
 	return nil
@@ -116,13 +155,46 @@ func (d *ChromeDriver) Stop() error {
 	defer func() {
 		d.cmd = nil
 	}()
-	d.cmd.Process.Signal(os.Interrupt)
+	//close the browsers before the driver is gone
+	d.DeleteAllSessions()
+	signalProcess(d.cmd, d.ProcessGroup, os.Interrupt)
 	if d.logFile != nil {
 		d.logFile.Close()
 	}
+	d.stopDisplay()
 	return nil
 }
 
+//undo a failed Start: kill the driver if it started, stop the display it was started on, close the log file and forget the process, so that Start can be called again.
+func (d *ChromeDriver) abortStart() {
+	if d.cmd.Process != nil {
+		signalProcess(d.cmd, d.ProcessGroup, os.Kill)
+		go d.cmd.Wait()
+	}
+	if d.logFile != nil {
+		d.logFile.Close()
+		d.logFile = nil
+	}
+	d.stopDisplay()
+	d.cmd = nil
+}
+
+//stop the display if it was started with the driver.
+func (d *ChromeDriver) stopDisplay() {
+	if d.ownDisplay {
+		d.Display.Stop()
+		d.ownDisplay = false
+	}
+}
+
+//Signal sends sig to the driver, or to its process group if ProcessGroup is set.
+func (d *ChromeDriver) Signal(sig os.Signal) error {
+	if d.cmd == nil {
+		return errors.New("signal failed: chromedriver not running")
+	}
+	return signalProcess(d.cmd, d.ProcessGroup, sig)
+}
+
 func (d *ChromeDriver) NewSession(desired, required Capabilities) (*Session, error) {
 	//id, capabs, err := d.newSession(desired, required)
 	//return &Session{id, capabs, d}, err
@@ -134,7 +206,7 @@ func (d *ChromeDriver) NewSession(desired, required Capabilities) (*Session, err
 	return session, nil
 }
 
-func (d *ChromeDriver) Sessions() ([]Session, error) {
+func (d *ChromeDriver) Sessions() ([]*Session, error) {
 	sessions, err := d.sessions()
 	if err != nil {
 		return nil, err
@@ -0,0 +1,12 @@
+module github.com/tooolbox/webdriver/cmd/wdshell
+
+go 1.23.0
+
+require (
+	github.com/tooolbox/webdriver v0.0.0
+	golang.org/x/term v0.32.0
+)
+
+require golang.org/x/sys v0.33.0 // indirect
+
+replace github.com/tooolbox/webdriver => ../..
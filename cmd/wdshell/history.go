@@ -0,0 +1,65 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"os"
+)
+
+// the number of lines of history kept.
+const historySize = 1000
+
+// history is the history of the lines read by the terminal, kept in a file
+// between runs. It implements term.History.
+type history struct {
+	path  string
+	lines []string
+}
+
+// loads the history from path. A missing file is an empty history.
+func loadHistory(path string) *history {
+	h := &history{path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		return h
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.add(scanner.Text())
+	}
+	return h
+}
+
+// adds line to the history, unless it is empty or repeats the last one.
+func (h *history) add(line string) bool {
+	if line == "" || len(h.lines) > 0 && h.lines[len(h.lines)-1] == line {
+		return false
+	}
+	h.lines = append(h.lines, line)
+	if len(h.lines) > historySize {
+		h.lines = h.lines[len(h.lines)-historySize:]
+	}
+	return true
+}
+
+// Add adds line to the history and appends it to the file.
+func (h *history) Add(line string) {
+	if !h.add(line) || h.path == "" {
+		return
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString(line + "\n")
+}
+
+func (h *history) Len() int { return len(h.lines) }
+
+// At returns the line i, 0 is the most recent.
+func (h *history) At(i int) string { return h.lines[len(h.lines)-1-i] }
@@ -0,0 +1,262 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command wdshell drives a browser session from an interactive shell, to
+// explore a live page, e.g. its selectors, before writing page objects.
+//
+// It starts the driver of a browser, or attaches to a running one with -url,
+// creates a session and reads commands:
+//
+//	$ wdshell -browser firefox -cap acceptInsecureCerts=true
+//	wd> go example.com
+//	wd> find css a
+//	$1 <a> "More information..."
+//	wd> attr $1 href
+//	https://www.iana.org/domains/example
+//	wd> click
+//	wd> exec return document.title
+//	"IANA-managed Reserved Domains"
+//	wd> screenshot out.png
+//
+// Type help for the list of commands. Tab completes commands and element
+// handles, the history is kept in ~/.wdshell_history. Commands can be piped
+// too, one per line.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/tooolbox/webdriver"
+	"golang.org/x/term"
+)
+
+// capabilities set by -cap name=value, the value is JSON or a string.
+type capFlags webdriver.Capabilities
+
+func (c capFlags) String() string { return "" }
+
+func (c capFlags) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return errors.New("want name=value")
+	}
+	name, raw := s[:i], s[i+1:]
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+	c[name] = value
+	return nil
+}
+
+var browserNames = map[string]string{
+	"chrome":  "chrome",
+	"firefox": "firefox",
+	"edge":    "MicrosoftEdge",
+	"safari":  "safari",
+	"ie11":    "internet explorer",
+}
+
+func main() {
+	caps := capFlags{}
+	browser := flag.String("browser", "chrome", "browser: chrome, firefox, edge, safari or ie11")
+	driverPath := flag.String("driver", "", "path of the driver, default: found by webdriver.FindDriver")
+	remote := flag.String("url", "", "URL of a running driver or Selenium server to attach to, instead of starting a driver")
+	headless := flag.Bool("headless", false, "run chrome, firefox or edge without a window")
+	historyPath := flag.String("history", defaultHistoryPath(), "file of the history, none if empty")
+	flag.Var(caps, "cap", "capability of the session as name=value, the value is JSON or a string; repeatable")
+	flag.Parse()
+	if _, ok := browserNames[*browser]; !ok {
+		fatal(fmt.Errorf("unknown browser %q", *browser))
+	}
+
+	desired := webdriver.Capabilities{"browserName": browserNames[*browser]}
+	if *headless {
+		setHeadless(desired, *browser)
+	}
+	for name, value := range caps {
+		desired[name] = value
+	}
+
+	var driver webdriver.WebDriver
+	if *remote != "" {
+		driver = webdriver.NewRemoteDriver(*remote)
+	} else {
+		var err error
+		if driver, err = startDriver(*browser, *driverPath); err != nil {
+			fatal(err)
+		}
+	}
+	session, err := driver.NewSession(desired, nil)
+	if err != nil {
+		driver.Stop()
+		fatal(err)
+	}
+	cleanup := func() {
+		session.Delete()
+		driver.Stop()
+	}
+
+	sh := newShell(session, os.Stdout)
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		err = interactive(sh, *historyPath)
+	} else {
+		go func() {
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			<-interrupt
+			cleanup()
+			os.Exit(1)
+		}()
+		err = script(sh, os.Stdin)
+	}
+	cleanup()
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// runs the commands typed in the terminal, until quit or EOF.
+func interactive(sh *shell, historyPath string) error {
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), state)
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "wd> ")
+	t.History = loadHistory(historyPath)
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		line, pos, candidates := sh.complete(line, pos)
+		if len(candidates) > 0 {
+			fmt.Fprintln(t, strings.Join(candidates, "  "))
+		}
+		return line, pos, true
+	}
+	sh.out = t
+	for {
+		line, err := t.ReadLine()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := sh.run(line); err == errQuit {
+			return nil
+		} else if err != nil {
+			fmt.Fprintln(t, "error:", err)
+		}
+	}
+}
+
+// runs the commands read from r, one per line. It stops at the first error.
+func script(sh *shell, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if err := sh.run(scanner.Text()); err == errQuit {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %v", scanner.Text(), err)
+		}
+	}
+	return scanner.Err()
+}
+
+// start the driver of browser on a free port. Its output is written to a file
+// in the temporary directory, not to the terminal.
+func startDriver(browser, path string) (webdriver.WebDriver, error) {
+	if path == "" && browser != "ie11" {
+		info, err := webdriver.FindDriver(browser, webdriver.DiscoverOptions{})
+		if err != nil {
+			return nil, err
+		}
+		path = info.Path
+	}
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	logFile := filepath.Join(os.TempDir(), "wdshell-"+browser+".log")
+	var driver webdriver.WebDriver
+	switch browser {
+	case "chrome":
+		d := webdriver.NewChromeDriver(path)
+		d.Port, d.LogFile = port, logFile
+		driver = d
+	case "firefox":
+		d := webdriver.NewFirefoxDriver(path)
+		d.Port, d.LogFile = port, logFile
+		driver = d
+	case "edge":
+		d := webdriver.NewEdgeDriver(path)
+		d.Port, d.LogFile = port, logFile
+		driver = d
+	case "safari":
+		d := webdriver.NewSafariDriver(path)
+		d.Port, d.LogFile = port, logFile
+		driver = d
+	case "ie11":
+		if path == "" {
+			path = "IEDriverServer"
+		}
+		d := webdriver.NewIE11Driver(path)
+		d.Port, d.LogFile = port, logFile
+		driver = d
+	}
+	if err := driver.Start(); err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(os.Stderr, "driver log:", logFile)
+	return driver, nil
+}
+
+func freePort() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+// adds the headless argument to the options of the browser.
+func setHeadless(caps webdriver.Capabilities, browser string) {
+	switch browser {
+	case "chrome":
+		caps["goog:chromeOptions"] = map[string]interface{}{"args": []string{"--headless=new"}}
+	case "edge":
+		caps["ms:edgeOptions"] = map[string]interface{}{"args": []string{"--headless=new"}}
+	case "firefox":
+		caps["moz:firefoxOptions"] = map[string]interface{}{"args": []string{"-headless"}}
+	}
+}
+
+func defaultHistoryPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".wdshell_history")
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "wdshell:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,354 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/tooolbox/webdriver"
+)
+
+// shell runs the commands of a line on a session. The elements found are
+// kept as handles, $1, $2..., that the element commands accept as their first
+// argument; without a handle they act on the first element of the last find.
+type shell struct {
+	session  *webdriver.Session
+	out      io.Writer
+	elements []webdriver.WebElement
+	// the index of the element used when no handle is given, -1 if none
+	current int
+}
+
+func newShell(session *webdriver.Session, out io.Writer) *shell {
+	return &shell{session: session, out: out, current: -1}
+}
+
+type command struct {
+	args string
+	help string
+	run  func(sh *shell, arg string) error
+}
+
+var commands map[string]command
+
+// the strategies of find, by their short name.
+var strategies = map[string]webdriver.FindElementStrategy{
+	"css":     webdriver.CSS_Selector,
+	"xpath":   webdriver.XPath,
+	"id":      webdriver.ID,
+	"name":    webdriver.Name,
+	"tag":     webdriver.TagName,
+	"class":   webdriver.ClassName,
+	"link":    webdriver.LinkText,
+	"partial": webdriver.PartialLinkText,
+}
+
+var errQuit = errors.New("quit")
+
+func init() {
+	// set in init, help refers to commands
+	commands = map[string]command{
+		"go":         {"<url>", "load url", (*shell).goURL},
+		"url":        {"", "print the current URL", (*shell).url},
+		"title":      {"", "print the title of the page", (*shell).title},
+		"back":       {"", "go back in the history", noArg((*webdriver.Session).Back)},
+		"forward":    {"", "go forward in the history", noArg((*webdriver.Session).Forward)},
+		"refresh":    {"", "reload the page", noArg((*webdriver.Session).Refresh)},
+		"source":     {"", "print the source of the page", (*shell).source},
+		"find":       {"<css|xpath|id|name|tag|class|link|partial> <value>", "find elements and give them handles", (*shell).find},
+		"elements":   {"", "list the handles of the elements found", (*shell).list},
+		"click":      {"[$n]", "click an element", (*shell).click},
+		"text":       {"[$n]", "print the text of an element", (*shell).text},
+		"attr":       {"[$n] <name>", "print an attribute of an element", (*shell).attr},
+		"type":       {"[$n] <text>", "send keys to an element", (*shell).typeText},
+		"clear":      {"[$n]", "clear an input element", (*shell).clear},
+		"exec":       {"<js>", "execute a script and print its result, e.g. exec return document.title", (*shell).exec},
+		"screenshot": {"<file.png>", "save a screenshot of the page", (*shell).screenshot},
+		"cookies":    {"", "print the cookies of the page", (*shell).cookies},
+		"help":       {"", "print this help", (*shell).help},
+		"quit":       {"", "delete the session and exit", func(*shell, string) error { return errQuit }},
+	}
+}
+
+// run executes a line. It returns errQuit to end the shell.
+func (sh *shell) run(line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil
+	}
+	name, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	if name == "exit" {
+		name = "quit"
+	}
+	c, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q, try help", name)
+	}
+	return c.run(sh, arg)
+}
+
+func noArg(f func(*webdriver.Session) error) func(*shell, string) error {
+	return func(sh *shell, arg string) error {
+		return f(sh.session)
+	}
+}
+
+func (sh *shell) goURL(arg string) error {
+	if arg == "" {
+		return errors.New("usage: go <url>")
+	}
+	if !strings.Contains(arg, "://") && !strings.HasPrefix(arg, "about:") {
+		arg = "https://" + arg
+	}
+	return sh.session.Url(arg)
+}
+
+func (sh *shell) url(string) error {
+	url, err := sh.session.GetUrl()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, url)
+	return nil
+}
+
+func (sh *shell) title(string) error {
+	title, err := sh.session.Title()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, title)
+	return nil
+}
+
+func (sh *shell) source(string) error {
+	source, err := sh.session.Source()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, source)
+	return nil
+}
+
+func (sh *shell) find(arg string) error {
+	by, value := arg, ""
+	if i := strings.IndexAny(arg, " \t"); i >= 0 {
+		by, value = arg[:i], strings.TrimSpace(arg[i+1:])
+	}
+	strategy, ok := strategies[by]
+	if !ok || value == "" {
+		return errors.New("usage: find " + commands["find"].args)
+	}
+	elements, err := sh.session.FindElements(strategy, value)
+	if err != nil {
+		return err
+	}
+	if len(elements) == 0 {
+		fmt.Fprintln(sh.out, "no elements")
+		return nil
+	}
+	sh.current = len(sh.elements)
+	for _, e := range elements {
+		sh.elements = append(sh.elements, e)
+		sh.describe(len(sh.elements) - 1)
+	}
+	return nil
+}
+
+func (sh *shell) list(string) error {
+	for i := range sh.elements {
+		sh.describe(i)
+	}
+	return nil
+}
+
+// prints the handle, tag and text of the element i.
+func (sh *shell) describe(i int) {
+	e := sh.elements[i]
+	tag, err := e.Name()
+	if err != nil {
+		fmt.Fprintf(sh.out, "$%d %v\n", i+1, err)
+		return
+	}
+	text, _ := e.Text()
+	text = strings.Join(strings.Fields(text), " ")
+	if len(text) > 60 {
+		text = text[:57] + "..."
+	}
+	fmt.Fprintf(sh.out, "$%d <%s> %q\n", i+1, tag, text)
+}
+
+// returns the element of the handle at the start of arg, or the current
+// element, and the rest of arg.
+func (sh *shell) element(arg string) (webdriver.WebElement, string, error) {
+	if strings.HasPrefix(arg, "$") {
+		handle, rest := arg, ""
+		if i := strings.IndexAny(arg, " \t"); i >= 0 {
+			handle, rest = arg[:i], strings.TrimSpace(arg[i+1:])
+		}
+		n, err := strconv.Atoi(handle[1:])
+		if err != nil || n < 1 || n > len(sh.elements) {
+			return webdriver.WebElement{}, "", fmt.Errorf("no element %s", handle)
+		}
+		sh.current = n - 1
+		return sh.elements[n-1], rest, nil
+	}
+	if sh.current < 0 {
+		return webdriver.WebElement{}, "", errors.New("no element, use find first")
+	}
+	return sh.elements[sh.current], arg, nil
+}
+
+func (sh *shell) click(arg string) error {
+	e, _, err := sh.element(arg)
+	if err != nil {
+		return err
+	}
+	return e.Click()
+}
+
+func (sh *shell) text(arg string) error {
+	e, _, err := sh.element(arg)
+	if err != nil {
+		return err
+	}
+	text, err := e.Text()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, text)
+	return nil
+}
+
+func (sh *shell) attr(arg string) error {
+	e, name, err := sh.element(arg)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("usage: attr " + commands["attr"].args)
+	}
+	value, err := e.GetAttribute(name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, value)
+	return nil
+}
+
+func (sh *shell) typeText(arg string) error {
+	e, text, err := sh.element(arg)
+	if err != nil {
+		return err
+	}
+	return e.SendKeys(text)
+}
+
+func (sh *shell) clear(arg string) error {
+	e, _, err := sh.element(arg)
+	if err != nil {
+		return err
+	}
+	return e.Clear()
+}
+
+func (sh *shell) exec(arg string) error {
+	if arg == "" {
+		return errors.New("usage: exec <js>")
+	}
+	result, err := sh.session.ExecuteScript(arg, []interface{}{})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(sh.out, string(result))
+	return nil
+}
+
+func (sh *shell) screenshot(arg string) error {
+	if arg == "" {
+		return errors.New("usage: screenshot <file.png>")
+	}
+	return sh.session.SaveScreenshot(arg)
+}
+
+func (sh *shell) cookies(string) error {
+	cookies, err := sh.session.GetCookies()
+	if err != nil {
+		return err
+	}
+	for _, c := range cookies {
+		fmt.Fprintf(sh.out, "%s=%s domain=%s path=%s secure=%v\n", c.Name, c.Value, c.Domain, c.Path, c.Secure)
+	}
+	return nil
+}
+
+func (sh *shell) help(string) error {
+	for _, name := range commandNames() {
+		c := commands[name]
+		fmt.Fprintf(sh.out, "  %-30s %s\n", strings.TrimSpace(name+" "+c.args), c.help)
+	}
+	return nil
+}
+
+func commandNames() []string {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// complete completes the word of line before pos with a command name, a find
+// strategy or an element handle. It returns the new line and position, and
+// the candidates if there are several.
+func (sh *shell) complete(line string, pos int) (string, int, []string) {
+	start := strings.LastIndexAny(line[:pos], " \t") + 1
+	word := line[start:pos]
+	fields := strings.Fields(line[:start])
+	var candidates []string
+	switch {
+	case len(fields) == 0:
+		candidates = commandNames()
+	case strings.HasPrefix(word, "$"):
+		for i := range sh.elements {
+			candidates = append(candidates, "$"+strconv.Itoa(i+1))
+		}
+	case len(fields) == 1 && fields[0] == "find":
+		for name := range strategies {
+			candidates = append(candidates, name)
+		}
+		sort.Strings(candidates)
+	}
+	var matches []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, word) {
+			matches = append(matches, c)
+		}
+	}
+	if len(matches) == 0 {
+		return line, pos, nil
+	}
+	completed := matches[0]
+	if len(matches) == 1 {
+		completed += " "
+		matches = nil
+	} else {
+		for _, m := range matches[1:] {
+			for !strings.HasPrefix(m, completed) {
+				completed = completed[:len(completed)-1]
+			}
+		}
+	}
+	return line[:start] + completed + line[pos:], start + len(completed), matches
+}
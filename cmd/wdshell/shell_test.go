@@ -0,0 +1,118 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tooolbox/webdriver"
+)
+
+const elementKey = "element-6066-11e4-a52e-4f735466cecf"
+
+// a session of a fake driver with a page of two buttons, e1 and e2.
+func fakeSession(t *testing.T, clicked *[]string) *webdriver.Session {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var value interface{}
+		path := strings.TrimPrefix(r.URL.Path, "/session/s1")
+		switch {
+		case r.URL.Path == "/session":
+			value = map[string]interface{}{"sessionId": "s1", "capabilities": map[string]interface{}{"browserName": "fake"}}
+		case path == "/elements":
+			value = []map[string]string{{elementKey: "e1"}, {elementKey: "e2"}}
+		case strings.HasSuffix(path, "/name"):
+			value = "button"
+		case path == "/element/e1/text":
+			value = "Save"
+		case path == "/element/e2/text":
+			value = "Cancel"
+		case strings.HasSuffix(path, "/click"):
+			*clicked = append(*clicked, strings.Split(path, "/")[2])
+		case path == "/execute/sync":
+			value = "Fake page"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": value})
+	}))
+	t.Cleanup(srv.Close)
+	session, err := webdriver.NewRemoteDriver(srv.URL).NewSession(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return session
+}
+
+func TestShell(t *testing.T) {
+	var clicked []string
+	var out bytes.Buffer
+	sh := newShell(fakeSession(t, &clicked), &out)
+	err := script(sh, strings.NewReader(`
+find css button
+click
+click $2
+exec return document.title
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "$1 <button> \"Save\"\n$2 <button> \"Cancel\"\n\"Fake page\"\n"; out.String() != want {
+		t.Errorf("got output %q, want %q", out.String(), want)
+	}
+	if strings.Join(clicked, " ") != "e1 e2" {
+		t.Errorf("clicked %v", clicked)
+	}
+	for _, line := range []string{"text $3", "fly", "find css"} {
+		if err := sh.run(line); err == nil {
+			t.Errorf("%s: no error", line)
+		}
+	}
+	if err := sh.run("exit"); err != errQuit {
+		t.Errorf("exit: got %v", err)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	var clicked []string
+	sh := newShell(fakeSession(t, &clicked), &bytes.Buffer{})
+	if err := sh.run("find css button"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		line, want string
+		candidates int
+	}{
+		{"cl", "cl", 2},
+		{"cli", "click ", 0},
+		{"find x", "find xpath ", 0},
+		{"find p", "find partial ", 0},
+		{"click $", "click $", 2},
+		{"text $2", "text $2 ", 0},
+		{"exec $", "exec $", 2},
+		{"go exam", "go exam", 0},
+	} {
+		line, pos, candidates := sh.complete(c.line, len(c.line))
+		if line != c.want || pos != len(c.want) || len(candidates) != c.candidates {
+			t.Errorf("%q: got %q at %d, candidates %v", c.line, line, pos, candidates)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h := loadHistory(path)
+	for _, line := range []string{"go example.com", "find css a", "find css a", ""} {
+		h.Add(line)
+	}
+	h = loadHistory(path)
+	if h.Len() != 2 || h.At(0) != "find css a" || h.At(1) != "go example.com" {
+		t.Errorf("got history %q", h.lines)
+	}
+}
@@ -5,6 +5,7 @@
 package webdriver
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -13,6 +14,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -109,6 +111,15 @@ func (e CommandError) Error() string {
 	return m
 }
 
+//returns true if err is a CommandError caused by a reference to an element no longer attached to the DOM.
+func isStaleElement(err error) bool {
+	var cerr *CommandError
+	if !errors.As(err, &cerr) {
+		return false
+	}
+	return cerr.StatusCode == StaleElementReference || cerr.ErrorType == "stale element reference"
+}
+
 type jsonResponse struct {
 	RawSessionID string          `json:"sessionId"`
 	Status       int             `json:"status"`
@@ -167,23 +178,183 @@ func newRequest(method, path string, data []byte) (*http.Request, error) {
 }
 
 type WebDriverCore struct {
+	//Trace, if set, records every command sent to the driver.
+	Trace *TraceRecorder
+	//Retry, if set, sends again the commands that fail with a transient error.
+	Retry *RetryPolicy
+	//Observer, if set, is notified of every command sent to the driver.
+	Observer CommandObserver
+	//BiDi, if set, requests the WebDriver BiDi WebSocket of the new sessions (capability webSocketUrl), see Session.DialBiDi.
+	BiDi bool
+
 	url string
+	//the output of the driver process
+	logs *ringBuffer
+	//the sessions created with NewSession and not deleted yet
+	registry *sessionRegistry
+	//replaces the HTTP transport, e.g. to replay a trace
+	transport http.RoundTripper
 }
 
 func (w WebDriverCore) Start() error { return nil }
 func (w WebDriverCore) Stop() error  { return nil }
 
-func (w WebDriverCore) do(params interface{}, method, urlFormat string, urlParams ...interface{}) (string, []byte, error) {
+//Returns the last output of the driver process, up to 1 MiB, kept whether or not it is written to a LogFile.
+func (w WebDriverCore) Logs() []byte { return w.logs.Bytes() }
+
+func (w WebDriverCore) do(ctx context.Context, params interface{}, method, urlFormat string, urlParams ...interface{}) (string, []byte, error) {
 	if method != "GET" && method != "POST" && method != "DELETE" {
 		return "", nil, errors.New("invalid method: " + method)
 	}
-	url := w.url + fmt.Sprintf(urlFormat, urlParams...)
-	sessionID, data, err := w.doInternal(params, method, url)
+	path := fmt.Sprintf(urlFormat, urlParams...)
+	done := w.observe(ctx, method, urlFormat, path)
+	var sessionID string
+	var data []byte
+	var status, attempts int
+	err := w.Retry.retry(ctx, method, path, func(attempt int) error {
+		attempts = attempt + 1
+		var err error
+		status, sessionID, data, err = w.doInternal(ctx, attempt, params, method, w.url+path)
+		return err
+	})
+	done(CommandResult{Status: status, Attempts: attempts, Err: err})
 	return sessionID, data, err
 }
 
-//communicate with the server.
-func (w WebDriverCore) doInternal(params interface{}, method, path string) (string, []byte, error) {
+//communicate with the server, returns the HTTP status code of the response too. attempt is the number of the attempt of the command, from 0.
+func (w WebDriverCore) doInternal(ctx context.Context, attempt int, params interface{}, method, path string) (int, string, []byte, error) {
+	response, err := w.roundTrip(ctx, attempt, params, method, path)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer response.Body.Close()
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := io.Copy(buf, response.Body); err != nil {
+		return response.StatusCode, "", nil, err
+	}
+	debugprint("raw buffer: " + buf.String())
+
+	jr := jsonResponse{}
+	decoder := json.NewDecoder(buf)
+	err = decoder.Decode(&jr)
+
+	if err != nil {
+		debugprint(err)
+		return response.StatusCode, "", nil, errors.New("error: response must be a JSON object")
+	}
+
+	if response.StatusCode >= 400 || jr.Status != 0 {
+		return response.StatusCode, "", nil, parseError(response.StatusCode, jr)
+	}
+
+	if len(jr.RawSessionID) == 0 {
+		jr2 := jsonResponse{}
+		err := json.Unmarshal(jr.RawValue, &jr2)
+
+		if err != nil {
+			debugprint(err)
+			//return "", nil, errors.New("error: response must be a JSON object")
+		}
+		if len(jr2.RawSessionID) > 0 {
+			jr.RawSessionID = jr2.RawSessionID
+		}
+	}
+	debugprint("<< " + jr.RawSessionID + " " + string(jr.RawValue))
+	return response.StatusCode, jr.RawSessionID, jr.RawValue, nil
+}
+
+//send a command whose response value is a string and pass the content of the string, unescaped, to fn.
+//The string is read straight from the response body, so large values (e.g. screenshots) are never held in memory.
+func (w WebDriverCore) doStream(ctx context.Context, fn func(value io.Reader) error, params interface{}, method, urlFormat string, urlParams ...interface{}) error {
+	if method != "GET" && method != "POST" && method != "DELETE" {
+		return errors.New("invalid method: " + method)
+	}
+	path := fmt.Sprintf(urlFormat, urlParams...)
+	done := w.observe(ctx, method, urlFormat, path)
+	var status, attempts int
+	err := w.Retry.retry(ctx, method, path, func(attempt int) error {
+		attempts = attempt + 1
+		streamed := false
+		var err error
+		status, err = w.doStreamOnce(ctx, attempt, func(value io.Reader) error {
+			streamed = true
+			return fn(value)
+		}, params, method, w.url+path)
+		if streamed && err != nil {
+			//fn may have consumed part of the value already
+			return finalError{err}
+		}
+		return err
+	})
+	done(CommandResult{Status: status, Attempts: attempts, Err: err})
+	return err
+}
+
+//send a command and stream its value to fn, returns the HTTP status code of the response.
+func (w WebDriverCore) doStreamOnce(ctx context.Context, attempt int, fn func(value io.Reader) error, params interface{}, method, path string) (int, error) {
+	response, err := w.roundTrip(ctx, attempt, params, method, path)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 400 {
+		jr := jsonResponse{}
+		if err := json.NewDecoder(response.Body).Decode(&jr); err != nil {
+			return response.StatusCode, errors.New("error: response must be a JSON object")
+		}
+		return response.StatusCode, parseError(response.StatusCode, jr)
+	}
+
+	decoder := json.NewDecoder(response.Body)
+	if t, err := decoder.Token(); err != nil || t != json.Delim('{') {
+		return response.StatusCode, errors.New("error: response must be a JSON object")
+	}
+	jr := jsonResponse{}
+	for decoder.More() {
+		t, err := decoder.Token()
+		if err != nil {
+			return response.StatusCode, err
+		}
+		switch t {
+		case "value":
+			//the decoder stopped right after the key, continue by hand from the colon
+			r := bufio.NewReader(io.MultiReader(decoder.Buffered(), response.Body))
+			c, err := skipSpace(r)
+			if err != nil {
+				return response.StatusCode, err
+			}
+			if c != ':' {
+				return response.StatusCode, errors.New("error: malformed JSON response")
+			}
+			if c, err = skipSpace(r); err != nil {
+				return response.StatusCode, err
+			}
+			if c != '"' || jr.Status != 0 {
+				r.UnreadByte()
+				if err := json.NewDecoder(r).Decode(&jr.RawValue); err != nil {
+					return response.StatusCode, err
+				}
+				return response.StatusCode, parseError(response.StatusCode, jr)
+			}
+			debugprint("<< streaming value")
+			return response.StatusCode, fn(&jsonStringReader{r: r})
+		case "status":
+			err = decoder.Decode(&jr.Status)
+		default:
+			err = decoder.Decode(&json.RawMessage{})
+		}
+		if err != nil {
+			return response.StatusCode, err
+		}
+	}
+	return response.StatusCode, errors.New("error: response has no value")
+}
+
+//send a request to the server, following the redirect of POST /session.
+//The caller must close the body of the response.
+func (w WebDriverCore) roundTrip(ctx context.Context, attempt int, params interface{}, method, path string) (*http.Response, error) {
 	debugprint(">> " + method + " " + path)
 	var jsonParams []byte
 	var err error
@@ -193,7 +364,7 @@ func (w WebDriverCore) doInternal(params interface{}, method, path string) (stri
 		}
 		jsonParams, err = json.Marshal(params)
 		if err != nil {
-			return "", nil, err
+			return nil, err
 		}
 	}
 	debugprint(">> " + string(jsonParams))
@@ -205,73 +376,159 @@ func (w WebDriverCore) doInternal(params interface{}, method, path string) (stri
 			// Proxy:             http.ProxyURL(proxyUrl),
 			DisableKeepAlives: true,
 		},
+		//the redirect of POST /session is followed below
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if via[0].Method == "POST" {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+	if w.transport != nil {
+		client.Transport = w.transport
 	}
 
 	request, err := newRequest(method, path, jsonParams)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(request.Context(), 60*time.Second)
-	defer cancel()
-
-	request = request.WithContext(ctx)
+	//the timeout of this request only, the redirect gets its own
+	reqCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	request = request.WithContext(reqCtx)
 
+	start := time.Now()
 	response, err := client.Do(request)
+	if w.Trace != nil {
+		w.Trace.trace(method, strings.TrimPrefix(path, w.url), jsonParams, attempt, start, response, err)
+	}
 	if err != nil {
-		return "", nil, err
+		cancel()
+		return nil, err
 	}
-	defer response.Body.Close()
+	response.Body = cancelBody{response.Body, cancel}
 
 	debugprint("StatusCode: " + strconv.Itoa(response.StatusCode))
 	//http.Client doesn't follow POST redirected (/session command)
 	if method == "POST" && isRedirect(response) {
 		debugprint("redirected")
+		response.Body.Close()
 		url, err := response.Location()
 		if err != nil {
-			return "", nil, err
+			return nil, err
 		}
-		return w.doInternal(nil, "GET", url.String())
+		return w.roundTrip(ctx, attempt, nil, "GET", url.String())
 	}
+	return response, nil
+}
 
-	buf := bytes.NewBuffer(nil)
-	if _, err := io.Copy(buf, response.Body); err != nil {
-		return "", nil, err
-	}
-	debugprint("raw buffer: " + buf.String())
-
-	jr := jsonResponse{}
-	decoder := json.NewDecoder(buf)
-	err = decoder.Decode(&jr)
+//a response body that releases the request context when closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
 
-	if err != nil {
-		debugprint(err)
-		return "", nil, errors.New("error: response must be a JSON object")
-	}
+func (b cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
 
-	if response.StatusCode >= 400 || jr.Status != 0 {
-		return "", nil, parseError(response.StatusCode, jr)
+//returns the first byte in r that is not JSON white space.
+func skipSpace(r *bufio.Reader) (byte, error) {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			return c, nil
+		}
 	}
+}
 
-	if len(jr.RawSessionID) == 0 {
-		jr2 := jsonResponse{}
-		err := json.Unmarshal(jr.RawValue, &jr2)
+//jsonStringReader reads the content of a JSON string, whose opening quote has
+//already been consumed, and resolves escape sequences. It returns io.EOF at
+//the closing quote.
+type jsonStringReader struct {
+	r    *bufio.Reader
+	done bool
+	buf  []byte
+}
 
+func (j *jsonStringReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(j.buf) > 0 {
+			c := copy(p[n:], j.buf)
+			j.buf = j.buf[c:]
+			n += c
+			continue
+		}
+		if j.done {
+			break
+		}
+		c, err := j.r.ReadByte()
+		if err == io.EOF {
+			return n, io.ErrUnexpectedEOF
+		}
 		if err != nil {
-			debugprint(err)
-			//return "", nil, errors.New("error: response must be a JSON object")
+			return n, err
 		}
-		if len(jr2.RawSessionID) > 0 {
-			jr.RawSessionID = jr2.RawSessionID
+		switch c {
+		case '"':
+			j.done = true
+		case '\\':
+			if j.buf, err = j.unescape(); err != nil {
+				return n, err
+			}
+		default:
+			p[n] = c
+			n++
 		}
 	}
-	debugprint("<< " + jr.RawSessionID + " " + string(jr.RawValue))
-	return jr.RawSessionID, jr.RawValue, nil
+	if n == 0 && j.done {
+		return 0, io.EOF
+	}
+	return n, nil
+}
+
+//decode the escape sequence following a backslash.
+func (j *jsonStringReader) unescape() ([]byte, error) {
+	c, err := j.r.ReadByte()
+	if err != nil {
+		return nil, io.ErrUnexpectedEOF
+	}
+	switch c {
+	case '"', '\\', '/':
+		return []byte{c}, nil
+	case 'b':
+		return []byte{'\b'}, nil
+	case 'f':
+		return []byte{'\f'}, nil
+	case 'n':
+		return []byte{'\n'}, nil
+	case 'r':
+		return []byte{'\r'}, nil
+	case 't':
+		return []byte{'\t'}, nil
+	case 'u':
+		hex := make([]byte, 4)
+		if _, err := io.ReadFull(j.r, hex); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		var s string
+		if err := json.Unmarshal([]byte(`"\\u`+string(hex)+`"`), &s); err != nil {
+			return nil, err
+		}
+		return []byte(s), nil
+	}
+	return nil, fmt.Errorf("invalid escape sequence \\%c in JSON string", c)
 }
 
 //Query the server's status.
 func (w WebDriverCore) Status() (*Status, error) {
-	_, data, err := w.do(nil, "GET", "/status")
+	_, data, err := w.do(context.Background(), nil, "GET", "/status")
 	if err != nil {
 		return nil, err
 	}
@@ -286,24 +543,107 @@ func (w WebDriverCore) newSession(desired, required Capabilities) (*Session, err
 	if desired == nil {
 		desired = map[string]interface{}{}
 	}
-	p := params{"desiredCapabilities": desired, "requiredCapabilities": required, "capabilities": desired}
-	sessionId, data, err := w.do(p, "POST", "/session")
+	p := params{"desiredCapabilities": desired, "requiredCapabilities": required, "capabilities": w3cCapabilities(desired, required, w.BiDi)}
+	sessionId, data, err := w.do(context.Background(), p, "POST", "/session")
 	if err != nil {
 		return nil, err
 	}
 	var capabilities Capabilities
 	err = json.Unmarshal(data, &capabilities)
-	return &Session{Id: sessionId, Capabilities: capabilities}, err
+	session := &Session{Id: sessionId, Capabilities: capabilities}
+	if err == nil {
+		w.registry.add(session)
+	}
+	return session, err
+}
+
+//the capabilities that W3C drivers accept without complaining.
+var w3cCapabilityNames = map[string]bool{
+	"browserName":               true,
+	"browserVersion":            true,
+	"platformName":              true,
+	"acceptInsecureCerts":       true,
+	"pageLoadStrategy":          true,
+	"proxy":                     true,
+	"setWindowRect":             true,
+	"timeouts":                  true,
+	"strictFileInteractability": true,
+	"unhandledPromptBehavior":   true,
+	"webSocketUrl":              true,
+}
+
+//build the W3C "capabilities" parameter of new session from the desired and required capabilities.
+//Capabilities already in W3C form (alwaysMatch/firstMatch) are sent as they are, otherwise standard and extension ("vendor:name") capabilities are sent as alwaysMatch and the legacy ones are left out.
+//The required capabilities are added to alwaysMatch, overriding the desired ones, and so is webSocketUrl if bidi is true.
+func w3cCapabilities(desired, required Capabilities, bidi bool) Capabilities {
+	_, always := desired["alwaysMatch"]
+	_, first := desired["firstMatch"]
+	if (always || first) && len(required) == 0 && !bidi {
+		return desired
+	}
+	w3c := Capabilities{}
+	alwaysMatch := Capabilities{}
+	if always || first {
+		for k, v := range desired {
+			w3c[k] = v
+		}
+		if m, ok := desired["alwaysMatch"].(map[string]interface{}); ok {
+			for k, v := range m {
+				alwaysMatch[k] = v
+			}
+		} else if m, ok := desired["alwaysMatch"].(Capabilities); ok {
+			for k, v := range m {
+				alwaysMatch[k] = v
+			}
+		}
+	} else {
+		for k, v := range desired {
+			if w3cCapabilityNames[k] || strings.Contains(k, ":") {
+				alwaysMatch[k] = v
+			}
+		}
+	}
+	for k, v := range required {
+		if w3cCapabilityNames[k] || strings.Contains(k, ":") {
+			alwaysMatch[k] = v
+		}
+	}
+	if _, ok := alwaysMatch["webSocketUrl"]; bidi && !ok {
+		alwaysMatch["webSocketUrl"] = true
+	}
+	w3c["alwaysMatch"] = alwaysMatch
+	return w3c
 }
 
 //Returns a list of the currently active sessions.
-func (w WebDriverCore) sessions() ([]Session, error) {
-	_, data, err := w.do(nil, "GET", "/sessions")
+//W3C drivers don't support GET /sessions, the sessions created with NewSession are returned instead.
+func (w WebDriverCore) sessions() ([]*Session, error) {
+	_, data, err := w.do(context.Background(), nil, "GET", "/sessions")
+	//W3C drivers have no command to list the sessions
+	if isUnknownCommand(err) && w.registry != nil {
+		return w.registry.list(), nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	var sessions []Session
+	var sessions []*Session
 	err = json.Unmarshal(data, &sessions)
 	return sessions, err
 	//return nil, errors.New("unsupported")
 }
+
+//Delete all the sessions created with NewSession and not deleted yet. All the sessions are deleted even if some fail, the first error is returned.
+func (w WebDriverCore) DeleteAllSessions() error {
+	var first error
+	for _, s := range w.registry.list() {
+		if err := s.Delete(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+//remove a deleted session from the registry.
+func (w WebDriverCore) forget(id string) {
+	w.registry.remove(id)
+}
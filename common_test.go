@@ -0,0 +1,73 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestNewSessionRedirect(t *testing.T) {
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/session":
+			http.Redirect(w, r, "/session/r1", http.StatusSeeOther)
+		case r.Method == "GET" && r.URL.Path == "/session/r1":
+			writeValue(w, map[string]interface{}{"sessionId": "r1", "capabilities": map[string]interface{}{"browserName": "fake"}})
+		default:
+			writeError(w, 404, "unknown command")
+		}
+	})
+	s, err := d.NewSession(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Id != "r1" {
+		t.Errorf("got session %q, want r1", s.Id)
+	}
+}
+
+func TestNewSessionCapabilities(t *testing.T) {
+	var sent struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		sent.Capabilities = nil
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Error(err)
+		}
+		writeValue(w, map[string]interface{}{"sessionId": "s1", "capabilities": map[string]interface{}{}})
+	})
+	d.BiDi = true
+	desired := Capabilities{"browserName": "firefox", "javascriptEnabled": true, "acceptInsecureCerts": false}
+	required := Capabilities{"acceptInsecureCerts": true, "moz:firefoxOptions": map[string]interface{}{}}
+	if _, err := d.NewSession(desired, required); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"alwaysMatch": map[string]interface{}{
+		"browserName":         "firefox",
+		"acceptInsecureCerts": true,
+		"moz:firefoxOptions":  map[string]interface{}{},
+		"webSocketUrl":        true,
+	}}
+	if !reflect.DeepEqual(sent.Capabilities, want) {
+		t.Errorf("sent %v, want %v", sent.Capabilities, want)
+	}
+
+	//capabilities in W3C form are kept, required ones are added
+	desired = Capabilities{"alwaysMatch": map[string]interface{}{"browserName": "chrome"}, "firstMatch": []interface{}{}}
+	if _, err := d.NewSession(desired, Capabilities{"platformName": "linux"}); err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]interface{}{
+		"alwaysMatch": map[string]interface{}{"browserName": "chrome", "platformName": "linux", "webSocketUrl": true},
+		"firstMatch":  []interface{}{},
+	}
+	if !reflect.DeepEqual(sent.Capabilities, want) {
+		t.Errorf("sent %v, want %v", sent.Capabilities, want)
+	}
+}
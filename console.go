@@ -0,0 +1,220 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConsoleEntry is a message logged by the page: a console call or an
+// uncaught JavaScript exception.
+type ConsoleEntry struct {
+	Time    time.Time
+	Level   LogLevel
+	Message string
+	// Exception is true for uncaught JavaScript errors.
+	Exception bool
+}
+
+func (e ConsoleEntry) String() string {
+	return fmt.Sprintf("%s %s %s", e.Time.Format("15:04:05.000"), e.Level, e.Message)
+}
+
+// ConsoleOptions configures a ConsoleWatcher.
+type ConsoleOptions struct {
+	// PollInterval is the period Log("browser") is polled with when the session
+	// has no BiDi connection. Default: 500ms.
+	PollInterval time.Duration
+	// OnEntry, if set, is called with every entry as it is collected, from
+	// the goroutine of the watcher.
+	OnEntry func(e ConsoleEntry)
+	// Fail, if set, is called for every uncaught JavaScript exception,
+	// e.g. t.Error to fail the running test. It is called from the goroutine
+	// of the watcher, not the one of the test, so it must not be t.Fatal or
+	// t.FailNow: call Check from the test to stop it at an exception.
+	Fail func(args ...interface{})
+}
+
+// ConsoleWatcher collects the console messages and the uncaught exceptions of
+// a page in the background. It is safe for concurrent use.
+type ConsoleWatcher struct {
+	opts ConsoleOptions
+
+	mu      sync.Mutex
+	entries []ConsoleEntry
+	checked int // entries already inspected by Check
+
+	stop     func() error
+	stopOnce sync.Once
+	stopErr  error
+}
+
+// WatchConsole starts collecting the console of the session. It listens to
+// log.entryAdded events if the session has a webSocketUrl (see DialBiDi) and
+// polls Log("browser") otherwise.
+func (s *Session) WatchConsole(opts ConsoleOptions) (*ConsoleWatcher, error) {
+	if url, _ := s.capability("webSocketUrl").(string); url != "" {
+		b, err := s.DialBiDi()
+		if err != nil {
+			return nil, err
+		}
+		w, err := b.WatchConsole(opts)
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+		stop := w.stop
+		w.stop = func() error {
+			err := stop()
+			b.Close()
+			return err
+		}
+		return w, nil
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = 500 * time.Millisecond
+	}
+	w := &ConsoleWatcher{opts: opts}
+	poll := func() error {
+		entries, err := s.Log("browser")
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			w.add(browserLogEntry(e))
+		}
+		return nil
+	}
+	if err := poll(); err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(opts.PollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := poll(); err != nil {
+					debugprint(err)
+				}
+			}
+		}
+	}()
+	w.stop = func() error {
+		close(done)
+		<-stopped
+		return poll()
+	}
+	return w, nil
+}
+
+// WatchConsole starts collecting log.entryAdded events over an open BiDi connection.
+func (b *BiDiConn) WatchConsole(opts ConsoleOptions) (*ConsoleWatcher, error) {
+	w := &ConsoleWatcher{opts: opts}
+	remove := b.OnLogEntry(func(e BiDiLogEntry) {
+		w.add(bidiLogEntry(e))
+	})
+	if err := b.Subscribe("log.entryAdded"); err != nil {
+		remove()
+		return nil, err
+	}
+	w.stop = func() error {
+		remove()
+		return b.Unsubscribe("log.entryAdded")
+	}
+	return w, nil
+}
+
+func (w *ConsoleWatcher) add(e ConsoleEntry) {
+	w.mu.Lock()
+	w.entries = append(w.entries, e)
+	w.mu.Unlock()
+	if w.opts.OnEntry != nil {
+		w.opts.OnEntry(e)
+	}
+	if e.Exception && w.opts.Fail != nil {
+		w.opts.Fail("JavaScript error: " + e.Message)
+	}
+}
+
+// Entries returns all the entries collected so far.
+func (w *ConsoleWatcher) Entries() []ConsoleEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]ConsoleEntry(nil), w.entries...)
+}
+
+// Exceptions returns the uncaught JavaScript exceptions collected so far.
+func (w *ConsoleWatcher) Exceptions() []ConsoleEntry {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var exceptions []ConsoleEntry
+	for _, e := range w.entries {
+		if e.Exception {
+			exceptions = append(exceptions, e)
+		}
+	}
+	return exceptions
+}
+
+// Check returns an error if the page threw an uncaught exception since the
+// previous Check, so that a test step can fail on JavaScript errors.
+// Entries still in flight in the browser may be collected later.
+func (w *ConsoleWatcher) Check() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var messages []string
+	for _, e := range w.entries[w.checked:] {
+		if e.Exception {
+			messages = append(messages, e.Message)
+		}
+	}
+	w.checked = len(w.entries)
+	if len(messages) == 0 {
+		return nil
+	}
+	return errors.New("JavaScript error: " + strings.Join(messages, "; "))
+}
+
+// Stop collecting. Entries remain available. Calling Stop again returns the
+// error of the first call.
+func (w *ConsoleWatcher) Stop() error {
+	w.stopOnce.Do(func() { w.stopErr = w.stop() })
+	return w.stopErr
+}
+
+// convert an entry of the legacy log, whose level names are those of Selenium.
+func browserLogEntry(e LogEntry) ConsoleEntry {
+	level := LogLevel(strings.ToUpper(e.Level))
+	exception := e.Source == "javascript" && level == LogSevere
+	if e.Source == "" {
+		//drivers that don't report the source
+		exception = level == LogSevere && strings.Contains(e.Message, "Uncaught")
+	}
+	return ConsoleEntry{Time: e.Time(), Level: level, Message: e.Message, Exception: exception}
+}
+
+// convert a BiDi log entry, whose levels are those of the console API.
+func bidiLogEntry(e BiDiLogEntry) ConsoleEntry {
+	level := LogInfo
+	switch e.Level {
+	case "debug":
+		level = LogDebug
+	case "warn":
+		level = LogWarning
+	case "error":
+		level = LogSevere
+	}
+	return ConsoleEntry{Time: e.Timestamp.Time(), Level: level, Message: e.Text, Exception: e.Type == "javascript"}
+}
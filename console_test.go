@@ -0,0 +1,79 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConsoleWatcherPolling(t *testing.T) {
+	var mu sync.Mutex
+	calls := 0
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		switch calls {
+		case 1:
+			writeValue(w, []map[string]interface{}{
+				{"level": "INFO", "message": "console-api 1:1 \"hello\"", "source": "console-api", "timestamp": 1700000000000},
+			})
+		case 2:
+			writeValue(w, []map[string]interface{}{
+				{"level": "SEVERE", "message": "app.js 3:7 Uncaught Error: boom", "source": "javascript", "timestamp": 1700000001000},
+			})
+		default:
+			writeValue(w, []interface{}{})
+		}
+	})
+	var failures []string
+	var fmu sync.Mutex
+	watcher, err := fakeSession(d).WatchConsole(ConsoleOptions{
+		PollInterval: 10 * time.Millisecond,
+		Fail: func(args ...interface{}) {
+			fmu.Lock()
+			defer fmu.Unlock()
+			failures = append(failures, args[0].(string))
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.Check(); err != nil {
+		t.Fatalf("unexpected error before the exception: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(watcher.Exceptions()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := watcher.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.Stop(); err != nil {
+		t.Errorf("second Stop: %v", err)
+	}
+	entries := watcher.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	if entries[0].Level != LogInfo || entries[0].Exception || entries[0].Time.Unix() != 1700000000 {
+		t.Errorf("wrong first entry: %+v", entries[0])
+	}
+	if !entries[1].Exception || entries[1].Level != LogSevere {
+		t.Errorf("exception not detected: %+v", entries[1])
+	}
+	if err := watcher.Check(); err == nil {
+		t.Error("Check didn't report the exception")
+	}
+	if err := watcher.Check(); err != nil {
+		t.Errorf("Check reported the exception twice: %v", err)
+	}
+	if len(failures) != 1 {
+		t.Errorf("Fail called %d times, want 1", len(failures))
+	}
+}
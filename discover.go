@@ -0,0 +1,365 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DriverInfo describes a driver executable found by FindDriver and the
+// browser it drives.
+type DriverInfo struct {
+	// Browser is the name of the browser: "chrome", "firefox", "msedge" or
+	// "safari".
+	Browser string
+	// Path is the path of the driver executable.
+	Path    string
+	Version Version
+	// BrowserPath is the path of the browser executable (or application
+	// bundle), empty if the browser wasn't found.
+	BrowserPath    string
+	BrowserVersion Version
+}
+
+// Check returns a *VersionMismatchError if the driver doesn't support the
+// version of the browser. It returns nil if either version is unknown.
+func (i DriverInfo) Check() error {
+	if i.Version.IsZero() || i.BrowserVersion.IsZero() {
+		return nil
+	}
+	ok := true
+	switch i.Browser {
+	case "chrome", "msedge", "safari":
+		//the driver is released with the browser
+		ok = i.Version.Major == i.BrowserVersion.Major
+	case "firefox":
+		ok = i.BrowserVersion.Compare(minFirefox(i.Version)) >= 0
+	}
+	if !ok {
+		return &VersionMismatchError{i}
+	}
+	return nil
+}
+
+// VersionMismatchError is returned by FindDriver when the version of the
+// driver doesn't support the version of the browser.
+type VersionMismatchError struct {
+	DriverInfo
+}
+
+func (e *VersionMismatchError) Error() string {
+	want := fmt.Sprintf("version %d", e.BrowserVersion.Major)
+	if e.Browser == "firefox" {
+		want = fmt.Sprintf("version %s or later", minFirefox(e.Version))
+	}
+	return fmt.Sprintf("%s %s (%s) doesn't support %s %s (%s), %s of the browser is needed",
+		driverNames[e.Browser], e.Version.Raw, e.Path, e.Browser, e.BrowserVersion.Raw, e.BrowserPath, want)
+}
+
+// the first version of Firefox supported by each version of geckodriver,
+// latest first, from https://firefox-source-docs.mozilla.org/testing/geckodriver/Support.html
+var geckodriverSupport = []struct {
+	geckodriver, firefox string
+}{
+	{"0.34", "115"},
+	{"0.33", "102"},
+	{"0.31", "91"},
+	{"0.30", "78"},
+	{"0.26", "60"},
+	{"0.21", "57"},
+	{"0.19", "55"},
+	{"0.18", "53"},
+	{"0.17", "52"},
+}
+
+// returns the first version of Firefox supported by geckodriver.
+func minFirefox(geckodriver Version) Version {
+	for _, s := range geckodriverSupport {
+		v, _ := ParseVersion(s.geckodriver)
+		if geckodriver.Compare(v) >= 0 {
+			firefox, _ := ParseVersion(s.firefox)
+			return firefox
+		}
+	}
+	return Version{}
+}
+
+// the executables of the drivers, by browser
+var driverNames = map[string]string{
+	"chrome":  "chromedriver",
+	"firefox": "geckodriver",
+	"msedge":  "msedgedriver",
+	"safari":  "safaridriver",
+}
+
+// the directories where drivers are usually installed, other than PATH
+var driverDirs = map[string][]string{
+	"linux": {
+		"/usr/local/bin",
+		"/usr/bin",
+		"/usr/lib/chromium",
+		"/usr/lib/chromium-browser",
+		"/snap/bin",
+	},
+	"darwin": {
+		"/usr/local/bin",
+		"/opt/homebrew/bin",
+		"/usr/bin",
+	},
+	"windows": {
+		`%ProgramFiles%\Microsoft\Edge\Application`,
+		`%ProgramFiles(x86)%\Microsoft\Edge\Application`,
+	},
+}
+
+// the executables of the browsers in PATH and their usual locations, by browser and OS
+var browserPaths = map[string]map[string][]string{
+	"chrome": {
+		"linux":   {"google-chrome", "google-chrome-stable", "chromium", "chromium-browser", "/opt/google/chrome/chrome"},
+		"darwin":  {"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome"},
+		"windows": {`%ProgramFiles%\Google\Chrome\Application\chrome.exe`, `%ProgramFiles(x86)%\Google\Chrome\Application\chrome.exe`, `%LocalAppData%\Google\Chrome\Application\chrome.exe`},
+	},
+	"firefox": {
+		"linux":   {"firefox", "/usr/lib/firefox/firefox"},
+		"darwin":  {"/Applications/Firefox.app/Contents/MacOS/firefox"},
+		"windows": {`%ProgramFiles%\Mozilla Firefox\firefox.exe`, `%ProgramFiles(x86)%\Mozilla Firefox\firefox.exe`},
+	},
+	"msedge": {
+		"linux":   {"microsoft-edge", "microsoft-edge-stable", "/opt/microsoft/msedge/msedge"},
+		"darwin":  {"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge"},
+		"windows": {`%ProgramFiles(x86)%\Microsoft\Edge\Application\msedge.exe`, `%ProgramFiles%\Microsoft\Edge\Application\msedge.exe`},
+	},
+	"safari": {
+		"darwin": {"/Applications/Safari.app"},
+	},
+}
+
+// DiscoverOptions configures FindDriver.
+type DiscoverOptions struct {
+	// Dirs are searched for the driver before PATH.
+	Dirs []string
+	// CacheDir is searched for the driver after PATH and the usual install
+	// locations. It holds a directory for each version of each driver, e.g.
+	// "chromedriver/120.0.6099.109/chromedriver"; the version that matches the
+	// browser is preferred. Default: $WEBDRIVER_CACHE, or "webdriver" in the
+	// user cache directory.
+	CacheDir string
+	// BrowserPath is the browser executable. Default: the browser is looked
+	// for in PATH and in its usual install locations.
+	BrowserPath string
+}
+
+// FindDriver looks for the driver of browser ("chrome", "firefox", "msedge"
+// or "safari") in opts.Dirs, PATH, the usual install locations and the cache
+// directory, and for the browser itself. It starts neither: versions are read
+// with --version, or from the application bundle, with no network access.
+//
+// If the versions of the first driver found don't match, the others are
+// tried; if none matches, FindDriver returns the first with a
+// *VersionMismatchError, e.g. for chromedriver 114 and Chrome 120.
+//
+//	info, err := webdriver.FindDriver("chrome", webdriver.DiscoverOptions{})
+//	if err != nil {
+//		return err
+//	}
+//	driver := webdriver.NewChromeDriver(info.Path)
+func FindDriver(browser string, opts DiscoverOptions) (DriverInfo, error) {
+	if browser == "edge" || browser == "microsoftedge" {
+		browser = "msedge"
+	}
+	name, ok := driverNames[browser]
+	if !ok {
+		return DriverInfo{}, fmt.Errorf("find driver: unknown browser %q", browser)
+	}
+	info := DriverInfo{Browser: browser}
+	info.BrowserPath, info.BrowserVersion = findBrowser(browser, opts.BrowserPath)
+
+	candidates := driverCandidates(name, opts)
+	if len(candidates) == 0 {
+		return info, fmt.Errorf("find driver: %s not found in PATH, in the usual locations or in the cache", name)
+	}
+	var first DriverInfo
+	var firstErr error
+	for i, path := range candidates {
+		candidate := info
+		candidate.Path = path
+		out, err := runVersion(path)
+		if err != nil {
+			if i == 0 {
+				first, firstErr = candidate, fmt.Errorf("find driver: %s --version: %w", path, err)
+			}
+			continue
+		}
+		candidate.Version = parseVersionOutput(out)
+		err = candidate.Check()
+		if err == nil {
+			return candidate, nil
+		}
+		if firstErr == nil {
+			first, firstErr = candidate, err
+		}
+	}
+	return first, firstErr
+}
+
+// returns the paths of the executables of the driver name, in search order.
+func driverCandidates(name string, opts DiscoverOptions) []string {
+	exe := name
+	if runtime.GOOS == "windows" {
+		exe += ".exe"
+	}
+	var paths []string
+	seen := map[string]bool{}
+	add := func(path string) {
+		if abs, err := filepath.Abs(path); err == nil && !seen[abs] && isExecutable(abs) {
+			seen[abs] = true
+			paths = append(paths, abs)
+		}
+	}
+	for _, dir := range opts.Dirs {
+		add(filepath.Join(dir, exe))
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		if dir != "" {
+			add(filepath.Join(dir, exe))
+		}
+	}
+	for _, dir := range driverDirs[runtime.GOOS] {
+		add(filepath.Join(os.ExpandEnv(windowsEnv(dir)), exe))
+	}
+
+	cacheDir := opts.CacheDir
+	if cacheDir == "" {
+		cacheDir = os.Getenv("WEBDRIVER_CACHE")
+	}
+	if cacheDir == "" {
+		if dir, err := os.UserCacheDir(); err == nil {
+			cacheDir = filepath.Join(dir, "webdriver")
+		}
+	}
+	if cacheDir != "" {
+		//latest version first
+		versions, _ := ioutil.ReadDir(filepath.Join(cacheDir, name))
+		sort.Slice(versions, func(i, j int) bool {
+			a, _ := ParseVersion(versions[i].Name())
+			b, _ := ParseVersion(versions[j].Name())
+			return a.Compare(b) > 0
+		})
+		for _, v := range versions {
+			add(filepath.Join(cacheDir, name, v.Name(), exe))
+		}
+	}
+	return paths
+}
+
+// returns the path and the version of browser, if found.
+func findBrowser(browser, path string) (string, Version) {
+	paths := browserPaths[browser][runtime.GOOS]
+	if path != "" {
+		paths = []string{path}
+	}
+	for _, p := range paths {
+		p = os.ExpandEnv(windowsEnv(p))
+		if !filepath.IsAbs(p) {
+			var err error
+			if p, err = exec.LookPath(p); err != nil {
+				continue
+			}
+		}
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		return p, browserVersion(p)
+	}
+	return "", Version{}
+}
+
+// returns the version of the browser executable, or application bundle, path.
+func browserVersion(path string) Version {
+	if strings.HasSuffix(path, ".app") {
+		//the version of a macOS application is in its Info.plist
+		data, err := ioutil.ReadFile(filepath.Join(path, "Contents", "Info.plist"))
+		if err != nil {
+			return Version{}
+		}
+		m := bundleVersion.FindSubmatch(data)
+		if m == nil {
+			return Version{}
+		}
+		v, _ := ParseVersion(string(m[1]))
+		return v
+	}
+	if runtime.GOOS == "windows" {
+		//browsers don't print their version on Windows, it is the name of a
+		//directory next to the executable, e.g. Application\120.0.6099.109
+		entries, _ := ioutil.ReadDir(filepath.Dir(path))
+		var latest Version
+		for _, e := range entries {
+			if v, err := ParseVersion(e.Name()); e.IsDir() && err == nil && v.Compare(latest) > 0 {
+				latest = v
+			}
+		}
+		return latest
+	}
+	out, err := runVersion(path)
+	if err != nil {
+		return Version{}
+	}
+	return parseVersionOutput(out)
+}
+
+var (
+	versionNumber = regexp.MustCompile(`\d+(\.\d+)+`)
+	bundleVersion = regexp.MustCompile(`<key>CFBundleShortVersionString</key>\s*<string>([^<]+)</string>`)
+)
+
+// returns the first version in the output of --version, e.g. "ChromeDriver
+// 120.0.6099.109 (3419140...)" or "Included with Safari 17.2 (19617.1.17)".
+func parseVersionOutput(out string) Version {
+	v, _ := ParseVersion(versionNumber.FindString(out))
+	return v
+}
+
+// runs path --version and returns its output.
+func runVersion(path string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// converts the %VAR% references of Windows paths to $VAR, for os.ExpandEnv.
+func windowsEnv(path string) string {
+	return windowsVar.ReplaceAllString(path, "$${$1}")
+}
+
+var windowsVar = regexp.MustCompile(`%([^%]+)%`)
+
+// returns true if path is a regular file that can be executed.
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || info.Mode()&0111 != 0
+}
@@ -0,0 +1,95 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writes an executable that prints version.
+func fakeExecutable(t *testing.T, path, version string) {
+	writeScript(t, path, "#!/bin/sh\necho '"+version+"'\n")
+}
+
+// writes the executable script path.
+func writeScript(t *testing.T, path, script string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindDriver(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake drivers are shell scripts")
+	}
+	dirs := driverDirs
+	driverDirs = nil
+	defer func() { driverDirs = dirs }()
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", t.TempDir())
+
+	tmp := t.TempDir()
+	chrome := filepath.Join(tmp, "chrome")
+	fakeExecutable(t, chrome, "Google Chrome 120.0.6099.109")
+	local := filepath.Join(tmp, "bin")
+	fakeExecutable(t, filepath.Join(local, "chromedriver"), "ChromeDriver 114.0.5735.90 (386bc09e8f4f2e025eddae123f36f6263096ae49)")
+	opts := DiscoverOptions{Dirs: []string{local}, CacheDir: filepath.Join(tmp, "cache"), BrowserPath: chrome}
+
+	_, err := FindDriver("chrome", opts)
+	var mismatch *VersionMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("got %v, want a version mismatch", err)
+	}
+	if mismatch.Version.Major != 114 || mismatch.BrowserVersion.Major != 120 || !strings.Contains(err.Error(), "chromedriver 114.0.5735.90") {
+		t.Errorf("wrong mismatch: %v", err)
+	}
+
+	//the driver of the cache that matches the browser is found
+	for _, v := range []string{"119.0.6045.105", "120.0.6099.109"} {
+		fakeExecutable(t, filepath.Join(tmp, "cache", "chromedriver", v, "chromedriver"), "ChromeDriver "+v)
+	}
+	info, err := FindDriver("chrome", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(tmp, "cache", "chromedriver", "120.0.6099.109", "chromedriver"); info.Path != want {
+		t.Errorf("found %s, want %s", info.Path, want)
+	}
+
+	if _, err := FindDriver("firefox", opts); err == nil || errors.As(err, &mismatch) {
+		t.Errorf("missing geckodriver: got %v", err)
+	}
+}
+
+func TestDriverInfoCheck(t *testing.T) {
+	version := func(s string) Version {
+		v, _ := ParseVersion(s)
+		return v
+	}
+	for _, c := range []struct {
+		info DriverInfo
+		ok   bool
+	}{
+		{DriverInfo{Browser: "firefox", Version: version("0.34.0"), BrowserVersion: version("121.0")}, true},
+		{DriverInfo{Browser: "firefox", Version: version("0.34.0"), BrowserVersion: version("102.15.0")}, false},
+		{DriverInfo{Browser: "firefox", Version: version("0.30.0"), BrowserVersion: version("91.0")}, true},
+		{DriverInfo{Browser: "msedge", Version: version("120.0.2210.91"), BrowserVersion: version("121.0.2277.83")}, false},
+		{DriverInfo{Browser: "safari", Version: version("17.2"), BrowserVersion: version("17.2.1")}, true},
+		{DriverInfo{Browser: "chrome", Version: version("120.0.6099.109")}, true},
+	} {
+		if err := c.info.Check(); (err == nil) != c.ok {
+			t.Errorf("%+v: got %v", c.info, err)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Display is a virtual X display, for headed browsers on Linux hosts with no
+// display, e.g. CI runners. Set it on a driver to start it with the driver,
+// and stop it with the driver:
+//
+//	driver := webdriver.NewChromeDriver("chromedriver")
+//	driver.Display = webdriver.NewDisplay()
+//
+// A display started by hand can be shared by several drivers, it is not
+// stopped with them.
+type Display struct {
+	// Command is the X server, which must support the -displayfd option, as
+	// Xvfb, Xvnc and Xephyr do. Default: "Xvfb".
+	Command string
+	// Width, Height and Depth of the screen. Default: 1920x1080x24.
+	Width, Height, Depth int
+	// Args are extra arguments of the server.
+	Args []string
+	// Start fails if the server isn't ready in less than StartTimeout. Default: 10s.
+	StartTimeout time.Duration
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	number int
+	exited chan struct{}
+}
+
+// NewDisplay returns a display with the default settings.
+func NewDisplay() *Display {
+	return &Display{
+		Command:      "Xvfb",
+		Width:        1920,
+		Height:       1080,
+		Depth:        24,
+		StartTimeout: 10 * time.Second,
+	}
+}
+
+// Start starts the X server on a free display number, chosen by the server.
+func (x *Display) Start() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	dserr := "display start failed: "
+	if x.cmd != nil {
+		return errors.New(dserr + "display already running")
+	}
+	command := x.Command
+	if command == "" {
+		command = "Xvfb"
+	}
+	width, height, depth := x.Width, x.Height, x.Depth
+	if width <= 0 || height <= 0 {
+		width, height = 1920, 1080
+	}
+	if depth <= 0 {
+		depth = 24
+	}
+	timeout := x.StartTimeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	//the server writes the number of the display to fd 3 when it is ready
+	r, w, err := os.Pipe()
+	if err != nil {
+		return errors.New(dserr + err.Error())
+	}
+	defer r.Close()
+	args := []string{
+		"-displayfd", "3",
+		"-screen", "0", fmt.Sprintf("%dx%dx%d", width, height, depth),
+		"-nolisten", "tcp",
+	}
+	cmd := exec.Command(command, append(args, x.Args...)...)
+	cmd.ExtraFiles = []*os.File{w}
+	if err := cmd.Start(); err != nil {
+		w.Close()
+		return errors.New(dserr + err.Error())
+	}
+	w.Close()
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	ready := make(chan string, 1)
+	go func() {
+		line, _ := bufio.NewReader(r).ReadString('\n')
+		ready <- strings.TrimSpace(line)
+	}()
+	select {
+	case line := <-ready:
+		number, err := strconv.Atoi(line)
+		if err != nil {
+			cmd.Process.Kill()
+			return fmt.Errorf(dserr+"%s exited without a display number: %q", command, line)
+		}
+		x.cmd, x.number, x.exited = cmd, number, exited
+		debugprint("display " + x.name() + " started")
+		return nil
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		return errors.New(dserr + command + " not ready after " + timeout.String())
+	}
+}
+
+// Stop stops the X server.
+func (x *Display) Stop() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.cmd == nil {
+		return errors.New("stop failed: display not running")
+	}
+	cmd, exited := x.cmd, x.exited
+	x.cmd = nil
+	cmd.Process.Signal(os.Interrupt)
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		cmd.Process.Kill()
+		<-exited
+	}
+	return nil
+}
+
+// Running returns true if the display is started.
+func (x *Display) Running() bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.cmd != nil
+}
+
+// Name returns the name of the display, e.g. ":99", the value of DISPLAY for
+// its clients. It is empty if the display isn't started.
+func (x *Display) Name() string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	return x.name()
+}
+
+func (x *Display) name() string {
+	if x.cmd == nil {
+		return ""
+	}
+	return ":" + strconv.Itoa(x.number)
+}
+
+// starts the display, if not running yet, for the driver command cmd, whose
+// DISPLAY is set. It returns true if the display was started, and must be
+// stopped with the driver.
+func (x *Display) startFor(cmd *exec.Cmd) (bool, error) {
+	started := false
+	if !x.Running() {
+		if err := x.Start(); err != nil {
+			return false, err
+		}
+		started = true
+	}
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, "DISPLAY="+x.Name())
+	return started, nil
+}
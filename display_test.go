@@ -0,0 +1,55 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestDisplay(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake X server is a shell script")
+	}
+	server := filepath.Join(t.TempDir(), "Xfake")
+	//the fake server prints its display number on -displayfd and waits
+	writeScript(t, server, "#!/bin/sh\necho 42 >&3\nexec sleep 60\n")
+
+	x := NewDisplay()
+	x.Command = server
+	if x.Name() != "" {
+		t.Errorf("display not started has name %q", x.Name())
+	}
+	cmd := exec.Command("chromedriver")
+	started, err := x.startFor(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !started || x.Name() != ":42" {
+		t.Errorf("got display %q, started %v", x.Name(), started)
+	}
+	if env := cmd.Env[len(cmd.Env)-1]; env != "DISPLAY=:42" {
+		t.Errorf("driver environment ends with %q", env)
+	}
+	//a running display is shared
+	if started, err := x.startFor(exec.Command("geckodriver")); err != nil || started {
+		t.Errorf("running display started again: %v, %v", started, err)
+	}
+	if err := x.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if x.Running() {
+		t.Error("display running after Stop")
+	}
+
+	writeScript(t, server, "#!/bin/sh\necho 'no screens found' >&2\nexit 1\n")
+	x.StartTimeout = 5 * time.Second
+	if err := x.Start(); err == nil {
+		t.Error("display started by a failing server")
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	if os.Getenv("WEBDRIVER_FAKE_DRIVER") != "" {
+		fakeDriverProcess()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// fakeDriverProcess is run by the test binary started as a driver: it prints
+// its arguments, environment and directory and serves a status on its port
+// until it is stopped. With WEBDRIVER_FAKE_DRIVER=hang it never listens.
+func fakeDriverProcess() {
+	fmt.Println("env", os.Getenv("WEBDRIVER_TEST_ENV"))
+	dir, _ := os.Getwd()
+	fmt.Println("dir", dir)
+	port := ""
+	for _, arg := range os.Args[1:] {
+		fmt.Println("arg", arg)
+		if strings.HasPrefix(strings.TrimLeft(arg, "-"), "port=") {
+			port = arg[strings.Index(arg, "=")+1:]
+		}
+	}
+	//on stdout, after the arguments: the tests wait for it to read them all
+	if os.Getenv("WEBDRIVER_FAKE_DRIVER") == "hang" {
+		select {}
+	}
+	fmt.Println("fake driver listening on port", port)
+	http.ListenAndServe("127.0.0.1:"+port, nil)
+}
+
+func freeTestPort(t *testing.T) int {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// waits until the logs of d contain s.
+func waitLogs(t *testing.T, d WebDriver, s string) []byte {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		logs := d.Logs()
+		if bytes.Contains(logs, []byte(s)) {
+			return logs
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%q not in the driver logs:\n%s", s, logs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDriverLogs(t *testing.T) {
+	defer os.Setenv("WEBDRIVER_FAKE_DRIVER", os.Getenv("WEBDRIVER_FAKE_DRIVER"))
+	os.Setenv("WEBDRIVER_FAKE_DRIVER", "1")
+	dir := t.TempDir()
+
+	d := NewChromeDriver(os.Args[0])
+	d.Port = freeTestPort(t)
+	d.LogPath = filepath.Join(dir, "chromedriver.log")
+	d.LogFile = filepath.Join(dir, "output.log")
+	d.Verbose = true
+	d.ExtraArgs = []string{"--allowed-ips=127.0.0.1"}
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	logs := waitLogs(t, d, "listening")
+	d.Stop()
+	for _, arg := range []string{"--log-path=" + d.LogPath, "--verbose", "--allowed-ips=127.0.0.1"} {
+		if !bytes.Contains(logs, []byte("arg "+arg+"\n")) {
+			t.Errorf("argument %s not passed:\n%s", arg, logs)
+		}
+	}
+	if output, _ := ioutil.ReadFile(d.LogFile); !bytes.Contains(output, []byte("arg --verbose")) {
+		t.Errorf("output not written to the log file: %q", output)
+	}
+
+	//geckodriver has no log path switch, its output is written there
+	f := NewFirefoxDriver(os.Args[0])
+	f.Port = freeTestPort(t)
+	f.LogPath = filepath.Join(dir, "geckodriver.log")
+	f.LogLevel = "debug"
+	if err := f.Start(); err != nil {
+		t.Fatal(err)
+	}
+	logs = waitLogs(t, f, "listening")
+	f.Stop()
+	if !bytes.Contains(logs, []byte("arg --log\narg debug\n")) {
+		t.Errorf("log level not passed:\n%s", logs)
+	}
+	if output, _ := ioutil.ReadFile(f.LogPath); !bytes.Contains(output, []byte("listening")) {
+		t.Errorf("output not written to the log path: %q", output)
+	}
+}
+
+func TestDriverProcess(t *testing.T) {
+	defer os.Setenv("WEBDRIVER_FAKE_DRIVER", os.Getenv("WEBDRIVER_FAKE_DRIVER"))
+	os.Setenv("WEBDRIVER_FAKE_DRIVER", "1")
+	dir := t.TempDir()
+
+	d := NewIE11Driver(os.Args[0])
+	d.Port = freeTestPort(t)
+	d.LogFile = filepath.Join(dir, "output.log")
+	d.Env = []string{"WEBDRIVER_TEST_ENV=set"}
+	d.Dir = dir
+	d.ProcessGroup = true
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	logs := waitLogs(t, d, "listening")
+	for _, line := range []string{"env set", "dir " + dir} {
+		if !bytes.Contains(logs, []byte(line+"\n")) {
+			t.Errorf("%q not in the output:\n%s", line, logs)
+		}
+	}
+	cmd := d.cmd
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	//the group was interrupted
+	if _, err := cmd.Process.Wait(); err != nil {
+		t.Error(err)
+	}
+	if err := d.Signal(os.Interrupt); err == nil {
+		t.Error("stopped driver signalled")
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	r := newRingBuffer(8)
+	if r.Bytes() == nil || len(r.Bytes()) != 0 {
+		t.Errorf("empty buffer: %q", r.Bytes())
+	}
+	r.Write([]byte("abcdef"))
+	r.Write([]byte("ghij"))
+	if got := string(r.Bytes()); got != "cdefghij" {
+		t.Errorf("got %q, want cdefghij", got)
+	}
+	r.Write([]byte("0123456789"))
+	if got := string(r.Bytes()); got != "23456789" {
+		t.Errorf("got %q, want 23456789", got)
+	}
+	var none *ringBuffer
+	if none.Bytes() != nil {
+		t.Error("nil buffer is not empty")
+	}
+}
+
+func TestDriverStartTimeout(t *testing.T) {
+	defer os.Setenv("WEBDRIVER_FAKE_DRIVER", os.Getenv("WEBDRIVER_FAKE_DRIVER"))
+	os.Setenv("WEBDRIVER_FAKE_DRIVER", "1")
+
+	d := NewChromeDriver(os.Args[0])
+	d.Port = freeTestPort(t)
+	d.LogFile = filepath.Join(t.TempDir(), "output.log")
+	d.StartTimeout = 100 * time.Millisecond
+	d.Env = []string{"WEBDRIVER_FAKE_DRIVER=hang"}
+	if err := d.Start(); err == nil {
+		d.Stop()
+		t.Fatal("a driver that doesn't listen started")
+	}
+	if d.cmd != nil || d.logFile != nil {
+		t.Error("the failed start was not cleaned up")
+	}
+	//the driver can be started again
+	d.Env = nil
+	d.StartTimeout = 20 * time.Second
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+}
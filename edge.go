@@ -24,16 +24,31 @@ type EdgeDriver struct {
 	BaseUrl string
 	//The number of threads to use for handling HTTP requests. Default: 4
 	Threads int
-	//The path to use for the EdgeDriver server log. Default: ./Edgedriver.log
+	//The path of the Edgedriver log. Edgedriver has no log file switch, its output is written to LogPath if LogFile is not set. Default: ""
 	LogPath string
+	//The verbosity of the log: "ALL", "DEBUG", "INFO", "WARNING", "SEVERE" or "OFF" (--log-level of msedgedriver). Default: "", the level of the driver
+	LogLevel string
+	//Log verbosely (--verbose).
+	Verbose bool
+	//Extra arguments of the driver, after those of the settings above, e.g. "--host=0.0.0.0".
+	ExtraArgs []string
+	//Environment of the driver, "KEY=value" entries added to the inherited one, which they override. Default: nil
+	Env []string
+	//Working directory of the driver. Default: "", the current directory
+	Dir string
+	//Start the driver in a new process group, which includes the browsers it starts, and signal the whole group in Stop and Signal.
+	ProcessGroup bool
 	// Log file to dump Edgedriver stdout/stderr. If "" send to terminal. Default: ""
 	LogFile string
 	// Start method fails if Edgedriver doesn't start in less than StartTimeout. Default 20s.
 	StartTimeout time.Duration
+	// Display, if set, is the X display of the browser. It is started with the driver, unless it is running already, and then stopped with it.
+	Display *Display
 
-	path    string
-	cmd     *exec.Cmd
-	logFile *os.File
+	path       string
+	cmd        *exec.Cmd
+	logFile    *os.File
+	ownDisplay bool
 }
 
 //create a new service using Edgedriver.
@@ -43,6 +58,7 @@ type EdgeDriver struct {
 func NewEdgeDriver(path string) *EdgeDriver {
 	d := &EdgeDriver{}
 	d.path = path
+	d.registry = newSessionRegistry()
 	d.Port = 17556
 	d.BaseUrl = ""
 	d.Threads = 4
@@ -78,31 +94,57 @@ func (d *EdgeDriver) Start() error {
 	// 	switches = append(switches, "-url-base="+d.BaseUrl)
 	// }
 
+	if d.LogLevel != "" {
+		switches = append(switches, "--log-level="+d.LogLevel)
+	} else if d.Verbose {
+		switches = append(switches, "--verbose")
+	}
+	switches = append(switches, d.ExtraArgs...)
+
 	d.cmd = exec.Command(d.path, switches...)
+	setupProcess(d.cmd, d.Env, d.Dir, d.ProcessGroup)
+	if d.Display != nil {
+		started, err := d.Display.startFor(d.cmd)
+		if err != nil {
+			d.cmd = nil
+			return errors.New(csferr + err.Error())
+		}
+		d.ownDisplay = started
+	}
 	stdout, err := d.cmd.StdoutPipe()
 	if err != nil {
+		d.abortStart()
 		return errors.New(csferr + err.Error())
 	}
 	stderr, err := d.cmd.StderrPipe()
 	if err != nil {
+		d.abortStart()
 		return errors.New(csferr + err.Error())
 	}
 	if err := d.cmd.Start(); err != nil {
+		d.abortStart()
 		return errors.New(csferr + err.Error())
 	}
-	if d.LogFile != "" {
+	logFile := d.LogFile
+	if logFile == "" {
+		logFile = d.LogPath
+	}
+	d.logs = newRingBuffer(driverLogSize)
+	if logFile != "" {
 		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
-		d.logFile, err = os.OpenFile(d.LogFile, flags, 0640)
+		d.logFile, err = os.OpenFile(logFile, flags, 0640)
 		if err != nil {
+			d.abortStart()
 			return err
 		}
-		go io.Copy(d.logFile, stdout)
-		go io.Copy(d.logFile, stderr)
+		go io.Copy(io.MultiWriter(d.logFile, d.logs), stdout)
+		go io.Copy(io.MultiWriter(d.logFile, d.logs), stderr)
 	} else {
-		go io.Copy(os.Stdout, stdout)
-		go io.Copy(os.Stderr, stderr)
+		go io.Copy(io.MultiWriter(os.Stdout, d.logs), stdout)
+		go io.Copy(io.MultiWriter(os.Stderr, d.logs), stderr)
 	}
 	if err = probePort(d.Port, d.StartTimeout); err != nil {
+		d.abortStart()
 		return err
 	}
 	return nil
@@ -115,13 +157,46 @@ func (d *EdgeDriver) Stop() error {
 	defer func() {
 		d.cmd = nil
 	}()
-	d.cmd.Process.Signal(os.Interrupt)
+	//close the browsers before the driver is gone
+	d.DeleteAllSessions()
+	signalProcess(d.cmd, d.ProcessGroup, os.Interrupt)
 	if d.logFile != nil {
 		d.logFile.Close()
 	}
+	d.stopDisplay()
 	return nil
 }
 
+//undo a failed Start: kill the driver if it started, stop the display it was started on, close the log file and forget the process, so that Start can be called again.
+func (d *EdgeDriver) abortStart() {
+	if d.cmd.Process != nil {
+		signalProcess(d.cmd, d.ProcessGroup, os.Kill)
+		go d.cmd.Wait()
+	}
+	if d.logFile != nil {
+		d.logFile.Close()
+		d.logFile = nil
+	}
+	d.stopDisplay()
+	d.cmd = nil
+}
+
+//stop the display if it was started with the driver.
+func (d *EdgeDriver) stopDisplay() {
+	if d.ownDisplay {
+		d.Display.Stop()
+		d.ownDisplay = false
+	}
+}
+
+//Signal sends sig to the driver, or to its process group if ProcessGroup is set.
+func (d *EdgeDriver) Signal(sig os.Signal) error {
+	if d.cmd == nil {
+		return errors.New("signal failed: Edgedriver not running")
+	}
+	return signalProcess(d.cmd, d.ProcessGroup, sig)
+}
+
 func (d *EdgeDriver) NewSession(desired, required Capabilities) (*Session, error) {
 	//id, capabs, err := d.newSession(desired, required)
 	//return &Session{id, capabs, d}, err
@@ -133,7 +208,7 @@ func (d *EdgeDriver) NewSession(desired, required Capabilities) (*Session, error
 	return session, nil
 }
 
-func (d *EdgeDriver) Sessions() ([]Session, error) {
+func (d *EdgeDriver) Sessions() ([]*Session, error) {
 	sessions, err := d.sessions()
 	if err != nil {
 		return nil, err
@@ -0,0 +1,682 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// HAR is an HTTP Archive 1.2 document, see http://www.softwareishard.com/blog/har-12-spec/.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+// HARLog is the root of a HAR document.
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Pages   []HARPage   `json:"pages"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+// HARCreator names the application that created the document.
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HARPage is a page of a HAR document.
+type HARPage struct {
+	StartedDateTime string `json:"startedDateTime"`
+	ID              string `json:"id"`
+	Title           string `json:"title"`
+	PageTimings     struct {
+		OnContentLoad float64 `json:"onContentLoad"`
+		OnLoad        float64 `json:"onLoad"`
+	} `json:"pageTimings"`
+}
+
+// HAREntry is an HTTP request and its response.
+type HAREntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+// HARNameValue is a header, a cookie or a query string parameter.
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// HARRequest is the request of an entry.
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARPostData is the body of a request.
+type HARPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// HARResponse is the response of an entry. Status is 0 if the request failed.
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARNameValue `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+// HARContent is the body of a response.
+type HARContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings are the phases of an entry in milliseconds, -1 when not applicable.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// Write the document as JSON.
+func (h *HAR) Write(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(h)
+}
+
+// WriteFile writes the document to filename, conventionally with extension .har.
+func (h *HAR) WriteFile(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := h.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// EnablePerformanceLog sets the capabilities that make chromedriver record
+// the network events in the "performance" log, which Session.RecordHAR reads.
+func (c Capabilities) EnablePerformanceLog() {
+	prefs, _ := c["goog:loggingPrefs"].(map[string]interface{})
+	if prefs == nil {
+		prefs = map[string]interface{}{}
+		c["goog:loggingPrefs"] = prefs
+	}
+	prefs["performance"] = "ALL"
+	options, _ := c["goog:chromeOptions"].(map[string]interface{})
+	if options == nil {
+		options = map[string]interface{}{}
+		c["goog:chromeOptions"] = options
+	}
+	options["perfLoggingPrefs"] = map[string]interface{}{"enableNetwork": true, "enablePage": false}
+}
+
+// HAROptions configures a HARRecorder.
+type HAROptions struct {
+	// Bodies includes the response bodies. Only supported with the
+	// performance log, bodies are fetched with CDP when the HAR is built.
+	Bodies bool
+}
+
+// HARRecorder turns captured network traffic into a HAR document. It is safe
+// for concurrent use.
+type HARRecorder struct {
+	opts HAROptions
+	// drain the source of events, if it is polled
+	poll func() error
+	// the executor response bodies are fetched with
+	bodies CDPExecutor
+	stop   func() error
+
+	mu      sync.Mutex
+	records map[string]*harRecord
+	order   []*harRecord
+	skipped []LogEntry
+}
+
+type harRecord struct {
+	id    string
+	entry HAREntry
+	// CDP monotonic clock, in seconds
+	requestTime float64
+	timing      *cdpResourceTiming
+	done        bool
+	// BiDi start, in milliseconds since the epoch
+	start BiDiTime
+}
+
+// NewHARRecorder returns a recorder fed by hand with AddPerformanceLog.
+func NewHARRecorder(opts HAROptions) *HARRecorder {
+	return &HARRecorder{opts: opts, records: map[string]*harRecord{}, stop: func() error { return nil }}
+}
+
+// RecordHAR starts recording the network traffic of the session. It listens
+// to BiDi network events if the session has a webSocketUrl and reads the
+// "performance" log otherwise (see Capabilities.EnablePerformanceLog).
+func (s *Session) RecordHAR(opts HAROptions) (*HARRecorder, error) {
+	if url, _ := s.capability("webSocketUrl").(string); url != "" {
+		b, err := s.DialBiDi()
+		if err != nil {
+			return nil, err
+		}
+		r, err := b.RecordHAR(opts)
+		if err != nil {
+			b.Close()
+			return nil, err
+		}
+		stop := r.stop
+		r.stop = func() error {
+			err := stop()
+			b.Close()
+			return err
+		}
+		return r, nil
+	}
+	r := NewHARRecorder(opts)
+	r.bodies = s
+	var stopped bool
+	var pollMu sync.Mutex
+	r.poll = func() error {
+		pollMu.Lock()
+		defer pollMu.Unlock()
+		if stopped {
+			return nil
+		}
+		entries, err := s.Log("performance")
+		if err != nil {
+			return err
+		}
+		return r.AddPerformanceLog(entries)
+	}
+	r.stop = func() error {
+		err := r.poll()
+		pollMu.Lock()
+		stopped = true
+		pollMu.Unlock()
+		return err
+	}
+	//discard the traffic logged before the recording started
+	if _, err := s.Log("performance"); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// RecordHAR starts recording the network events of an open BiDi connection.
+func (b *BiDiConn) RecordHAR(opts HAROptions) (*HARRecorder, error) {
+	r := NewHARRecorder(opts)
+	removes := []func(){
+		b.OnBeforeRequestSent(r.addBiDiRequest),
+		b.OnResponseCompleted(r.addBiDiResponse),
+		b.onNetwork("network.fetchError", r.addBiDiError),
+	}
+	events := []string{"network.beforeRequestSent", "network.responseCompleted", "network.fetchError"}
+	removeAll := func() {
+		for _, remove := range removes {
+			remove()
+		}
+	}
+	if err := b.Subscribe(events...); err != nil {
+		removeAll()
+		return nil, err
+	}
+	r.stop = func() error {
+		removeAll()
+		return b.Unsubscribe(events...)
+	}
+	return r, nil
+}
+
+// HAR builds the document with the traffic recorded so far.
+func (r *HARRecorder) HAR() (*HAR, error) {
+	if r.poll != nil {
+		if err := r.poll(); err != nil {
+			return nil, err
+		}
+	}
+	h := &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "webdriver", Version: "0.1"},
+		Pages:   []HARPage{},
+		Entries: []*HAREntry{},
+	}}
+	//the bodies are fetched without the lock, which the event handlers take
+	var missing []*harRecord
+	var entries []*HAREntry
+	r.mu.Lock()
+	for _, rec := range r.order {
+		entry := rec.entry
+		h.Log.Entries = append(h.Log.Entries, &entry)
+		if r.opts.Bodies && r.bodies != nil && rec.done && entry.Response.Status != 0 && entry.Response.Content.Text == "" {
+			missing = append(missing, rec)
+			entries = append(entries, &entry)
+		}
+	}
+	r.mu.Unlock()
+	for n, rec := range missing {
+		body, err := CDPNetwork{r.bodies}.GetResponseBody(rec.id)
+		if err != nil {
+			//evicted from the browser cache or never had a body
+			continue
+		}
+		setHARContent(&entries[n].Response.Content, body)
+		//kept for the next documents, the browser may evict it
+		r.mu.Lock()
+		rec.entry.Response.Content = entries[n].Response.Content
+		r.mu.Unlock()
+	}
+	return h, nil
+}
+
+// Skipped returns the entries of the performance log that were not valid JSON
+// and so are missing from the HAR.
+func (r *HARRecorder) Skipped() []LogEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]LogEntry(nil), r.skipped...)
+}
+
+// Stop recording. The recorded traffic remains available.
+func (r *HARRecorder) Stop() error {
+	return r.stop()
+}
+
+func setHARContent(c *HARContent, body []byte) {
+	c.Size = len(body)
+	if utf8.Valid(body) {
+		c.Text = string(body)
+	} else {
+		c.Text = base64.StdEncoding.EncodeToString(body)
+		c.Encoding = "base64"
+	}
+}
+
+// the events of the performance log, see https://chromedevtools.github.io/devtools-protocol/tot/Network/
+type cdpRequest struct {
+	URL      string                 `json:"url"`
+	Method   string                 `json:"method"`
+	Headers  map[string]interface{} `json:"headers"`
+	PostData string                 `json:"postData"`
+}
+
+type cdpResponse struct {
+	URL               string                 `json:"url"`
+	Status            int                    `json:"status"`
+	StatusText        string                 `json:"statusText"`
+	Headers           map[string]interface{} `json:"headers"`
+	MimeType          string                 `json:"mimeType"`
+	Protocol          string                 `json:"protocol"`
+	RemoteIPAddress   string                 `json:"remoteIPAddress"`
+	EncodedDataLength float64                `json:"encodedDataLength"`
+	Timing            *cdpResourceTiming     `json:"timing"`
+}
+
+// offsets in milliseconds from RequestTime, -1 if not applicable.
+type cdpResourceTiming struct {
+	RequestTime       float64 `json:"requestTime"`
+	DNSStart          float64 `json:"dnsStart"`
+	DNSEnd            float64 `json:"dnsEnd"`
+	ConnectStart      float64 `json:"connectStart"`
+	ConnectEnd        float64 `json:"connectEnd"`
+	SSLStart          float64 `json:"sslStart"`
+	SSLEnd            float64 `json:"sslEnd"`
+	SendStart         float64 `json:"sendStart"`
+	SendEnd           float64 `json:"sendEnd"`
+	ReceiveHeadersEnd float64 `json:"receiveHeadersEnd"`
+}
+
+type cdpNetworkEvent struct {
+	RequestID         string       `json:"requestId"`
+	Timestamp         float64      `json:"timestamp"`
+	WallTime          float64      `json:"wallTime"`
+	Request           *cdpRequest  `json:"request"`
+	RedirectResponse  *cdpResponse `json:"redirectResponse"`
+	Response          *cdpResponse `json:"response"`
+	EncodedDataLength float64      `json:"encodedDataLength"`
+	ErrorText         string       `json:"errorText"`
+	Canceled          bool         `json:"canceled"`
+}
+
+// AddPerformanceLog adds the network events of entries of the chromedriver
+// "performance" log. Other entries are ignored, those that are not valid JSON
+// are kept aside, see Skipped.
+func (r *HARRecorder) AddPerformanceLog(entries []LogEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range entries {
+		var m struct {
+			Message struct {
+				Method string          `json:"method"`
+				Params cdpNetworkEvent `json:"params"`
+			} `json:"message"`
+		}
+		if err := json.Unmarshal([]byte(e.Message), &m); err != nil {
+			//e.g. truncated by the driver, the rest of the batch is still valid
+			r.skipped = append(r.skipped, e)
+			continue
+		}
+		p := m.Message.Params
+		switch m.Message.Method {
+		case "Network.requestWillBeSent":
+			r.cdpRequestWillBeSent(p)
+		case "Network.responseReceived":
+			if rec := r.records[p.RequestID]; rec != nil && p.Response != nil {
+				setCDPResponse(rec, p.Response)
+			}
+		case "Network.loadingFinished":
+			if rec := r.records[p.RequestID]; rec != nil {
+				rec.done = true
+				if rec.entry.Response.Content.Size == 0 {
+					rec.entry.Response.Content.Size = int(p.EncodedDataLength)
+				}
+				rec.entry.Response.BodySize = int(p.EncodedDataLength)
+				setCDPTimings(rec, p.Timestamp)
+			}
+		case "Network.loadingFailed":
+			if rec := r.records[p.RequestID]; rec != nil {
+				rec.done = true
+				rec.entry.Response.StatusText = p.ErrorText
+				rec.entry.Comment = p.ErrorText
+				setCDPTimings(rec, p.Timestamp)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *HARRecorder) cdpRequestWillBeSent(p cdpNetworkEvent) {
+	if p.Request == nil {
+		return
+	}
+	if rec := r.records[p.RequestID]; rec != nil && p.RedirectResponse != nil {
+		//the same request id is reused by redirects, close the previous hop
+		setCDPResponse(rec, p.RedirectResponse)
+		rec.entry.Response.RedirectURL = p.Request.URL
+		rec.done = true
+		setCDPTimings(rec, p.Timestamp)
+	}
+	rec := &harRecord{id: p.RequestID, requestTime: p.Timestamp}
+	rec.entry.StartedDateTime = wallTime(p.WallTime).Format(time.RFC3339Nano)
+	rec.entry.Request = HARRequest{
+		Method:      p.Request.Method,
+		URL:         p.Request.URL,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARNameValue{},
+		Headers:     harHeaders(p.Request.Headers),
+		QueryString: harQueryString(p.Request.URL),
+		HeadersSize: -1,
+		BodySize:    len(p.Request.PostData),
+	}
+	if p.Request.PostData != "" {
+		mimeType := headerValue(p.Request.Headers, "Content-Type")
+		rec.entry.Request.PostData = &HARPostData{MimeType: mimeType, Text: p.Request.PostData}
+	}
+	rec.entry.Response = HARResponse{Cookies: []HARNameValue{}, Headers: []HARNameValue{}, HeadersSize: -1, BodySize: -1}
+	rec.entry.Timings = HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	r.records[p.RequestID] = rec
+	r.order = append(r.order, rec)
+}
+
+func setCDPResponse(rec *harRecord, response *cdpResponse) {
+	version := harHTTPVersion(response.Protocol)
+	rec.entry.Request.HTTPVersion = version
+	rec.entry.Response.Status = response.Status
+	rec.entry.Response.StatusText = response.StatusText
+	rec.entry.Response.HTTPVersion = version
+	rec.entry.Response.Headers = harHeaders(response.Headers)
+	rec.entry.Response.Content.MimeType = response.MimeType
+	rec.entry.ServerIPAddress = strings.Trim(response.RemoteIPAddress, "[]")
+	rec.timing = response.Timing
+}
+
+// compute the timings of the entry, finished at the monotonic time end.
+func setCDPTimings(rec *harRecord, end float64) {
+	t := &rec.entry.Timings
+	total := (end - rec.requestTime) * 1000
+	tm := rec.timing
+	if tm == nil {
+		//served from the cache or failed before the request was sent
+		*t = HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: total}
+		rec.entry.Time = total
+		return
+	}
+	blocked := tm.SendStart
+	for _, start := range []float64{tm.DNSStart, tm.ConnectStart} {
+		if start >= 0 {
+			blocked = start
+			break
+		}
+	}
+	offset := (tm.RequestTime - rec.requestTime) * 1000
+	*t = HARTimings{
+		Blocked: offset + blocked,
+		DNS:     span(tm.DNSStart, tm.DNSEnd),
+		Connect: span(tm.ConnectStart, tm.ConnectEnd),
+		SSL:     span(tm.SSLStart, tm.SSLEnd),
+		Send:    tm.SendEnd - tm.SendStart,
+		Wait:    tm.ReceiveHeadersEnd - tm.SendEnd,
+		Receive: (end-tm.RequestTime)*1000 - tm.ReceiveHeadersEnd,
+	}
+	if t.Receive < 0 {
+		t.Receive = 0
+	}
+	rec.entry.Time = harTotal(*t)
+}
+
+func (r *HARRecorder) addBiDiRequest(e BiDiNetworkEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	//redirects reuse the request id, the previous hop completed already
+	rec := &harRecord{id: e.Request.Request, start: e.Timestamp}
+	rec.entry.StartedDateTime = e.Timestamp.Time().Format(time.RFC3339Nano)
+	rec.entry.Request = HARRequest{
+		Method:      e.Request.Method,
+		URL:         e.Request.URL,
+		HTTPVersion: "HTTP/1.1",
+		Cookies:     []HARNameValue{},
+		Headers:     bidiHARHeaders(e.Request.Headers),
+		QueryString: harQueryString(e.Request.URL),
+		HeadersSize: e.Request.HeadersSize,
+		BodySize:    -1,
+	}
+	if e.Request.BodySize != nil {
+		rec.entry.Request.BodySize = *e.Request.BodySize
+	}
+	rec.entry.Response = HARResponse{Cookies: []HARNameValue{}, Headers: []HARNameValue{}, HeadersSize: -1, BodySize: -1}
+	rec.entry.Timings = HARTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1}
+	r.records[rec.id] = rec
+	r.order = append(r.order, rec)
+}
+
+func (r *HARRecorder) addBiDiResponse(e BiDiNetworkEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec := r.records[e.Request.Request]
+	if rec == nil || e.Response == nil {
+		return
+	}
+	rec.done = true
+	version := harHTTPVersion(e.Response.Protocol)
+	rec.entry.Request.HTTPVersion = version
+	rec.entry.Response.Status = e.Response.Status
+	rec.entry.Response.StatusText = e.Response.StatusText
+	rec.entry.Response.HTTPVersion = version
+	rec.entry.Response.Headers = bidiHARHeaders(e.Response.Headers)
+	rec.entry.Response.Content = HARContent{Size: e.Response.BytesReceived, MimeType: e.Response.MimeType}
+	if e.Response.HeadersSize != nil {
+		rec.entry.Response.HeadersSize = *e.Response.HeadersSize
+	}
+	if e.Response.BodySize != nil {
+		rec.entry.Response.BodySize = *e.Response.BodySize
+	}
+	for _, h := range rec.entry.Response.Headers {
+		if strings.EqualFold(h.Name, "Location") {
+			rec.entry.Response.RedirectURL = h.Value
+		}
+	}
+	tm := e.Request.Timings
+	t := &rec.entry.Timings
+	if tm.ResponseEnd > 0 && tm.RequestStart > 0 {
+		t.DNS = span(tm.DNSStart, tm.DNSEnd)
+		t.Connect = span(tm.ConnectStart, tm.ConnectEnd)
+		if tm.TLSStart > 0 {
+			t.SSL = span(tm.TLSStart, tm.ConnectEnd)
+		}
+		t.Send = 0
+		t.Wait = tm.ResponseStart - tm.RequestStart
+		t.Receive = tm.ResponseEnd - tm.ResponseStart
+	} else {
+		t.Wait = float64(e.Timestamp - rec.start)
+	}
+	rec.entry.Time = harTotal(*t)
+}
+
+func (r *HARRecorder) addBiDiError(e BiDiNetworkEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if rec := r.records[e.Request.Request]; rec != nil {
+		rec.done = true
+		rec.entry.Response.StatusText = e.ErrorText
+		rec.entry.Comment = e.ErrorText
+		rec.entry.Timings.Wait = float64(e.Timestamp - rec.start)
+		rec.entry.Time = harTotal(rec.entry.Timings)
+	}
+}
+
+// the duration between two offsets, -1 if the phase didn't happen.
+func span(start, end float64) float64 {
+	if start < 0 || end < 0 || (start == 0 && end == 0) {
+		return -1
+	}
+	return end - start
+}
+
+// the total time of an entry, ssl is included in connect.
+func harTotal(t HARTimings) float64 {
+	total := 0.0
+	for _, v := range []float64{t.Blocked, t.DNS, t.Connect, t.Send, t.Wait, t.Receive} {
+		if v > 0 {
+			total += v
+		}
+	}
+	return total
+}
+
+func wallTime(seconds float64) time.Time {
+	//microseconds, the precision of the browser clock
+	return time.Unix(0, int64(math.Round(seconds*1e6))*int64(time.Microsecond)).UTC()
+}
+
+func harHTTPVersion(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "h2", "http/2", "http/2.0":
+		return "HTTP/2"
+	case "h3", "http/3":
+		return "HTTP/3"
+	case "http/1.0":
+		return "HTTP/1.0"
+	}
+	return "HTTP/1.1"
+}
+
+func harHeaders(headers map[string]interface{}) []HARNameValue {
+	nv := []HARNameValue{}
+	for name, value := range headers {
+		s, _ := value.(string)
+		//CDP joins repeated headers with newlines
+		for _, v := range strings.Split(s, "\n") {
+			nv = append(nv, HARNameValue{name, v})
+		}
+	}
+	sort.Slice(nv, func(i, j int) bool { return nv[i].Name < nv[j].Name })
+	return nv
+}
+
+// returns the value of the header name, whose case varies: HTTP/2 headers are
+// lower case.
+func headerValue(headers map[string]interface{}, name string) string {
+	for k, v := range headers {
+		if strings.EqualFold(k, name) {
+			s, _ := v.(string)
+			return s
+		}
+	}
+	return ""
+}
+
+func bidiHARHeaders(headers []BiDiHeader) []HARNameValue {
+	nv := []HARNameValue{}
+	for _, h := range headers {
+		value := h.Value.Value
+		if h.Value.Type == "base64" {
+			if v, err := base64.StdEncoding.DecodeString(value); err == nil {
+				value = string(v)
+			}
+		}
+		nv = append(nv, HARNameValue{h.Name, value})
+	}
+	return nv
+}
+
+func harQueryString(rawurl string) []HARNameValue {
+	nv := []HARNameValue{}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nv
+	}
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range query[name] {
+			nv = append(nv, HARNameValue{name, v})
+		}
+	}
+	return nv
+}
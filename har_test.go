@@ -0,0 +1,227 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// an entry of the performance log, as chromedriver wraps a CDP event.
+func perfLogEntry(method string, params map[string]interface{}) map[string]interface{} {
+	message := mustJSON(map[string]interface{}{
+		"message": map[string]interface{}{"method": method, "params": params},
+		"webview": "ABC",
+	})
+	return map[string]interface{}{"level": "INFO", "message": string(message), "timestamp": 1700000000000}
+}
+
+func TestHARPerformanceLog(t *testing.T) {
+	timing := map[string]interface{}{
+		"requestTime": 100.05, "dnsStart": 1.0, "dnsEnd": 3.0, "connectStart": 3.0, "connectEnd": 10.0,
+		"sslStart": 5.0, "sslEnd": 10.0, "sendStart": 10.0, "sendEnd": 11.0, "receiveHeadersEnd": 31.0,
+	}
+	polls := 0
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/log"):
+			polls++
+			if polls == 1 {
+				//logged before the recording started
+				writeValue(w, []interface{}{perfLogEntry("Network.requestWillBeSent", map[string]interface{}{
+					"requestId": "0", "timestamp": 1.0, "wallTime": 1.0,
+					"request": map[string]interface{}{"url": "http://old/", "method": "GET", "headers": map[string]interface{}{}},
+				})})
+				return
+			}
+			writeValue(w, []interface{}{
+				map[string]interface{}{"level": "INFO", "message": `{"message":{"method":"Page.frameNavigated","params":{}}}`},
+				map[string]interface{}{"level": "INFO", "message": `{"message":{"method":"Network.dataRece`},
+				perfLogEntry("Network.requestWillBeSent", map[string]interface{}{
+					"requestId": "1", "timestamp": 100.0, "wallTime": 1700000000.5,
+					"request": map[string]interface{}{"url": "http://a/old", "method": "GET", "headers": map[string]interface{}{"Accept": "*/*"}},
+				}),
+				perfLogEntry("Network.requestWillBeSent", map[string]interface{}{
+					"requestId": "1", "timestamp": 100.05, "wallTime": 1700000000.55,
+					"request": map[string]interface{}{"url": "http://a/new?q=1&lang=en", "method": "GET", "headers": map[string]interface{}{}},
+					"redirectResponse": map[string]interface{}{
+						"status": 301, "statusText": "Moved Permanently", "protocol": "http/1.1",
+						"headers": map[string]interface{}{"Location": "/new?q=1&lang=en"},
+					},
+				}),
+				perfLogEntry("Network.responseReceived", map[string]interface{}{
+					"requestId": "1", "timestamp": 100.08,
+					"response": map[string]interface{}{
+						"status": 200, "statusText": "OK", "protocol": "h2", "mimeType": "text/html",
+						"remoteIPAddress": "[::1]", "timing": timing,
+						"headers": map[string]interface{}{"Content-Type": "text/html", "Set-Cookie": "a=1\nb=2"},
+					},
+				}),
+				perfLogEntry("Network.loadingFinished", map[string]interface{}{
+					"requestId": "1", "timestamp": 100.1, "encodedDataLength": 120,
+				}),
+				perfLogEntry("Network.requestWillBeSent", map[string]interface{}{
+					"requestId": "2", "timestamp": 100.2, "wallTime": 1700000000.7,
+					"request": map[string]interface{}{"url": "http://b/", "method": "POST", "postData": "x=1",
+						"headers": map[string]interface{}{"content-type": "application/x-www-form-urlencoded"}},
+				}),
+				perfLogEntry("Network.loadingFailed", map[string]interface{}{
+					"requestId": "2", "timestamp": 100.25, "errorText": "net::ERR_NAME_NOT_RESOLVED",
+				}),
+			})
+		case strings.HasSuffix(r.URL.Path, "/cdp/execute"):
+			var p struct {
+				Cmd    string
+				Params map[string]string
+			}
+			json.NewDecoder(r.Body).Decode(&p)
+			if p.Cmd != "Network.getResponseBody" || p.Params["requestId"] != "1" {
+				t.Errorf("unexpected command %s %v", p.Cmd, p.Params)
+			}
+			writeValue(w, map[string]interface{}{"body": "<html></html>", "base64Encoded": false})
+		default:
+			writeValue(w, nil)
+		}
+	})
+	recorder, err := fakeSession(d).RecordHAR(HAROptions{Bodies: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := recorder.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	h, err := recorder.HAR()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if polls != 2 {
+		t.Errorf("performance log read %d times after Stop, want 2", polls)
+	}
+	if skipped := recorder.Skipped(); len(skipped) != 1 || !strings.Contains(skipped[0].Message, "dataRece") {
+		t.Errorf("skipped entries: %v", skipped)
+	}
+	entries := h.Log.Entries
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	redirect, page, failed := entries[0], entries[1], entries[2]
+	if redirect.Response.Status != 301 || redirect.Response.RedirectURL != "http://a/new?q=1&lang=en" {
+		t.Errorf("wrong redirect: %+v", redirect.Response)
+	}
+	if page.StartedDateTime != "2023-11-14T22:13:20.55Z" {
+		t.Errorf("startedDateTime = %s", page.StartedDateTime)
+	}
+	if page.Response.Status != 200 || page.Response.HTTPVersion != "HTTP/2" || page.ServerIPAddress != "::1" {
+		t.Errorf("wrong response: %+v", page.Response)
+	}
+	if page.Response.Content.Text != "<html></html>" || page.Response.Content.Size != 13 {
+		t.Errorf("wrong content: %+v", page.Response.Content)
+	}
+	if len(page.Request.QueryString) != 2 || page.Request.QueryString[0] != (HARNameValue{"lang", "en"}) {
+		t.Errorf("wrong query string: %v", page.Request.QueryString)
+	}
+	if len(page.Response.Headers) != 3 {
+		t.Errorf("repeated headers not split: %v", page.Response.Headers)
+	}
+	want := HARTimings{Blocked: 1, DNS: 2, Connect: 7, SSL: 5, Send: 1, Wait: 20, Receive: 19}
+	got := page.Timings
+	for _, v := range []*float64{&got.Blocked, &got.Receive} {
+		*v = float64(int(*v + 0.5))
+	}
+	if got != want {
+		t.Errorf("timings = %+v, want %+v", got, want)
+	}
+	if failed.Response.Status != 0 || failed.Comment != "net::ERR_NAME_NOT_RESOLVED" {
+		t.Errorf("wrong failed entry: %+v", failed)
+	}
+	if failed.Request.PostData == nil || failed.Request.PostData.Text != "x=1" ||
+		failed.Request.PostData.MimeType != "application/x-www-form-urlencoded" {
+		t.Errorf("wrong post data: %+v", failed.Request.PostData)
+	}
+
+	var buf bytes.Buffer
+	if err := h.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["log"]["version"] != "1.2" {
+		t.Errorf("wrong version: %v", doc["log"]["version"])
+	}
+}
+
+func TestEnablePerformanceLog(t *testing.T) {
+	caps := Capabilities{"goog:chromeOptions": map[string]interface{}{"args": []string{"--headless"}}}
+	caps.EnablePerformanceLog()
+	options := caps["goog:chromeOptions"].(map[string]interface{})
+	if options["args"] == nil || options["perfLoggingPrefs"] == nil {
+		t.Errorf("wrong chrome options: %v", options)
+	}
+	if caps["goog:loggingPrefs"].(map[string]interface{})["performance"] != "ALL" {
+		t.Errorf("wrong logging prefs: %v", caps["goog:loggingPrefs"])
+	}
+}
+
+// a CDP executor that records more traffic while a body is fetched.
+type busyBodies struct {
+	r *HARRecorder
+}
+
+func (b busyBodies) CDP(method string, params, result interface{}) error {
+	entry := perfLogEntry("Network.requestWillBeSent", map[string]interface{}{
+		"requestId": "2", "timestamp": 2.0, "wallTime": 1700000002.0,
+		"request": map[string]interface{}{"url": "http://a/later", "method": "GET", "headers": map[string]interface{}{}},
+	})
+	if err := b.r.AddPerformanceLog([]LogEntry{{Message: entry["message"].(string)}}); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(`{"body":"ok","base64Encoded":false}`), result)
+}
+
+func TestHARBodiesUnlocked(t *testing.T) {
+	r := NewHARRecorder(HAROptions{Bodies: true})
+	r.bodies = busyBodies{r}
+	var entries []LogEntry
+	for _, e := range []map[string]interface{}{
+		perfLogEntry("Network.requestWillBeSent", map[string]interface{}{
+			"requestId": "1", "timestamp": 1.0, "wallTime": 1700000001.0,
+			"request": map[string]interface{}{"url": "http://a/", "method": "GET", "headers": map[string]interface{}{}},
+		}),
+		perfLogEntry("Network.responseReceived", map[string]interface{}{
+			"requestId": "1", "timestamp": 1.1, "response": map[string]interface{}{"status": 200, "headers": map[string]interface{}{}},
+		}),
+		perfLogEntry("Network.loadingFinished", map[string]interface{}{"requestId": "1", "timestamp": 1.2}),
+	} {
+		entries = append(entries, LogEntry{Message: e["message"].(string)})
+	}
+	if err := r.AddPerformanceLog(entries); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan *HAR)
+	go func() {
+		h, err := r.HAR()
+		if err != nil {
+			t.Error(err)
+		}
+		done <- h
+	}()
+	select {
+	case h := <-done:
+		if h == nil || len(h.Log.Entries) != 1 || h.Log.Entries[0].Response.Content.Text != "ok" {
+			t.Fatalf("got HAR %+v", h)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("HAR holds its lock while fetching the bodies")
+	}
+	if h, _ := r.HAR(); len(h.Log.Entries) != 2 {
+		t.Errorf("got %d entries, want the one added during the fetch too", len(h.Log.Entries))
+	}
+}
@@ -24,8 +24,20 @@ type IE11Driver struct {
 	BaseUrl string
 	//The number of threads to use for handling HTTP requests. Default: 4
 	Threads int
-	//The path to use for the IE11Driver server log. Default: ./IE11driver.log
+	//The path of the IEDriverServer log. Default: "" (no log file)
 	LogPath string
+	//The verbosity of the log: "FATAL", "ERROR", "WARN", "INFO", "DEBUG" or "TRACE" (--log-level). Default: "", the level of the driver
+	LogLevel string
+	//Log verbosely (--log-level=TRACE).
+	Verbose bool
+	//Extra arguments of the driver, after those of the settings above, e.g. "--whitelisted-ips=10.0.0.2".
+	ExtraArgs []string
+	//Environment of the driver, "KEY=value" entries added to the inherited one, which they override. Default: nil
+	Env []string
+	//Working directory of the driver. Default: "", the current directory
+	Dir string
+	//Start the driver in a new process group, which includes the browsers it starts, and signal the whole group in Stop and Signal.
+	ProcessGroup bool
 	// Log file to dump IE11driver stdout/stderr. If "" send to terminal. Default: ""
 	LogFile string
 	// Start method fails if IE11driver doesn't start in less than StartTimeout. Default 20s.
@@ -43,6 +55,7 @@ type IE11Driver struct {
 func NewIE11Driver(path string) *IE11Driver {
 	d := &IE11Driver{}
 	d.path = path
+	d.registry = newSessionRegistry()
 	d.Port = 5555
 	d.BaseUrl = ""
 	d.Threads = 4
@@ -70,31 +83,49 @@ func (d *IE11Driver) Start() error {
 	var switches []string
 	switches = append(switches, "--port="+strconv.Itoa(d.Port))
 
+	if d.LogPath != "" {
+		switches = append(switches, "--log-file="+d.LogPath)
+	}
+	if d.LogLevel != "" {
+		switches = append(switches, "--log-level="+d.LogLevel)
+	} else if d.Verbose {
+		switches = append(switches, "--log-level=TRACE")
+	}
+	switches = append(switches, d.ExtraArgs...)
+
 	d.cmd = exec.Command(d.path, switches...)
+	setupProcess(d.cmd, d.Env, d.Dir, d.ProcessGroup)
 	stdout, err := d.cmd.StdoutPipe()
 	if err != nil {
+		d.abortStart()
 		return errors.New(csferr + err.Error())
 	}
 	stderr, err := d.cmd.StderrPipe()
 	if err != nil {
+		d.abortStart()
 		return errors.New(csferr + err.Error())
 	}
 	if err := d.cmd.Start(); err != nil {
+		d.abortStart()
 		return errors.New(csferr + err.Error())
 	}
-	if d.LogFile != "" {
+	logFile := d.LogFile
+	d.logs = newRingBuffer(driverLogSize)
+	if logFile != "" {
 		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
-		d.logFile, err = os.OpenFile(d.LogFile, flags, 0640)
+		d.logFile, err = os.OpenFile(logFile, flags, 0640)
 		if err != nil {
+			d.abortStart()
 			return err
 		}
-		go io.Copy(d.logFile, stdout)
-		go io.Copy(d.logFile, stderr)
+		go io.Copy(io.MultiWriter(d.logFile, d.logs), stdout)
+		go io.Copy(io.MultiWriter(d.logFile, d.logs), stderr)
 	} else {
-		go io.Copy(os.Stdout, stdout)
-		go io.Copy(os.Stderr, stderr)
+		go io.Copy(io.MultiWriter(os.Stdout, d.logs), stdout)
+		go io.Copy(io.MultiWriter(os.Stderr, d.logs), stderr)
 	}
 	if err = probePort(d.Port, d.StartTimeout); err != nil {
+		d.abortStart()
 		return err
 	}
 	return nil
@@ -107,13 +138,36 @@ func (d *IE11Driver) Stop() error {
 	defer func() {
 		d.cmd = nil
 	}()
-	d.cmd.Process.Signal(os.Interrupt)
+	//close the browsers before the driver is gone
+	d.DeleteAllSessions()
+	signalProcess(d.cmd, d.ProcessGroup, os.Interrupt)
 	if d.logFile != nil {
 		d.logFile.Close()
 	}
 	return nil
 }
 
+//undo a failed Start: kill the driver if it started, close the log file and forget the process, so that Start can be called again.
+func (d *IE11Driver) abortStart() {
+	if d.cmd.Process != nil {
+		signalProcess(d.cmd, d.ProcessGroup, os.Kill)
+		go d.cmd.Wait()
+	}
+	if d.logFile != nil {
+		d.logFile.Close()
+		d.logFile = nil
+	}
+	d.cmd = nil
+}
+
+//Signal sends sig to the driver, or to its process group if ProcessGroup is set.
+func (d *IE11Driver) Signal(sig os.Signal) error {
+	if d.cmd == nil {
+		return errors.New("signal failed: IE11driver not running")
+	}
+	return signalProcess(d.cmd, d.ProcessGroup, sig)
+}
+
 func (d *IE11Driver) NewSession(desired, required Capabilities) (*Session, error) {
 	//id, capabs, err := d.newSession(desired, required)
 	//return &Session{id, capabs, d}, err
@@ -125,7 +179,7 @@ func (d *IE11Driver) NewSession(desired, required Capabilities) (*Session, error
 	return session, nil
 }
 
-func (d *IE11Driver) Sessions() ([]Session, error) {
+func (d *IE11Driver) Sessions() ([]*Session, error) {
 	sessions, err := d.sessions()
 	if err != nil {
 		return nil, err
@@ -0,0 +1,567 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// InterceptedRequest is a request paused by an Interceptor. The handler
+// decides its fate by returning one of r.Continue(), r.ContinueWith(),
+// r.Fulfill() or r.Fail().
+type InterceptedRequest struct {
+	URL     string
+	Method  string
+	Headers http.Header
+	// Body is the request body. It is nil when the protocol doesn't expose it
+	// (BiDi) or the request has none.
+	Body []byte
+
+	id string
+}
+
+// InterceptAction is what happens to an intercepted request.
+type InterceptAction struct {
+	kind     int
+	override RequestOverride
+	response MockResponse
+}
+
+const (
+	actionContinue = iota
+	actionFulfill
+	actionFail
+)
+
+// RequestOverride changes a request before it is sent. Zero fields are left unchanged.
+type RequestOverride struct {
+	URL     string
+	Method  string
+	Headers http.Header
+	Body    []byte
+}
+
+// MockResponse is a response built in Go and returned to the page instead of
+// sending the request.
+type MockResponse struct {
+	Status  int
+	Headers http.Header
+	Body    []byte
+}
+
+// InterceptHandler is called for each request matching the pattern of an
+// intercept. Handlers are called one at a time and should return quickly:
+// the page waits for the decision.
+type InterceptHandler func(r *InterceptedRequest) InterceptAction
+
+// Continue sends the request unchanged.
+func (r *InterceptedRequest) Continue() InterceptAction {
+	return InterceptAction{kind: actionContinue}
+}
+
+// ContinueWith sends the request with the changes in o.
+func (r *InterceptedRequest) ContinueWith(o RequestOverride) InterceptAction {
+	return InterceptAction{kind: actionContinue, override: o}
+}
+
+// Fulfill answers the request with response, the request is not sent.
+func (r *InterceptedRequest) Fulfill(response MockResponse) InterceptAction {
+	if response.Status == 0 {
+		response.Status = http.StatusOK
+	}
+	return InterceptAction{kind: actionFulfill, response: response}
+}
+
+// FulfillJSON answers the request with v encoded as JSON.
+func (r *InterceptedRequest) FulfillJSON(status int, v interface{}) InterceptAction {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return r.Fulfill(MockResponse{Status: http.StatusInternalServerError, Body: []byte(err.Error())})
+	}
+	headers := http.Header{"Content-Type": {"application/json"}}
+	return r.Fulfill(MockResponse{Status: status, Headers: headers, Body: body})
+}
+
+// Fail aborts the request with a network error.
+func (r *InterceptedRequest) Fail() InterceptAction {
+	return InterceptAction{kind: actionFail}
+}
+
+// interceptBackend is the protocol an Interceptor pauses requests with.
+// setPatterns pauses the requests matching any of patterns only, none if
+// patterns is empty.
+type interceptBackend interface {
+	start(paused func(r *InterceptedRequest)) error
+	setPatterns(patterns []string) error
+	resolve(r *InterceptedRequest, a InterceptAction) error
+	stop() error
+}
+
+// Interceptor pauses the network requests of the page and hands those
+// matching a pattern to Go handlers. Only the requests matching the pattern
+// of a handler are paused, others are not seen by the interceptor. It is safe
+// for concurrent use.
+type Interceptor struct {
+	backend interceptBackend
+	// serializes the updates of the patterns of the backend and its stop
+	patternsMu sync.Mutex
+	stopped    bool
+
+	mu     sync.Mutex
+	routes []*interceptRoute
+	err    error
+}
+
+type interceptRoute struct {
+	pattern string
+	handler InterceptHandler
+}
+
+// Intercept starts intercepting the requests of the session whose URL
+// matches pattern, see Interceptor.Intercept. The interceptor uses WebDriver
+// BiDi if the session has a webSocketUrl, CDP otherwise.
+func (s *Session) Intercept(pattern string, handler InterceptHandler) (*Interceptor, error) {
+	return s.newInterceptor(&interceptRoute{pattern, handler})
+}
+
+// NewInterceptor starts intercepting the requests of the session without
+// handlers, requests continue unchanged until handlers are added.
+func (s *Session) NewInterceptor() (*Interceptor, error) {
+	return s.newInterceptor(nil)
+}
+
+func (s *Session) newInterceptor(route *interceptRoute) (*Interceptor, error) {
+	if url, _ := s.capability("webSocketUrl").(string); url != "" {
+		b, err := s.DialBiDi()
+		if err != nil {
+			return nil, err
+		}
+		return newInterceptor(&bidiIntercept{conn: b, owned: true}, route)
+	}
+	c, err := s.DialCDP()
+	if err != nil {
+		return nil, errors.New("intercept: session has neither a BiDi nor a CDP connection: " + err.Error())
+	}
+	return newInterceptor(&cdpIntercept{conn: c, owned: true}, route)
+}
+
+// Intercept starts intercepting the requests whose URL matches pattern over
+// an open BiDi connection, see Interceptor.Intercept.
+func (b *BiDiConn) Intercept(pattern string, handler InterceptHandler) (*Interceptor, error) {
+	return newInterceptor(&bidiIntercept{conn: b}, &interceptRoute{pattern, handler})
+}
+
+// Intercept starts intercepting the requests whose URL matches pattern over
+// an open CDP connection (Fetch domain), see Interceptor.Intercept.
+func (c *CDPConn) Intercept(pattern string, handler InterceptHandler) (*Interceptor, error) {
+	return newInterceptor(&cdpIntercept{conn: c}, &interceptRoute{pattern, handler})
+}
+
+func newInterceptor(backend interceptBackend, route *interceptRoute) (*Interceptor, error) {
+	i := &Interceptor{backend: backend}
+	if route != nil {
+		i.routes = append(i.routes, route)
+	}
+	if err := backend.start(i.paused); err != nil {
+		backend.stop()
+		return nil, err
+	}
+	if err := i.updatePatterns(); err != nil {
+		backend.stop()
+		return nil, err
+	}
+	return i, nil
+}
+
+// send the patterns of the routes to the backend.
+func (i *Interceptor) updatePatterns() error {
+	i.patternsMu.Lock()
+	defer i.patternsMu.Unlock()
+	if i.stopped {
+		return nil
+	}
+	var patterns []string
+	i.mu.Lock()
+	for _, r := range i.routes {
+		if !containsString(patterns, r.pattern) {
+			patterns = append(patterns, r.pattern)
+		}
+	}
+	i.mu.Unlock()
+	return i.backend.setPatterns(patterns)
+}
+
+// records the first error met by the interceptor.
+func (i *Interceptor) fail(err error) {
+	debugprint(err)
+	i.mu.Lock()
+	if i.err == nil {
+		i.err = err
+	}
+	i.mu.Unlock()
+}
+
+// Intercept hands the requests whose URL matches pattern to handler. In
+// pattern, "*" matches any sequence of characters and "?" a single one, e.g.
+// "*/api/*" or "https://*.analytics.com/*". When several patterns match, the
+// handler added last wins. The returned function removes the handler. An
+// error updating the patterns of the browser is reported by Err.
+func (i *Interceptor) Intercept(pattern string, handler InterceptHandler) func() {
+	route := &interceptRoute{pattern, handler}
+	i.mu.Lock()
+	i.routes = append(i.routes, route)
+	i.mu.Unlock()
+	if err := i.updatePatterns(); err != nil {
+		i.fail(err)
+	}
+	return func() {
+		i.mu.Lock()
+		for n, r := range i.routes {
+			if r == route {
+				i.routes = append(i.routes[:n:n], i.routes[n+1:]...)
+				break
+			}
+		}
+		i.mu.Unlock()
+		if err := i.updatePatterns(); err != nil {
+			i.fail(err)
+		}
+	}
+}
+
+// Err returns the first error met resolving a paused request or updating
+// the patterns, if any.
+func (i *Interceptor) Err() error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.err
+}
+
+// Stop intercepting: the browser stops pausing requests, and the BiDi
+// subscription to network.beforeRequestSent is removed. Connections opened by
+// Session.Intercept are closed. Stop can be called again, it does nothing.
+func (i *Interceptor) Stop() error {
+	//a handler removed meanwhile or later must not enable the patterns again
+	i.patternsMu.Lock()
+	defer i.patternsMu.Unlock()
+	if i.stopped {
+		return nil
+	}
+	i.stopped = true
+	return i.backend.stop()
+}
+
+func (i *Interceptor) paused(r *InterceptedRequest) {
+	var handler InterceptHandler
+	i.mu.Lock()
+	for n := len(i.routes) - 1; n >= 0; n-- {
+		if matchGlob(i.routes[n].pattern, r.URL) {
+			handler = i.routes[n].handler
+			break
+		}
+	}
+	i.mu.Unlock()
+	action := r.Continue()
+	if handler != nil {
+		action = handler(r)
+	}
+	if err := i.backend.resolve(r, action); err != nil {
+		i.fail(err)
+	}
+}
+
+// reports whether s matches pattern, where "*" matches any sequence of characters and "?" any character.
+func matchGlob(pattern, s string) bool {
+	p, n := 0, 0
+	star, mark := -1, 0
+	for n < len(s) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			star, mark = p, n
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == s[n]):
+			p++
+			n++
+		case star >= 0:
+			p = star + 1
+			mark++
+			n = mark
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// bidiIntercept pauses requests with network.addIntercept. BiDi URL patterns
+// have no wildcards: patterns without "*" and "?" are sent to the browser,
+// if any has wildcards all the requests are paused.
+type bidiIntercept struct {
+	conn  *BiDiConn
+	owned bool
+	// held while the intercept changes, so that events wait for its id
+	changing sync.Mutex
+	// the current intercept, and all those added, whose paused requests are ours
+	id     string
+	ids    []string
+	remove func()
+}
+
+func (b *bidiIntercept) start(paused func(r *InterceptedRequest)) error {
+	b.remove = b.conn.OnBeforeRequestSent(func(e BiDiNetworkEvent) {
+		if !e.IsBlocked {
+			return
+		}
+		b.changing.Lock()
+		ours := false
+		for _, id := range e.Intercepts {
+			ours = ours || containsString(b.ids, id)
+		}
+		b.changing.Unlock()
+		if !ours {
+			return
+		}
+		r := &InterceptedRequest{
+			URL:     e.Request.URL,
+			Method:  e.Request.Method,
+			Headers: http.Header{},
+			id:      e.Request.Request,
+		}
+		for _, h := range e.Request.Headers {
+			value := h.Value.Value
+			if h.Value.Type == "base64" {
+				if v, err := base64.StdEncoding.DecodeString(value); err == nil {
+					value = string(v)
+				}
+			}
+			r.Headers.Add(h.Name, value)
+		}
+		paused(r)
+	})
+	return b.conn.Subscribe("network.beforeRequestSent")
+}
+
+// replaces the intercept with one of patterns; the new one is added before
+// the old one is removed, so that no request escapes.
+func (b *bidiIntercept) setPatterns(patterns []string) error {
+	b.changing.Lock()
+	defer b.changing.Unlock()
+	old := b.id
+	b.id = ""
+	if len(patterns) > 0 {
+		p := map[string]interface{}{"phases": []string{"beforeRequestSent"}}
+		var urlPatterns []map[string]string
+		for _, pattern := range patterns {
+			if strings.ContainsAny(pattern, "*?") {
+				urlPatterns = nil
+				break
+			}
+			urlPatterns = append(urlPatterns, map[string]string{"type": "string", "pattern": pattern})
+		}
+		if urlPatterns != nil {
+			p["urlPatterns"] = urlPatterns
+		}
+		var result struct {
+			Intercept string `json:"intercept"`
+		}
+		if err := b.conn.Call("network.addIntercept", p, &result); err != nil {
+			b.id = old
+			return err
+		}
+		b.id = result.Intercept
+		b.ids = append(b.ids, b.id)
+	}
+	if old != "" {
+		return b.conn.Call("network.removeIntercept", map[string]interface{}{"intercept": old}, nil)
+	}
+	return nil
+}
+
+func (b *bidiIntercept) resolve(r *InterceptedRequest, a InterceptAction) error {
+	p := map[string]interface{}{"request": r.id}
+	switch a.kind {
+	case actionFail:
+		return b.conn.Call("network.failRequest", p, nil)
+	case actionFulfill:
+		p["statusCode"] = a.response.Status
+		p["reasonPhrase"] = http.StatusText(a.response.Status)
+		p["headers"] = bidiHeaders(a.response.Headers)
+		p["body"] = map[string]string{"type": "base64", "value": base64.StdEncoding.EncodeToString(a.response.Body)}
+		return b.conn.Call("network.provideResponse", p, nil)
+	}
+	o := a.override
+	if o.URL != "" {
+		p["url"] = o.URL
+	}
+	if o.Method != "" {
+		p["method"] = o.Method
+	}
+	if o.Headers != nil {
+		p["headers"] = bidiHeaders(o.Headers)
+	}
+	if o.Body != nil {
+		p["body"] = map[string]string{"type": "base64", "value": base64.StdEncoding.EncodeToString(o.Body)}
+	}
+	return b.conn.Call("network.continueRequest", p, nil)
+}
+
+func (b *bidiIntercept) stop() error {
+	if b.remove != nil {
+		b.remove()
+	}
+	err := b.setPatterns(nil)
+	if uerr := b.conn.Unsubscribe("network.beforeRequestSent"); err == nil {
+		err = uerr
+	}
+	if b.owned {
+		b.conn.Close()
+	}
+	return err
+}
+
+func bidiHeaders(h http.Header) []map[string]interface{} {
+	headers := []map[string]interface{}{}
+	for name, values := range h {
+		for _, v := range values {
+			headers = append(headers, map[string]interface{}{
+				"name":  name,
+				"value": map[string]string{"type": "string", "value": v},
+			})
+		}
+	}
+	return headers
+}
+
+// cdpIntercept pauses requests with the Fetch domain.
+type cdpIntercept struct {
+	conn   *CDPConn
+	owned  bool
+	remove func()
+	// Fetch is enabled, guarded by the patternsMu of the Interceptor
+	enabled bool
+}
+
+func (c *cdpIntercept) start(paused func(r *InterceptedRequest)) error {
+	c.remove = c.conn.On("Fetch.requestPaused", func(params json.RawMessage) {
+		var e struct {
+			RequestID string `json:"requestId"`
+			Request   struct {
+				URL      string            `json:"url"`
+				Method   string            `json:"method"`
+				Headers  map[string]string `json:"headers"`
+				PostData *string           `json:"postData"`
+			} `json:"request"`
+		}
+		if json.Unmarshal(params, &e) != nil {
+			return
+		}
+		r := &InterceptedRequest{
+			URL:     e.Request.URL,
+			Method:  e.Request.Method,
+			Headers: http.Header{},
+			id:      e.RequestID,
+		}
+		for name, value := range e.Request.Headers {
+			//CDP joins repeated headers with newlines
+			for _, v := range strings.Split(value, "\n") {
+				r.Headers.Add(name, v)
+			}
+		}
+		if e.Request.PostData != nil {
+			r.Body = []byte(*e.Request.PostData)
+		}
+		paused(r)
+	})
+	return nil
+}
+
+// enables Fetch with patterns, which have the same wildcards, or disables it
+// if there are none.
+func (c *cdpIntercept) setPatterns(patterns []string) error {
+	if len(patterns) == 0 {
+		if !c.enabled {
+			return nil
+		}
+		c.enabled = false
+		return c.conn.CDP("Fetch.disable", nil, nil)
+	}
+	var p []map[string]string
+	for _, pattern := range patterns {
+		//a backslash escapes the wildcards in CDP
+		p = append(p, map[string]string{"urlPattern": strings.ReplaceAll(pattern, `\`, `\\`), "requestStage": "Request"})
+	}
+	if err := c.conn.CDP("Fetch.enable", map[string]interface{}{"patterns": p}, nil); err != nil {
+		return err
+	}
+	c.enabled = true
+	return nil
+}
+
+func (c *cdpIntercept) resolve(r *InterceptedRequest, a InterceptAction) error {
+	p := map[string]interface{}{"requestId": r.id}
+	switch a.kind {
+	case actionFail:
+		p["errorReason"] = "Failed"
+		return c.conn.CDP("Fetch.failRequest", p, nil)
+	case actionFulfill:
+		p["responseCode"] = a.response.Status
+		p["responseHeaders"] = cdpHeaders(a.response.Headers)
+		p["body"] = base64.StdEncoding.EncodeToString(a.response.Body)
+		return c.conn.CDP("Fetch.fulfillRequest", p, nil)
+	}
+	o := a.override
+	if o.URL != "" {
+		p["url"] = o.URL
+	}
+	if o.Method != "" {
+		p["method"] = o.Method
+	}
+	if o.Headers != nil {
+		p["headers"] = cdpHeaders(o.Headers)
+	}
+	if o.Body != nil {
+		p["postData"] = base64.StdEncoding.EncodeToString(o.Body)
+	}
+	return c.conn.CDP("Fetch.continueRequest", p, nil)
+}
+
+func (c *cdpIntercept) stop() error {
+	if c.remove != nil {
+		c.remove()
+	}
+	c.enabled = false
+	err := c.conn.CDP("Fetch.disable", nil, nil)
+	if c.owned {
+		c.conn.Close()
+	}
+	return err
+}
+
+func cdpHeaders(h http.Header) []map[string]string {
+	headers := []map[string]string{}
+	for name, values := range h {
+		for _, v := range values {
+			headers = append(headers, map[string]string{"name": name, "value": v})
+		}
+	}
+	return headers
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
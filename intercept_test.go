@@ -0,0 +1,230 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, c := range []struct {
+		pattern, s string
+		match      bool
+	}{
+		{"*/api/*", "https://example.com/api/users?id=1", true},
+		{"*/api/*", "https://example.com/apis", false},
+		{"https://*.analytics.com/*", "https://www.analytics.com/collect", true},
+		{"https://*.analytics.com/*", "https://example.com/analytics.com/", false},
+		{"http://host/?", "http://host/a", true},
+		{"*", "", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+	} {
+		if got := matchGlob(c.pattern, c.s); got != c.match {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", c.pattern, c.s, got, c.match)
+		}
+	}
+}
+
+// a CDP stand-in that pauses two requests once Fetch is enabled and reports
+// how they were resolved, and the Fetch.enable and Fetch.disable commands.
+func fetchStandIn(resolved chan<- rpcMessage) func(c *wsConn) {
+	return func(c *wsConn) {
+		for {
+			data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			var m rpcMessage
+			json.Unmarshal(data, &m)
+			c.WriteMessage([]byte(fmt.Sprintf(`{"id":%d,"result":{}}`, m.ID)))
+			switch m.Method {
+			case "Fetch.enable":
+				resolved <- m
+				c.WriteMessage([]byte(`{"method":"Fetch.requestPaused","params":{"requestId":"1","request":{"url":"https://example.com/api/users","method":"POST","headers":{"Accept":"application/json"},"postData":"{}"}}}`))
+				c.WriteMessage([]byte(`{"method":"Fetch.requestPaused","params":{"requestId":"2","request":{"url":"https://example.com/index.html","method":"GET","headers":{}}}}`))
+			case "Fetch.fulfillRequest", "Fetch.continueRequest", "Fetch.failRequest", "Fetch.disable":
+				resolved <- m
+			}
+		}
+	}
+}
+
+func TestInterceptCDP(t *testing.T) {
+	resolved := make(chan rpcMessage, 4)
+	conn, err := DialCDPURL(serveWebSocket(t, fetchStandIn(resolved)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	var seen *InterceptedRequest
+	i, err := conn.Intercept("*/api/*", func(r *InterceptedRequest) InterceptAction {
+		seen = r
+		return r.FulfillJSON(201, map[string]string{"name": "gopher"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//only the pattern of the handler is paused
+	if m := <-resolved; m.Method != "Fetch.enable" || string(m.Params) != `{"patterns":[{"requestStage":"Request","urlPattern":"*/api/*"}]}` {
+		t.Errorf("got %s %s", m.Method, m.Params)
+	}
+	for n := 0; n < 2; n++ {
+		select {
+		case m := <-resolved:
+			var p struct {
+				RequestID    string `json:"requestId"`
+				ResponseCode int    `json:"responseCode"`
+				Body         string `json:"body"`
+			}
+			json.Unmarshal(m.Params, &p)
+			switch p.RequestID {
+			case "1":
+				body, _ := base64.StdEncoding.DecodeString(p.Body)
+				if m.Method != "Fetch.fulfillRequest" || p.ResponseCode != 201 || string(body) != `{"name":"gopher"}` {
+					t.Errorf("api request resolved with %s %s", m.Method, m.Params)
+				}
+			case "2":
+				if m.Method != "Fetch.continueRequest" {
+					t.Errorf("page request resolved with %s", m.Method)
+				}
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("request not resolved")
+		}
+	}
+	if seen == nil || seen.Method != "POST" || string(seen.Body) != "{}" || seen.Headers.Get("Accept") != "application/json" {
+		t.Fatalf("handler saw %+v", seen)
+	}
+	if err := i.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := i.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-resolved:
+		if m.Method != "Fetch.disable" {
+			t.Errorf("stopped with %s", m.Method)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Fetch not disabled")
+	}
+}
+
+func TestInterceptBiDi(t *testing.T) {
+	commands := make(chan rpcMessage, 10)
+	url := serveWebSocket(t, func(c *wsConn) {
+		intercepts := 0
+		for {
+			data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			var m rpcMessage
+			json.Unmarshal(data, &m)
+			commands <- m
+			result := "{}"
+			if m.Method == "network.addIntercept" {
+				intercepts++
+				result = fmt.Sprintf(`{"intercept":"i%d"}`, intercepts)
+			}
+			c.WriteMessage([]byte(fmt.Sprintf(`{"type":"success","id":%d,"result":%s}`, m.ID, result)))
+		}
+	})
+	conn, err := DialBiDiURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	i, err := conn.Intercept("https://example.com/api", func(r *InterceptedRequest) InterceptAction {
+		return r.Continue()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	remove := i.Intercept("*/img/*", func(r *InterceptedRequest) InterceptAction {
+		return r.Fail()
+	})
+	remove()
+	if err := i.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for len(commands) > 0 {
+		m := <-commands
+		got = append(got, m.Method+" "+string(m.Params))
+	}
+	want := []string{
+		`session.subscribe {"events":["network.beforeRequestSent"]}`,
+		`network.addIntercept {"phases":["beforeRequestSent"],"urlPatterns":[{"pattern":"https://example.com/api","type":"string"}]}`,
+		//a glob can't be sent, every request is paused
+		`network.addIntercept {"phases":["beforeRequestSent"]}`,
+		`network.removeIntercept {"intercept":"i1"}`,
+		`network.addIntercept {"phases":["beforeRequestSent"],"urlPatterns":[{"pattern":"https://example.com/api","type":"string"}]}`,
+		`network.removeIntercept {"intercept":"i2"}`,
+		`network.removeIntercept {"intercept":"i3"}`,
+		`session.unsubscribe {"events":["network.beforeRequestSent"]}`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got commands:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestInterceptStop(t *testing.T) {
+	commands := make(chan string, 100)
+	url := serveWebSocket(t, func(c *wsConn) {
+		for {
+			data, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			var m rpcMessage
+			json.Unmarshal(data, &m)
+			commands <- m.Method
+			c.WriteMessage([]byte(fmt.Sprintf(`{"id":%d,"result":{}}`, m.ID)))
+		}
+	})
+	conn, err := DialCDPURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	handler := func(r *InterceptedRequest) InterceptAction { return r.Continue() }
+	i, err := conn.Intercept("*/a/*", handler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	removeB := i.Intercept("*/b/*", handler)
+	removeC := i.Intercept("*/c/*", handler)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		removeB()
+	}()
+	if err := i.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	removeC()
+	if err := i.Stop(); err != nil {
+		t.Errorf("second Stop: %v", err)
+	}
+	//nothing is enabled again once Fetch is disabled
+	var last string
+	for len(commands) > 0 {
+		last = <-commands
+	}
+	if last != "Fetch.disable" {
+		t.Errorf("last command %s, want Fetch.disable", last)
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// A Locator describes how to find an element: a strategy, a value and,
+// optionally, the locator of the element to search from.
+//
+// Locators are built with By and chained with Child:
+//
+//	form := webdriver.By.ID("login")
+//	user := form.Child(webdriver.By.Name("user"))
+//	elem, err := session.Find(user)
+type Locator struct {
+	Using  FindElementStrategy
+	Value  string
+	parent *Locator
+}
+
+type locatorBuilder struct{}
+
+// By builds locators, e.g. By.CSS(".btn").
+var By locatorBuilder
+
+// Locate elements matching a CSS selector.
+func (locatorBuilder) CSS(selector string) Locator {
+	return Locator{Using: CSS_Selector, Value: selector}
+}
+
+// Locate elements matching an XPath expression.
+func (locatorBuilder) XPath(expression string) Locator {
+	return Locator{Using: XPath, Value: expression}
+}
+
+// Locate elements whose ID attribute is id.
+func (locatorBuilder) ID(id string) Locator {
+	return Locator{Using: ID, Value: id}
+}
+
+// Locate elements whose NAME attribute is name.
+func (locatorBuilder) Name(name string) Locator {
+	return Locator{Using: Name, Value: name}
+}
+
+// Locate elements whose class name contains class.
+func (locatorBuilder) ClassName(class string) Locator {
+	return Locator{Using: ClassName, Value: class}
+}
+
+// Locate elements whose tag name is tag.
+func (locatorBuilder) TagName(tag string) Locator {
+	return Locator{Using: TagName, Value: tag}
+}
+
+// Locate anchor elements whose visible text is text.
+func (locatorBuilder) LinkText(text string) Locator {
+	return Locator{Using: LinkText, Value: text}
+}
+
+// Locate anchor elements whose visible text contains text.
+func (locatorBuilder) PartialLinkText(text string) Locator {
+	return Locator{Using: PartialLinkText, Value: text}
+}
+
+// Child returns a locator that searches for c starting from the element found by l.
+func (l Locator) Child(c Locator) Locator {
+	if c.parent == nil {
+		c.parent = &l
+		return c
+	}
+	p := l.Child(*c.parent)
+	c.parent = &p
+	return c
+}
+
+// Parent returns the locator of the element the search starts from, or nil if
+// the search starts from the document root.
+func (l Locator) Parent() *Locator {
+	return l.parent
+}
+
+func (l Locator) String() string {
+	var steps []string
+	for p := &l; p != nil; p = p.parent {
+		steps = append([]string{fmt.Sprintf("%s %q", p.Using, p.Value)}, steps...)
+	}
+	return strings.Join(steps, " > ")
+}
+
+// Find the first element matched by l.
+func (s *Session) Find(l Locator) (WebElement, error) {
+	if l.parent == nil {
+		e, err := s.FindElement(l.Using, l.Value)
+		if err != nil {
+			return WebElement{}, fmt.Errorf("find %s: %w", l, err)
+		}
+		return e, nil
+	}
+	parent, err := s.Find(*l.parent)
+	if err != nil {
+		return WebElement{}, err
+	}
+	e, err := parent.FindElement(l.Using, l.Value)
+	if err != nil {
+		return WebElement{}, fmt.Errorf("find %s: %w", l, err)
+	}
+	return e, nil
+}
+
+// Find all the elements matched by l. The parents of l must match at least one element.
+func (s *Session) FindAll(l Locator) ([]WebElement, error) {
+	if l.parent == nil {
+		elements, err := s.FindElements(l.Using, l.Value)
+		if err != nil {
+			return nil, fmt.Errorf("find all %s: %w", l, err)
+		}
+		return elements, nil
+	}
+	parent, err := s.Find(*l.parent)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := parent.FindElements(l.Using, l.Value)
+	if err != nil {
+		return nil, fmt.Errorf("find all %s: %w", l, err)
+	}
+	return elements, nil
+}
+
+// Find the first element matched by l, searching from e.
+func (e WebElement) Find(l Locator) (WebElement, error) {
+	root := e
+	if l.parent != nil {
+		var err error
+		if root, err = e.Find(*l.parent); err != nil {
+			return WebElement{}, err
+		}
+	}
+	found, err := root.FindElement(l.Using, l.Value)
+	if err != nil {
+		return WebElement{}, fmt.Errorf("find %s: %w", l, err)
+	}
+	return found, nil
+}
+
+// Find all the elements matched by l, searching from e.
+func (e WebElement) FindAll(l Locator) ([]WebElement, error) {
+	root := e
+	if l.parent != nil {
+		var err error
+		if root, err = e.Find(*l.parent); err != nil {
+			return nil, err
+		}
+	}
+	elements, err := root.FindElements(l.Using, l.Value)
+	if err != nil {
+		return nil, fmt.Errorf("find all %s: %w", l, err)
+	}
+	return elements, nil
+}
+
+// A LazyElement is an element bound to a locator rather than to a DOM node.
+// The element is located on first use and located again, and the command
+// retried, whenever the driver reports a stale element reference, so a
+// LazyElement survives re-renders of the page.
+//
+// A LazyElement is not safe for concurrent use.
+type LazyElement struct {
+	Locator Locator
+	s       *Session
+	elem    *WebElement
+}
+
+// Lazy returns a LazyElement for l. No command is sent until the element is used.
+func (s *Session) Lazy(l Locator) *LazyElement {
+	return &LazyElement{Locator: l, s: s}
+}
+
+// Element returns the element currently matched by the locator, locating it if needed.
+func (l *LazyElement) Element() (WebElement, error) {
+	if l.elem == nil {
+		e, err := l.s.Find(l.Locator)
+		if err != nil {
+			return WebElement{}, err
+		}
+		l.elem = &e
+	}
+	return *l.elem, nil
+}
+
+// Reset drops the located element, the next command locates it again.
+func (l *LazyElement) Reset() {
+	l.elem = nil
+}
+
+// run f on the element, locating it again and retrying once if it went stale.
+func (l *LazyElement) do(f func(e WebElement) error) error {
+	e, err := l.Element()
+	if err != nil {
+		return err
+	}
+	err = f(e)
+	if !isStaleElement(err) {
+		return err
+	}
+	l.Reset()
+	if e, err = l.Element(); err != nil {
+		return err
+	}
+	return f(e)
+}
+
+// Search for an element starting from the lazy element.
+func (l *LazyElement) Find(c Locator) (WebElement, error) {
+	var found WebElement
+	err := l.do(func(e WebElement) (err error) {
+		found, err = e.Find(c)
+		return
+	})
+	return found, err
+}
+
+// Child returns a LazyElement located by c starting from the lazy element.
+func (l *LazyElement) Child(c Locator) *LazyElement {
+	return &LazyElement{Locator: l.Locator.Child(c), s: l.s}
+}
+
+// Click on the element.
+func (l *LazyElement) Click() error {
+	return l.do(func(e WebElement) error { return e.Click() })
+}
+
+// Submit the FORM element.
+func (l *LazyElement) Submit() error {
+	return l.do(func(e WebElement) error { return e.Submit() })
+}
+
+// Clear a TEXTAREA or text INPUT element's value.
+func (l *LazyElement) Clear() error {
+	return l.do(func(e WebElement) error { return e.Clear() })
+}
+
+// Send a sequence of key strokes to the element.
+func (l *LazyElement) SendKeys(sequence string) error {
+	return l.do(func(e WebElement) error { return e.SendKeys(sequence) })
+}
+
+// Returns the visible text of the element.
+func (l *LazyElement) Text() (string, error) {
+	var text string
+	err := l.do(func(e WebElement) (err error) {
+		text, err = e.Text()
+		return
+	})
+	return text, err
+}
+
+// Query the element's tag name.
+func (l *LazyElement) Name() (string, error) {
+	var name string
+	err := l.do(func(e WebElement) (err error) {
+		name, err = e.Name()
+		return
+	})
+	return name, err
+}
+
+// Get the value of an element's attribute.
+func (l *LazyElement) GetAttribute(name string) (string, error) {
+	var value string
+	err := l.do(func(e WebElement) (err error) {
+		value, err = e.GetAttribute(name)
+		return
+	})
+	return value, err
+}
+
+// Query the value of an element's computed CSS property.
+func (l *LazyElement) GetCssProperty(name string) (string, error) {
+	var value string
+	err := l.do(func(e WebElement) (err error) {
+		value, err = e.GetCssProperty(name)
+		return
+	})
+	return value, err
+}
+
+// Determine if the element is currently selected.
+func (l *LazyElement) IsSelected() (bool, error) {
+	var ok bool
+	err := l.do(func(e WebElement) (err error) {
+		ok, err = e.IsSelected()
+		return
+	})
+	return ok, err
+}
+
+// Determine if the element is currently enabled.
+func (l *LazyElement) IsEnabled() (bool, error) {
+	var ok bool
+	err := l.do(func(e WebElement) (err error) {
+		ok, err = e.IsEnabled()
+		return
+	})
+	return ok, err
+}
+
+// Determine if the element is currently displayed.
+func (l *LazyElement) IsDisplayed() (bool, error) {
+	var ok bool
+	err := l.do(func(e WebElement) (err error) {
+		ok, err = e.IsDisplayed()
+		return
+	})
+	return ok, err
+}
+
+// Determine the element's location on the page.
+func (l *LazyElement) GetLocation() (Position, error) {
+	var p Position
+	err := l.do(func(e WebElement) (err error) {
+		p, err = e.GetLocation()
+		return
+	})
+	return p, err
+}
+
+// Determine the element's size in pixels.
+func (l *LazyElement) Size() (Size, error) {
+	var size Size
+	err := l.do(func(e WebElement) (err error) {
+		size, err = e.Size()
+		return
+	})
+	return size, err
+}
@@ -0,0 +1,125 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeDriver is a WebDriver backed by an in-process HTTP server, for tests
+// that don't need a browser.
+type fakeDriver struct {
+	WebDriverCore
+	srv *httptest.Server
+}
+
+func newFakeDriver(t *testing.T, handler http.HandlerFunc) *fakeDriver {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	d := &fakeDriver{srv: srv}
+	d.url = srv.URL
+	d.registry = newSessionRegistry()
+	return d
+}
+
+func (d *fakeDriver) NewSession(desired, required Capabilities) (*Session, error) {
+	session, err := d.newSession(desired, required)
+	if err != nil {
+		return nil, err
+	}
+	session.wd = d
+	return session, nil
+}
+
+func (d *fakeDriver) Sessions() ([]*Session, error) {
+	sessions, err := d.sessions()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].wd = d
+	}
+	return sessions, nil
+}
+
+// write a W3C response with the given value.
+func writeValue(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"value": value})
+}
+
+// write a W3C error response.
+func writeError(w http.ResponseWriter, status int, errorType string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"value": map[string]interface{}{"error": errorType, "message": errorType},
+	})
+}
+
+// a session of d that speaks the W3C dialect, created without a round trip.
+func fakeSession(d WebDriver) *Session {
+	return &Session{
+		Id:           "s1",
+		Capabilities: Capabilities{"capabilities": map[string]interface{}{"browserName": "fake"}},
+		wd:           d,
+	}
+}
+
+func TestLocatorString(t *testing.T) {
+	l := By.ID("form").Child(By.CSS("div.row").Child(By.Name("q")))
+	want := `id "form" > css selector "div.row" > name "q"`
+	if l.String() != want {
+		t.Fatalf("got %s, want %s", l, want)
+	}
+	if l.Parent() == nil || l.Parent().Parent() == nil || l.Parent().Parent().Parent() != nil {
+		t.Fatal("wrong chain length")
+	}
+}
+
+func TestLazyElementStale(t *testing.T) {
+	finds, clicks := 0, 0
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/element"):
+			finds++
+			writeValue(w, map[string]string{webElementKey: "e" + string(rune('0'+finds))})
+		case strings.HasSuffix(r.URL.Path, "/click"):
+			clicks++
+			if strings.Contains(r.URL.Path, "/e1/") {
+				writeError(w, 404, "stale element reference")
+				return
+			}
+			writeValue(w, nil)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	lazy := fakeSession(d).Lazy(By.CSS(".btn"))
+	if err := lazy.Click(); err != nil {
+		t.Fatal(err)
+	}
+	if finds != 2 || clicks != 2 {
+		t.Fatalf("got %d finds and %d clicks, want 2 and 2", finds, clicks)
+	}
+	e, _ := lazy.Element()
+	if e.id != "e2" {
+		t.Fatalf("element not refreshed: %s", e.id)
+	}
+}
+
+func TestFindError(t *testing.T) {
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, 404, "no such element")
+	})
+	_, err := fakeSession(d).Find(By.ID("form").Child(By.Name("q")))
+	if err == nil || !strings.Contains(err.Error(), `id "form"`) {
+		t.Fatalf("locator missing from error: %v", err)
+	}
+}
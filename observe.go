@@ -0,0 +1,63 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"context"
+	"regexp"
+	"strings"
+)
+
+// Command describes a command sent to a driver, for a CommandObserver.
+type Command struct {
+	// Name identifies the kind of command, with the parameters of its path
+	// left out, e.g. "POST /session/{}/element/{}/click".
+	Name   string
+	Method string
+	// Path is the URL of the command relative to the driver.
+	Path string
+	// SessionID is the id of the session of the command, if any.
+	SessionID string
+}
+
+// CommandResult is the outcome of a command, for a CommandObserver.
+type CommandResult struct {
+	// Status is the HTTP status code of the last response, 0 if the driver
+	// couldn't be reached.
+	Status int
+	// Attempts is the number of times the command was sent, see RetryPolicy.
+	Attempts int
+	// Err is the error of the command: a *CommandError if the driver returned
+	// one, the transport error otherwise.
+	Err error
+}
+
+// CommandObserver is notified of the commands sent to a driver, e.g. to trace
+// or to measure them. See the otelwebdriver module for an implementation that
+// uses OpenTelemetry.
+type CommandObserver interface {
+	// StartCommand is called before c is sent, with the context of its
+	// session (see Session.SetContext). The function it returns is called when
+	// the command is over.
+	StartCommand(ctx context.Context, c Command) func(CommandResult)
+}
+
+var formatVerb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z]`)
+
+// observe notifies the observer of w, if any, of a command.
+func (w WebDriverCore) observe(ctx context.Context, method, urlFormat, path string) func(CommandResult) {
+	if w.Observer == nil {
+		return func(CommandResult) {}
+	}
+	c := Command{
+		Name:   method + " " + formatVerb.ReplaceAllString(urlFormat, "{}"),
+		Method: method,
+		Path:   path,
+	}
+	if strings.HasPrefix(path, "/session/") {
+		c.SessionID = strings.SplitN(strings.TrimPrefix(path, "/session/"), "/", 2)[0]
+	}
+	return w.Observer.StartCommand(ctx, c)
+}
@@ -0,0 +1,67 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type observedCommand struct {
+	Command
+	CommandResult
+	test interface{}
+}
+
+type recordingObserver []observedCommand
+
+type testKey struct{}
+
+func (o *recordingObserver) StartCommand(ctx context.Context, c Command) func(CommandResult) {
+	return func(r CommandResult) {
+		*o = append(*o, observedCommand{c, r, ctx.Value(testKey{})})
+	}
+}
+
+func TestCommandObserver(t *testing.T) {
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/click") {
+			writeError(w, 400, "element click intercepted")
+			return
+		}
+		writeValue(w, "home")
+	})
+	var o recordingObserver
+	d.Observer = &o
+	s := fakeSession(d)
+	s.SetContext(context.WithValue(context.Background(), testKey{}, "TestCommandObserver"))
+	s.Title()
+	(WebElement{s, "e1"}).Click()
+
+	if len(o) != 2 {
+		t.Fatalf("got %d commands, want 2", len(o))
+	}
+	title, click := o[0], o[1]
+	if title.Name != "GET /session/{}/title" || title.SessionID != "s1" || title.Status != 200 || title.Attempts != 1 {
+		t.Errorf("wrong title command: %+v", title)
+	}
+	if title.test != "TestCommandObserver" {
+		t.Errorf("the context of the session was not passed to the observer")
+	}
+	var cerr *CommandError
+	if click.Name != "POST /session/{}/element/{}/click" || click.Status != 400 || !errors.As(click.Err, &cerr) {
+		t.Errorf("wrong click command: %+v", click)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s.SetContext(ctx)
+	if _, err := s.Title(); !errors.Is(err, context.Canceled) {
+		t.Errorf("command of a canceled context: got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package otelwebdriver traces and measures the commands sent to a WebDriver
+// with OpenTelemetry.
+//
+// Every command becomes a client span, a child of the span in the context of
+// its session, and is counted and timed per command:
+//
+//	observer, err := otelwebdriver.NewObserver()
+//	if err != nil {
+//		return err
+//	}
+//	driver.Observer = observer
+//	session, err := driver.NewSession(desired, nil)
+//	...
+//	ctx, span := tracer.Start(ctx, t.Name())
+//	defer span.End()
+//	session.SetContext(ctx)
+//
+// It is a module of its own, the webdriver package has no dependencies.
+package otelwebdriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/tooolbox/webdriver"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the spans and metrics.
+const ScopeName = "github.com/tooolbox/webdriver/otelwebdriver"
+
+// Attribute keys of the spans and metrics.
+const (
+	// CommandKey is the name of the command, e.g. "POST /session/{}/element".
+	CommandKey = attribute.Key("webdriver.command")
+	// SessionIDKey is the id of the session of the command, on spans only.
+	SessionIDKey = attribute.Key("webdriver.session.id")
+	// AttemptsKey is the number of times the command was sent, on spans only.
+	AttemptsKey = attribute.Key("webdriver.attempts")
+	// StatusCodeKey is the HTTP status code of the response.
+	StatusCodeKey = attribute.Key("http.response.status_code")
+	// ErrorTypeKey is the type of the error of a failed command: the error
+	// code of the driver, e.g. "no such element", "transport", "timeout" or
+	// "canceled".
+	ErrorTypeKey = attribute.Key("error.type")
+)
+
+type config struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+}
+
+// Option configures an Observer.
+type Option func(*config)
+
+// WithTracerProvider sets the provider of the tracer of the spans. Default:
+// the global provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) { c.tracerProvider = tp }
+}
+
+// WithMeterProvider sets the provider of the meter of the metrics. Default:
+// the global provider.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) { c.meterProvider = mp }
+}
+
+// Observer is a webdriver.CommandObserver that records a span and metrics
+// for every command:
+//
+//   - webdriver.client.commands, the number of commands
+//   - webdriver.client.command.duration, the duration of the commands, in
+//     seconds, retries included
+type Observer struct {
+	tracer   trace.Tracer
+	commands metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+var _ webdriver.CommandObserver = (*Observer)(nil)
+
+// NewObserver returns an observer that uses the providers of opts.
+func NewObserver(opts ...Option) (*Observer, error) {
+	c := config{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	meter := c.meterProvider.Meter(ScopeName)
+	commands, err := meter.Int64Counter("webdriver.client.commands",
+		metric.WithDescription("Number of commands sent to the driver."),
+		metric.WithUnit("{command}"))
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("webdriver.client.command.duration",
+		metric.WithDescription("Duration of the commands sent to the driver."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	return &Observer{
+		tracer:   c.tracerProvider.Tracer(ScopeName),
+		commands: commands,
+		duration: duration,
+	}, nil
+}
+
+// StartCommand starts the span of c, which ends with the command.
+func (o *Observer) StartCommand(ctx context.Context, c webdriver.Command) func(webdriver.CommandResult) {
+	start := time.Now()
+	attrs := []attribute.KeyValue{CommandKey.String(c.Name)}
+	ctx, span := o.tracer.Start(ctx, c.Name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attrs...),
+		trace.WithAttributes(
+			attribute.String("http.request.method", c.Method),
+			attribute.String("url.path", c.Path)))
+	if c.SessionID != "" {
+		span.SetAttributes(SessionIDKey.String(c.SessionID))
+	}
+	return func(r webdriver.CommandResult) {
+		end := time.Now()
+		if r.Status != 0 {
+			attrs = append(attrs, StatusCodeKey.Int(r.Status))
+		}
+		if r.Err != nil {
+			attrs = append(attrs, ErrorTypeKey.String(errorType(r.Err)))
+			span.RecordError(r.Err, trace.WithTimestamp(end))
+			span.SetStatus(codes.Error, r.Err.Error())
+		}
+		span.SetAttributes(attrs[1:]...)
+		span.SetAttributes(AttemptsKey.Int(r.Attempts))
+		span.End(trace.WithTimestamp(end))
+
+		set := metric.WithAttributes(attrs...)
+		o.commands.Add(ctx, 1, set)
+		o.duration.Record(ctx, end.Sub(start).Seconds(), set)
+	}
+}
+
+// errorType returns the value of the error.type attribute of err.
+func errorType(err error) string {
+	var cerr *webdriver.CommandError
+	switch {
+	case errors.As(err, &cerr):
+		if cerr.ErrorType != "" {
+			return cerr.ErrorType
+		}
+		return fmt.Sprint(cerr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return "transport"
+	}
+	return fmt.Sprintf("%T", err)
+}
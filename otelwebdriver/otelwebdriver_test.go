@@ -0,0 +1,114 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package otelwebdriver
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/tooolbox/webdriver"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func command(method, path, params string, status int, value interface{}) webdriver.TraceCommand {
+	response, _ := json.Marshal(map[string]interface{}{"value": value})
+	c := webdriver.TraceCommand{Method: method, Path: path, Status: status, Response: response}
+	if params != "" {
+		c.Params = json.RawMessage(params)
+	}
+	return c
+}
+
+func TestObserver(t *testing.T) {
+	driver := webdriver.NewReplayDriver([]webdriver.TraceCommand{
+		command("POST", "/session", `{"capabilities":{"alwaysMatch":{}},"desiredCapabilities":{},"requiredCapabilities":null}`, 200,
+			map[string]interface{}{"sessionId": "s1", "capabilities": map[string]interface{}{"browserName": "fake"}}),
+		command("GET", "/session/s1/title", "", 200, "Login"),
+		command("POST", "/session/s1/element", `{"using":"id","value":"missing"}`, 404,
+			map[string]interface{}{"error": "no such element", "message": "missing"}),
+	})
+	spans := tracetest.NewSpanRecorder()
+	reader := sdkmetric.NewManualReader()
+	observer, err := NewObserver(
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))),
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	driver.Observer = observer
+	session, err := driver.NewSession(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	ctx, parent := tp.Tracer("test").Start(context.Background(), "TestLogin")
+	session.SetContext(ctx)
+	if _, err := session.Title(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.FindElement(webdriver.ID, "missing"); err == nil {
+		t.Fatal("found a missing element")
+	}
+	parent.End()
+
+	ended := spans.Ended()
+	if len(ended) != 4 {
+		t.Fatalf("got %d spans, want 4", len(ended))
+	}
+	title, find := ended[1], ended[2]
+	if title.Name() != "GET /session/{}/title" || title.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span %q is not a child of the test span", title.Name())
+	}
+	if ended[0].Parent().IsValid() {
+		t.Errorf("the span of the new session has a parent")
+	}
+	want := map[attribute.Key]attribute.Value{
+		SessionIDKey:  attribute.StringValue("s1"),
+		StatusCodeKey: attribute.IntValue(404),
+		ErrorTypeKey:  attribute.StringValue("no such element"),
+		AttemptsKey:   attribute.IntValue(1),
+	}
+	got := map[attribute.Key]attribute.Value{}
+	for _, kv := range find.Attributes() {
+		got[kv.Key] = kv.Value
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("span attribute %s is %v, want %v", k, got[k].Emit(), v.Emit())
+		}
+	}
+	if find.Status().Code != codes.Error {
+		t.Errorf("failed command has status %v", find.Status())
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int64{}
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		switch data := m.Data.(type) {
+		case metricdata.Sum[int64]:
+			for _, p := range data.DataPoints {
+				command, _ := p.Attributes.Value(CommandKey)
+				counts[command.AsString()] += p.Value
+			}
+		case metricdata.Histogram[float64]:
+			if len(data.DataPoints) != 3 {
+				t.Errorf("got %d duration series, want 3", len(data.DataPoints))
+			}
+		}
+	}
+	if counts["GET /session/{}/title"] != 1 || counts["POST /session/{}/element"] != 1 {
+		t.Errorf("wrong command counts: %v", counts)
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrPoolClosed is returned by SessionPool.Lease after Drain.
+var ErrPoolClosed = errors.New("session pool: closed")
+
+// PoolOptions configures a SessionPool.
+type PoolOptions struct {
+	// MaxSize is the largest number of sessions open at once. Default: 4.
+	MaxSize int
+	// Desired and Required are the capabilities of the sessions.
+	Desired, Required Capabilities
+	// Reset cleans a session returned to the pool. Default: ResetSession.
+	Reset func(s *Session) error
+	// HealthCheck returns an error if a session can't be used anymore, e.g.
+	// because its browser crashed. It runs before a session is leased again.
+	// Default: the current URL can be read.
+	HealthCheck func(s *Session) error
+}
+
+// SessionPool shares browser sessions between tests: a session is leased,
+// used by one test at a time, reset and returned to the pool. Sessions are
+// created on demand, up to the size of the pool, and replaced when they
+// fail their health check. A SessionPool is safe for concurrent use.
+//
+//	pool := webdriver.NewSessionPool(driver, webdriver.PoolOptions{MaxSize: 8})
+//	defer pool.Drain(context.Background())
+//
+//	session, err := pool.Lease(ctx)
+//	defer pool.Return(session)
+type SessionPool struct {
+	wd   WebDriver
+	opts PoolOptions
+	// a token for every session that may be open
+	tokens chan struct{}
+
+	mu     sync.Mutex
+	idle   []*Session
+	closed bool
+}
+
+// NewSessionPool returns an empty pool of sessions of wd, which must be started.
+func NewSessionPool(wd WebDriver, opts PoolOptions) *SessionPool {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = 4
+	}
+	if opts.Reset == nil {
+		opts.Reset = ResetSession
+	}
+	if opts.HealthCheck == nil {
+		opts.HealthCheck = func(s *Session) error {
+			_, err := s.GetUrl()
+			return err
+		}
+	}
+	p := &SessionPool{wd: wd, opts: opts, tokens: make(chan struct{}, opts.MaxSize)}
+	for i := 0; i < opts.MaxSize; i++ {
+		p.tokens <- struct{}{}
+	}
+	return p
+}
+
+// Lease returns a session for the exclusive use of the caller, who must give
+// it back with Return or Discard. It waits for a session to be returned if
+// all are leased, until ctx is done.
+func (p *SessionPool) Lease(ctx context.Context) (*Session, error) {
+	select {
+	case <-p.tokens:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			p.tokens <- struct{}{}
+			return nil, ErrPoolClosed
+		}
+		var s *Session
+		if n := len(p.idle); n > 0 {
+			s, p.idle = p.idle[n-1], p.idle[:n-1]
+		}
+		p.mu.Unlock()
+		if s == nil {
+			break
+		}
+		if err := p.opts.HealthCheck(s); err != nil {
+			debugprint("session pool: replacing session " + s.Id + ": " + err.Error())
+			s.Delete()
+			continue
+		}
+		return s, nil
+	}
+	s, err := p.wd.NewSession(p.opts.Desired, p.opts.Required)
+	if err != nil {
+		p.tokens <- struct{}{}
+		return nil, err
+	}
+	return s, nil
+}
+
+// Return gives back a leased session. The session is reset before it is
+// leased again; if it can't be reset, it is deleted.
+func (p *SessionPool) Return(s *Session) error {
+	defer func() { p.tokens <- struct{}{} }()
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return s.Delete()
+	}
+	if err := p.opts.Reset(s); err != nil {
+		s.Delete()
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return s.Delete()
+	}
+	p.idle = append(p.idle, s)
+	return nil
+}
+
+// Discard deletes a leased session that must not be used again, making room
+// for a new one.
+func (p *SessionPool) Discard(s *Session) error {
+	defer func() { p.tokens <- struct{}{} }()
+	return s.Delete()
+}
+
+// Drain closes the pool: leases fail, idle sessions are deleted and leased
+// sessions are deleted when they are returned. Drain waits for all the
+// sessions to be returned, until ctx is done.
+func (p *SessionPool) Drain(ctx context.Context) error {
+	p.mu.Lock()
+	p.closed = true
+	idle := p.idle
+	p.idle = nil
+	p.mu.Unlock()
+	var firstErr error
+	for _, s := range idle {
+		if err := s.Delete(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	for i := 0; i < p.opts.MaxSize; i++ {
+		select {
+		case <-p.tokens:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	//leave the tokens, leases of a closed pool release theirs
+	for i := 0; i < p.opts.MaxSize; i++ {
+		p.tokens <- struct{}{}
+	}
+	return firstErr
+}
+
+// ResetSession brings a session back to a blank state: it dismisses an open
+// alert, closes the windows but one, clears the cookies and the storage of
+// the current page and navigates to about:blank. Cookies are only visible
+// to the page of their domain, only those of the current page are deleted.
+func ResetSession(s *Session) error {
+	//an alert blocks every other command
+	s.DismissAlert()
+	handles, err := s.WindowHandles()
+	if err != nil {
+		return err
+	}
+	if len(handles) > 1 {
+		for _, h := range handles[1:] {
+			if err := h.SwitchTo(); err != nil {
+				return err
+			}
+			if err := s.CloseCurrentWindow(); err != nil {
+				return err
+			}
+		}
+	}
+	if len(handles) > 0 {
+		if err := handles[0].SwitchTo(); err != nil {
+			return err
+		}
+	}
+	if err := s.FocusOnFrame(nil); err != nil {
+		return err
+	}
+	if err := s.DeleteCookies(); err != nil {
+		return err
+	}
+	script := "try { window.localStorage.clear(); window.sessionStorage.clear(); } catch (e) {}"
+	if _, err := s.ExecuteScript(script, nil); err != nil {
+		return err
+	}
+	return s.Url("about:blank")
+}
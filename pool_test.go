@@ -0,0 +1,108 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionPool(t *testing.T) {
+	var mu sync.Mutex
+	created, deleted, blanked := 0, map[string]bool{}, map[string]int{}
+	dead := map[string]bool{}
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/session"), "/")
+		if len(parts) < 2 {
+			created++
+			id := fmt.Sprintf("s%d", created)
+			writeValue(w, map[string]interface{}{"sessionId": id, "capabilities": map[string]interface{}{"browserName": "fake"}})
+			return
+		}
+		id := parts[1]
+		if dead[id] || deleted[id] {
+			writeError(w, 404, "invalid session id")
+			return
+		}
+		switch {
+		case r.Method == "DELETE" && len(parts) == 2:
+			deleted[id] = true
+			writeValue(w, nil)
+		case strings.HasSuffix(r.URL.Path, "/alert/dismiss"):
+			writeError(w, 404, "no such alert")
+		case strings.HasSuffix(r.URL.Path, "/window/handles"):
+			writeValue(w, []string{"w1", "w2"})
+		case r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/url"):
+			blanked[id]++
+			writeValue(w, nil)
+		default:
+			writeValue(w, nil)
+		}
+	})
+	pool := NewSessionPool(d, PoolOptions{MaxSize: 2, Desired: Capabilities{"browserName": "fake"}})
+	ctx := context.Background()
+	s1, err := pool.Lease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := pool.Lease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	short, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := pool.Lease(short); err != context.DeadlineExceeded {
+		t.Fatalf("lease of a full pool: got %v, want a deadline error", err)
+	}
+
+	if err := pool.Return(s1); err != nil {
+		t.Fatal(err)
+	}
+	if blanked[s1.Id] != 1 {
+		t.Errorf("session not reset on return")
+	}
+	again, err := pool.Lease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Id != s1.Id {
+		t.Errorf("leased %s, want the idle session %s", again.Id, s1.Id)
+	}
+
+	//a session whose browser died is replaced
+	pool.Return(again)
+	mu.Lock()
+	dead[s1.Id] = true
+	mu.Unlock()
+	s3, err := pool.Lease(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s3.Id != "s3" {
+		t.Errorf("leased %s, want a new session s3", s3.Id)
+	}
+
+	pool.Return(s3)
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		pool.Return(s2)
+	}()
+	if err := pool.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !deleted["s2"] || !deleted["s3"] {
+		t.Errorf("sessions not deleted by Drain: %v", deleted)
+	}
+	if _, err := pool.Lease(ctx); err != ErrPoolClosed {
+		t.Errorf("lease of a drained pool: got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"os"
+	"os/exec"
+)
+
+// applies the process settings of a driver to its command: env is added to
+// the inherited environment, overriding it, dir is the working directory and
+// group starts the driver in a new process group, shared by the browsers it
+// starts.
+func setupProcess(cmd *exec.Cmd, env []string, dir string, group bool) {
+	if len(env) > 0 {
+		if cmd.Env == nil {
+			cmd.Env = os.Environ()
+		}
+		cmd.Env = append(cmd.Env, env...)
+	}
+	cmd.Dir = dir
+	if group {
+		setProcessGroup(cmd)
+	}
+}
+
+// sends sig to the process of cmd, or to its process group if group is true.
+func signalProcess(cmd *exec.Cmd, group bool, sig os.Signal) error {
+	if !group {
+		return cmd.Process.Signal(sig)
+	}
+	return signalProcessGroup(cmd.Process.Pid, sig)
+}
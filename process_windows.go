@@ -0,0 +1,28 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= syscall.CREATE_NEW_PROCESS_GROUP
+}
+
+// Windows has no signals: the process tree is terminated, forcibly for os.Kill.
+func signalProcessGroup(pid int, sig os.Signal) error {
+	args := []string{"/T", "/PID", strconv.Itoa(pid)}
+	if sig == os.Kill {
+		args = append(args, "/F")
+	}
+	return exec.Command("taskkill", args...).Run()
+}
@@ -0,0 +1,77 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"errors"
+	"strings"
+	"sync"
+)
+
+// sessionRegistry keeps the sessions created by a driver, in the order they
+// were created, until they are deleted. W3C drivers removed GET /sessions,
+// the registry replaces it.
+type sessionRegistry struct {
+	mu       sync.Mutex
+	sessions []*Session
+}
+
+func newSessionRegistry() *sessionRegistry {
+	return &sessionRegistry{}
+}
+
+// adds s to the registry. A nil registry keeps nothing.
+func (r *sessionRegistry) add(s *Session) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions = append(r.sessions, s)
+}
+
+// removes the session with the given id.
+func (r *sessionRegistry) remove(id string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, s := range r.sessions {
+		if s.Id == id {
+			r.sessions = append(r.sessions[:i:i], r.sessions[i+1:]...)
+			return
+		}
+	}
+}
+
+// returns a copy of the sessions in the registry.
+func (r *sessionRegistry) list() []*Session {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*Session(nil), r.sessions...)
+}
+
+// returns true if err is a CommandError caused by a session that doesn't exist, e.g. already deleted.
+func isNoSession(err error) bool {
+	var cerr *CommandError
+	if !errors.As(err, &cerr) {
+		return false
+	}
+	return cerr.StatusCode == NoSuchDriver || cerr.ErrorType == "invalid session id"
+}
+
+// returns true if err is a CommandError of a command the driver doesn't know, e.g. GET /sessions of a W3C driver.
+func isUnknownCommand(err error) bool {
+	var cerr *CommandError
+	if !errors.As(err, &cerr) {
+		return false
+	}
+	//the error type is the one of the HTTP status if the response has none
+	return cerr.StatusCode == UnknownCommand || cerr.ErrorType == "unknown command" || strings.HasPrefix(cerr.ErrorType, "404:")
+}
@@ -0,0 +1,103 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSessionRegistry(t *testing.T) {
+	var mu sync.Mutex
+	created, deleted := 0, map[string]bool{}
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/sessions":
+			writeError(w, 404, "unknown command")
+		case r.URL.Path == "/session":
+			created++
+			id := fmt.Sprintf("s%d", created)
+			writeValue(w, map[string]interface{}{"sessionId": id, "capabilities": map[string]interface{}{"browserName": "fake"}})
+		case r.Method == "DELETE":
+			id := strings.TrimPrefix(r.URL.Path, "/session/")
+			if deleted[id] || id == "s2" {
+				//s2 was closed by the driver
+				writeError(w, 404, "invalid session id")
+				return
+			}
+			deleted[id] = true
+			writeValue(w, nil)
+		default:
+			writeValue(w, nil)
+		}
+	})
+	ids := func() []string {
+		sessions, err := d.Sessions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, s := range sessions {
+			if s.wd != d {
+				t.Errorf("session %s of another driver", s.Id)
+			}
+			ids = append(ids, s.Id)
+		}
+		return ids
+	}
+
+	var sessions []*Session
+	for i := 0; i < 3; i++ {
+		s, err := d.NewSession(nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, s)
+	}
+	if got := fmt.Sprint(ids()); got != "[s1 s2 s3]" {
+		t.Errorf("got sessions %s", got)
+	}
+	if err := sessions[0].Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if got := fmt.Sprint(ids()); got != "[s2 s3]" {
+		t.Errorf("after delete got sessions %s", got)
+	}
+
+	//a session unknown to the driver is forgotten, but the error is returned
+	if err := d.DeleteAllSessions(); !isNoSession(err) {
+		t.Errorf("got %v, want an invalid session error", err)
+	}
+	if !deleted["s3"] {
+		t.Error("s3 not deleted after the error on s2")
+	}
+	if got := ids(); len(got) != 0 {
+		t.Errorf("sessions left: %s", got)
+	}
+}
+
+func TestSessionsError(t *testing.T) {
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, 500, "unknown error")
+	})
+	//only a driver without the command falls back to the registry
+	if sessions, err := d.Sessions(); err == nil {
+		t.Errorf("got sessions %v, want the error of the driver", sessions)
+	}
+	for _, err := range []error{
+		&CommandError{StatusCode: UnknownCommand},
+		&CommandError{StatusCode: -1, ErrorType: "unknown command"},
+		&CommandError{StatusCode: -1, ErrorType: "404: Unknown command/Resource Not Found"},
+	} {
+		if !isUnknownCommand(err) {
+			t.Errorf("%v is not an unknown command", err)
+		}
+	}
+}
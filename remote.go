@@ -0,0 +1,43 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import "strings"
+
+// RemoteDriver is a WebDriver already running, e.g. a driver started by hand
+// or a Selenium server, that is attached to by URL. Start and Stop do
+// nothing, Stop leaves the sessions alive.
+type RemoteDriver struct {
+	WebDriverCore
+}
+
+// NewRemoteDriver returns a driver that sends the commands to the server at
+// url, e.g. "http://localhost:4444/wd/hub".
+func NewRemoteDriver(url string) *RemoteDriver {
+	d := &RemoteDriver{}
+	d.url = strings.TrimSuffix(url, "/")
+	d.registry = newSessionRegistry()
+	return d
+}
+
+func (d *RemoteDriver) NewSession(desired, required Capabilities) (*Session, error) {
+	session, err := d.newSession(desired, required)
+	if err != nil {
+		return nil, err
+	}
+	session.wd = d
+	return session, nil
+}
+
+func (d *RemoteDriver) Sessions() ([]*Session, error) {
+	sessions, err := d.sessions()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].wd = d
+	}
+	return sessions, nil
+}
@@ -0,0 +1,40 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRemoteDriver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/wd/hub/session":
+			writeValue(w, map[string]interface{}{"sessionId": "r1", "capabilities": map[string]interface{}{"browserName": "fake"}})
+		case "/wd/hub/session/r1/title":
+			writeValue(w, "remote")
+		default:
+			writeError(w, 404, "unknown command")
+		}
+	}))
+	defer srv.Close()
+
+	d := NewRemoteDriver(srv.URL + "/wd/hub/")
+	if err := d.Start(); err != nil {
+		t.Fatal(err)
+	}
+	s, err := d.NewSession(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title, err := s.Title(); err != nil || title != "remote" {
+		t.Errorf("got title %q, %v", title, err)
+	}
+	if sessions, err := d.Sessions(); err != nil || len(sessions) != 1 || sessions[0] != s {
+		t.Errorf("got sessions %v, %v", sessions, err)
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// RetryPolicy retries the commands that fail with a transient error, e.g.
+// because the driver is restarting or the connection was reset. Only the
+// idempotent commands are retried: a command like Click may have run before
+// its connection failed. Set it on a driver before its sessions are created:
+//
+//	driver.Retry = &webdriver.RetryPolicy{MaxAttempts: 5}
+//
+// Every attempt is printed in the debug log and recorded by the trace of the
+// driver, with its attempt number.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a command is sent, the first
+	// included. Default: 3.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, doubled at every retry up
+	// to MaxBackoff. Defaults: 100ms and 5s.
+	Backoff, MaxBackoff time.Duration
+	// Jitter is the fraction of the delay chosen at random, between 0 and 1,
+	// so that clients failing together don't retry together. Default: 0.5; a
+	// negative value disables it.
+	Jitter float64
+	// Retryable returns true if a command that failed with err may succeed if
+	// it is sent again. Default: IsTransient.
+	Retryable func(err error) bool
+	// Idempotent returns true if a command can be sent twice with the same
+	// effect as once; path is relative to the driver, e.g.
+	// "/session/1234/element". Default: IsIdempotent.
+	Idempotent func(method, path string) bool
+}
+
+// delay returns the time to wait before the retry attempt, starting from 1.
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d, max := p.Backoff, p.MaxBackoff
+	if d <= 0 {
+		d = 100 * time.Millisecond
+	}
+	if max <= 0 {
+		max = 5 * time.Second
+	}
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	jitter := p.Jitter
+	if jitter == 0 {
+		jitter = 0.5
+	}
+	if jitter > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		d -= time.Duration(jitter * rand.Float64() * float64(d))
+	}
+	return d
+}
+
+// an error of a command that must not be sent again whatever the error,
+// e.g. because its response was partially read
+type finalError struct{ error }
+
+// retry calls f until it succeeds, fails with an error that is not retryable,
+// or the attempts are over. f gets the number of the attempt, from 0. A nil
+// policy calls f once. The wait before a retry ends early with the error of
+// ctx if it is done.
+func (p *RetryPolicy) retry(ctx context.Context, method, path string, f func(attempt int) error) error {
+	if p == nil {
+		return unwrapFinal(f(0))
+	}
+	idempotent, retryable := p.Idempotent, p.Retryable
+	if idempotent == nil {
+		idempotent = IsIdempotent
+	}
+	if retryable == nil {
+		retryable = IsTransient
+	}
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	if !idempotent(method, path) {
+		attempts = 1
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			d := p.delay(attempt)
+			debugprint(fmt.Sprintf("retry %d/%d of %s %s in %v: %v", attempt, attempts-1, method, path, d, err))
+			select {
+			case <-time.After(d):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		err = f(attempt)
+		if _, final := err.(finalError); final || err == nil || !retryable(err) {
+			return unwrapFinal(err)
+		}
+	}
+	return err
+}
+
+func unwrapFinal(err error) error {
+	if ferr, ok := err.(finalError); ok {
+		return ferr.error
+	}
+	return err
+}
+
+// the POST commands that can be retried, by the last element of their path
+var idempotentCommands = map[string]bool{
+	"element":  true,
+	"elements": true,
+	"timeouts": true,
+	"window":   true,
+}
+
+// IsIdempotent returns true for the commands that can be sent again after a
+// failure with no further effect: the GET commands, the DELETE commands but
+// closing the window and deleting the session, finding elements, setting the
+// timeouts and switching to a window by handle. It is false for the commands
+// that act on the page, e.g. Click, SendKeys or ExecuteScript, for navigation
+// and for the creation of a session.
+func IsIdempotent(method, path string) bool {
+	parts := strings.Split(strings.TrimSuffix(path, "/"), "/")
+	switch method {
+	case "GET":
+		return true
+	case "DELETE":
+		//closing the window again would close the next one, a session
+		//deleted again is an error: /session/{id} and /session/{id}/window
+		return len(parts) > 4 || len(parts) == 4 && parts[3] != "window"
+	case "POST":
+		//a command of a session: /session/{id}/...
+		return len(parts) > 3 && idempotentCommands[parts[len(parts)-1]]
+	}
+	return false
+}
+
+// IsTransient returns true for the errors that may not happen again: the
+// connection to the driver was refused, reset or timed out, or the driver
+// returned an unknown error, which chromedriver does e.g. when the browser
+// doesn't respond.
+func IsTransient(err error) bool {
+	var cerr *CommandError
+	if errors.As(err, &cerr) {
+		return cerr.StatusCode == UnknownError || cerr.ErrorType == "unknown error"
+	}
+	var uerr *url.Error
+	if !errors.As(err, &uerr) {
+		return false
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) && nerr.Timeout() {
+		return true
+	}
+	return isConnectionError(err) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
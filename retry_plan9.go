@@ -0,0 +1,17 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"errors"
+	"net"
+)
+
+// returns true if the connection to the driver failed. Plan 9 has no errno:
+// any failed dial is taken as refused.
+func isConnectionError(err error) bool {
+	var oerr *net.OpError
+	return errors.As(err, &oerr) && oerr.Op == "dial"
+}
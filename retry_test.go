@@ -0,0 +1,124 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[string]int{}
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls[r.URL.Path]++
+		n := calls[r.URL.Path]
+		mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/title") && n == 1:
+			//the connection is reset with no response
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		case strings.HasSuffix(r.URL.Path, "/title") && n == 2:
+			writeError(w, 500, "unknown error")
+		case strings.HasSuffix(r.URL.Path, "/title"):
+			writeValue(w, "home")
+		case strings.HasSuffix(r.URL.Path, "/click"):
+			writeError(w, 500, "unknown error")
+		case strings.HasSuffix(r.URL.Path, "/elements"):
+			writeError(w, 404, "no such element")
+		}
+	})
+	trace, err := NewTraceRecorder(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace.Keep = 10
+	d.Trace = trace
+	d.Retry = &RetryPolicy{Backoff: time.Millisecond}
+	s := fakeSession(d)
+
+	title, err := s.Title()
+	if err != nil || title != "home" {
+		t.Fatalf("got %q, %v, want the title after two retries", title, err)
+	}
+	last := trace.Last()
+	if len(last) != 3 || last[0].Error == "" || last[1].Status != 500 || last[2].Attempt != 2 {
+		t.Errorf("attempts not traced: %v", last)
+	}
+
+	if err := (WebElement{s, "e1"}).Click(); err == nil {
+		t.Error("click succeeded")
+	}
+	if n := calls["/session/s1/element/e1/click"]; n != 1 {
+		t.Errorf("click sent %d times, want 1", n)
+	}
+	s.FindElements(ID, "missing")
+	if n := calls["/session/s1/elements"]; n != 1 {
+		t.Errorf("a missing element was looked for %d times, want 1", n)
+	}
+}
+
+func TestRetryContext(t *testing.T) {
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, 500, "unknown error")
+	})
+	d.Retry = &RetryPolicy{Backoff: time.Minute, Jitter: -1}
+	s := fakeSession(d)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	s.SetContext(ctx)
+	start := time.Now()
+	if _, err := s.Title(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the error of the context", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("the backoff wasn't interrupted, returned after %v", elapsed)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := &RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond, Jitter: -1}
+	for attempt, want := range []time.Duration{1: 100, 2: 200, 3: 300, 4: 300} {
+		if got := p.delay(attempt); attempt > 0 && got != want*time.Millisecond {
+			t.Errorf("delay of attempt %d is %v, want %v", attempt, got, want*time.Millisecond)
+		}
+	}
+	p.Jitter = 0.5
+	for i := 0; i < 20; i++ {
+		if d := p.delay(1); d < 50*time.Millisecond || d > 100*time.Millisecond {
+			t.Fatalf("delay with jitter %v out of range", d)
+		}
+	}
+}
+
+func TestIsIdempotent(t *testing.T) {
+	for _, c := range []struct {
+		method, path string
+		want         bool
+	}{
+		{"GET", "/session/1/title", true},
+		{"DELETE", "/session/1/cookie", true},
+		{"DELETE", "/session/1/cookie/name", true},
+		{"DELETE", "/session/1/window", false},
+		{"DELETE", "/session/1", false},
+		{"POST", "/session/1/element", true},
+		{"POST", "/session/1/element/2/elements", true},
+		{"POST", "/session/1/element/2/click", false},
+		{"POST", "/session/1/url", false},
+		{"POST", "/session", false},
+	} {
+		if got := IsIdempotent(c.method, c.path); got != c.want {
+			t.Errorf("IsIdempotent(%s %s) = %v, want %v", c.method, c.path, got, c.want)
+		}
+	}
+}
@@ -24,8 +24,18 @@ type SafariDriver struct {
 	BaseUrl string
 	//The number of threads to use for handling HTTP requests. Default: 4
 	Threads int
-	//The path to use for the SafariDriver server log. Default: ./Safaridriver.log
+	//The path of the safaridriver log. safaridriver has no log file switch, its output is written to LogPath if LogFile is not set. Default: ""
 	LogPath string
+	//Log verbosely (--diagnose, the log is in ~/Library/Logs/com.apple.WebDriver).
+	Verbose bool
+	//Extra arguments of the driver, after those of the settings above.
+	ExtraArgs []string
+	//Environment of the driver, "KEY=value" entries added to the inherited one, which they override. Default: nil
+	Env []string
+	//Working directory of the driver. Default: "", the current directory
+	Dir string
+	//Start the driver in a new process group, which includes the browsers it starts, and signal the whole group in Stop and Signal.
+	ProcessGroup bool
 	// Log file to dump Safaridriver stdout/stderr. If "" send to terminal. Default: ""
 	LogFile string
 	// Start method fails if Safaridriver doesn't start in less than StartTimeout. Default 20s.
@@ -43,6 +53,7 @@ type SafariDriver struct {
 func NewSafariDriver(path string) *SafariDriver {
 	d := &SafariDriver{}
 	d.path = path
+	d.registry = newSessionRegistry()
 	d.Port = 9516
 	d.BaseUrl = ""
 	d.Threads = 4
@@ -78,31 +89,47 @@ func (d *SafariDriver) Start() error {
 	// 	switches = append(switches, "-url-base="+d.BaseUrl)
 	// }
 
+	if d.Verbose {
+		switches = append(switches, "--diagnose")
+	}
+	switches = append(switches, d.ExtraArgs...)
+
 	d.cmd = exec.Command(d.path, switches...)
+	setupProcess(d.cmd, d.Env, d.Dir, d.ProcessGroup)
 	stdout, err := d.cmd.StdoutPipe()
 	if err != nil {
+		d.abortStart()
 		return errors.New(csferr + err.Error())
 	}
 	stderr, err := d.cmd.StderrPipe()
 	if err != nil {
+		d.abortStart()
 		return errors.New(csferr + err.Error())
 	}
 	if err := d.cmd.Start(); err != nil {
+		d.abortStart()
 		return errors.New(csferr + err.Error())
 	}
-	if d.LogFile != "" {
+	logFile := d.LogFile
+	if logFile == "" {
+		logFile = d.LogPath
+	}
+	d.logs = newRingBuffer(driverLogSize)
+	if logFile != "" {
 		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
-		d.logFile, err = os.OpenFile(d.LogFile, flags, 0640)
+		d.logFile, err = os.OpenFile(logFile, flags, 0640)
 		if err != nil {
+			d.abortStart()
 			return err
 		}
-		go io.Copy(d.logFile, stdout)
-		go io.Copy(d.logFile, stderr)
+		go io.Copy(io.MultiWriter(d.logFile, d.logs), stdout)
+		go io.Copy(io.MultiWriter(d.logFile, d.logs), stderr)
 	} else {
-		go io.Copy(os.Stdout, stdout)
-		go io.Copy(os.Stderr, stderr)
+		go io.Copy(io.MultiWriter(os.Stdout, d.logs), stdout)
+		go io.Copy(io.MultiWriter(os.Stderr, d.logs), stderr)
 	}
 	if err = probePort(d.Port, d.StartTimeout); err != nil {
+		d.abortStart()
 		return err
 	}
 	return nil
@@ -115,13 +142,36 @@ func (d *SafariDriver) Stop() error {
 	defer func() {
 		d.cmd = nil
 	}()
-	d.cmd.Process.Signal(os.Interrupt)
+	//close the browsers before the driver is gone
+	d.DeleteAllSessions()
+	signalProcess(d.cmd, d.ProcessGroup, os.Interrupt)
 	if d.logFile != nil {
 		d.logFile.Close()
 	}
 	return nil
 }
 
+//undo a failed Start: kill the driver if it started, close the log file and forget the process, so that Start can be called again.
+func (d *SafariDriver) abortStart() {
+	if d.cmd.Process != nil {
+		signalProcess(d.cmd, d.ProcessGroup, os.Kill)
+		go d.cmd.Wait()
+	}
+	if d.logFile != nil {
+		d.logFile.Close()
+		d.logFile = nil
+	}
+	d.cmd = nil
+}
+
+//Signal sends sig to the driver, or to its process group if ProcessGroup is set.
+func (d *SafariDriver) Signal(sig os.Signal) error {
+	if d.cmd == nil {
+		return errors.New("signal failed: Safaridriver not running")
+	}
+	return signalProcess(d.cmd, d.ProcessGroup, sig)
+}
+
 func (d *SafariDriver) NewSession(desired, required Capabilities) (*Session, error) {
 	//id, capabs, err := d.newSession(desired, required)
 	//return &Session{id, capabs, d}, err
@@ -133,7 +183,7 @@ func (d *SafariDriver) NewSession(desired, required Capabilities) (*Session, err
 	return session, nil
 }
 
-func (d *SafariDriver) Sessions() ([]Session, error) {
+func (d *SafariDriver) Sessions() ([]*Session, error) {
 	sessions, err := d.sessions()
 	if err != nil {
 		return nil, err
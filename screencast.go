@@ -0,0 +1,425 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScreencastOptions configures a Screencast.
+type ScreencastOptions struct {
+	// FPS is the highest frame rate recorded. Default: 2.
+	FPS float64
+	// Quality of the JPEG frames of AVI files and of the CDP screencast, from 1 to 100. Default: 75.
+	Quality int
+	// Polling records periodic screenshots even if the CDP screencast of the
+	// browser is available.
+	Polling bool
+}
+
+// Screencast records the frames of a session in the background and encodes
+// them into an animated GIF or an MJPEG AVI file. A Screencast is safe for
+// concurrent use.
+type Screencast struct {
+	opts ScreencastOptions
+	enc  frameEncoder
+	file *os.File
+
+	mu     sync.Mutex
+	frames int
+	last   time.Time
+	err    error
+
+	stop     func()
+	stopOnce sync.Once
+}
+
+// StartScreencast starts recording the session into filename, whose
+// extension is .gif or .avi. Frames come from the CDP screencast on Chromium
+// based browsers started with a debuggerAddress, and from periodic
+// screenshots elsewhere. GIF frames are held in memory until Stop, which
+// suits short recordings; AVI frames are written as they come.
+func (s *Session) StartScreencast(filename string, opts ScreencastOptions) (*Screencast, error) {
+	if opts.FPS <= 0 {
+		opts.FPS = 2
+	}
+	if opts.Quality <= 0 || opts.Quality > 100 {
+		opts.Quality = 75
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	c := &Screencast{opts: opts, file: f}
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gif":
+		c.enc = &gifEncoder{w: f}
+	case ".avi":
+		c.enc = &aviEncoder{w: f, fps: opts.FPS, quality: opts.Quality}
+	default:
+		f.Close()
+		os.Remove(filename)
+		return nil, errors.New("screencast: unsupported format " + filepath.Ext(filename) + ", use .gif or .avi")
+	}
+	if _, err := s.debuggerAddress(); err == nil && !opts.Polling {
+		err := c.startCDP(s)
+		if err == nil {
+			return c, nil
+		}
+		debugprint(err)
+	}
+	c.startPolling(s)
+	return c, nil
+}
+
+// record periodic screenshots.
+func (c *Screencast) startPolling(s *Session) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(time.Duration(float64(time.Second) / c.opts.FPS))
+		defer ticker.Stop()
+		for {
+			img, err := s.ScreenshotImage()
+			if err != nil {
+				debugprint(err)
+			} else {
+				c.add(img, time.Now())
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	c.stop = func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// record the frames of Page.startScreencast over a direct CDP connection.
+func (c *Screencast) startCDP(s *Session) error {
+	conn, err := s.DialCDP()
+	if err != nil {
+		return err
+	}
+	remove := conn.On("Page.screencastFrame", func(params json.RawMessage) {
+		var frame struct {
+			Data      string `json:"data"`
+			SessionID int    `json:"sessionId"`
+			Metadata  struct {
+				Timestamp float64 `json:"timestamp"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(params, &frame); err != nil {
+			return
+		}
+		//the browser sends the next frame after the acknowledgment
+		go conn.CDP("Page.screencastFrameAck", map[string]interface{}{"sessionId": frame.SessionID}, nil)
+		at := time.Now()
+		if frame.Metadata.Timestamp > 0 {
+			at = wallTime(frame.Metadata.Timestamp)
+		}
+		if !c.due(at) {
+			return
+		}
+		data, err := base64.StdEncoding.DecodeString(frame.Data)
+		if err != nil {
+			return
+		}
+		img, err := jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		c.add(img, at)
+	})
+	params := map[string]interface{}{"format": "jpeg", "quality": c.opts.Quality}
+	if err := conn.CDP("Page.startScreencast", params, nil); err != nil {
+		remove()
+		conn.Close()
+		return err
+	}
+	c.stop = func() {
+		conn.CDP("Page.stopScreencast", nil, nil)
+		remove()
+		conn.Close()
+	}
+	return nil
+}
+
+// returns whether a frame taken at t respects the frame rate limit.
+func (c *Screencast) due(t time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last.IsZero() || t.Sub(c.last) >= time.Duration(float64(time.Second)/c.opts.FPS)
+}
+
+func (c *Screencast) add(img image.Image, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil || c.enc == nil {
+		return
+	}
+	c.last = t
+	c.frames++
+	c.err = c.enc.AddFrame(img, t)
+}
+
+// Frames returns the number of frames recorded so far.
+func (c *Screencast) Frames() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.frames
+}
+
+// Stop recording and write the file.
+func (c *Screencast) Stop() error {
+	c.stopOnce.Do(c.stop)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.enc == nil {
+		return errors.New("screencast: already stopped")
+	}
+	err := c.err
+	if cerr := c.enc.Close(time.Now()); err == nil {
+		err = cerr
+	}
+	c.enc = nil
+	if cerr := c.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// frameEncoder encodes the frames of an animation. Frames are added with the
+// time they were taken, Close receives the end of the last frame.
+type frameEncoder interface {
+	AddFrame(img image.Image, t time.Time) error
+	Close(end time.Time) error
+}
+
+// gifEncoder holds the frames in memory and writes the GIF on Close.
+type gifEncoder struct {
+	w      io.Writer
+	anim   gif.GIF
+	bounds image.Rectangle
+	last   time.Time
+}
+
+func (e *gifEncoder) AddFrame(img image.Image, t time.Time) error {
+	if len(e.anim.Image) == 0 {
+		e.bounds = image.Rect(0, 0, img.Bounds().Dx(), img.Bounds().Dy())
+	} else {
+		e.endFrame(t)
+	}
+	e.last = t
+	frame := image.NewPaletted(e.bounds, palette.WebSafe)
+	draw.FloydSteinberg.Draw(frame, e.bounds, img, img.Bounds().Min)
+	if n := len(e.anim.Image); n > 0 && bytes.Equal(e.anim.Image[n-1].Pix, frame.Pix) {
+		//the page didn't change, the previous frame lasts longer
+		return nil
+	}
+	e.anim.Image = append(e.anim.Image, frame)
+	e.anim.Delay = append(e.anim.Delay, 0)
+	return nil
+}
+
+// set the delay of the last frame to the time elapsed until t.
+func (e *gifEncoder) endFrame(t time.Time) {
+	n := len(e.anim.Image)
+	//GIF delays are in hundredths of a second
+	e.anim.Delay[n-1] += int(t.Sub(e.last) / (10 * time.Millisecond))
+	if e.anim.Delay[n-1] < 2 {
+		//browsers play shorter delays as 100ms
+		e.anim.Delay[n-1] = 2
+	}
+}
+
+func (e *gifEncoder) Close(end time.Time) error {
+	if len(e.anim.Image) == 0 {
+		return errors.New("screencast: no frames recorded")
+	}
+	e.endFrame(end)
+	return gif.EncodeAll(e.w, &e.anim)
+}
+
+// aviEncoder writes an MJPEG AVI with a constant frame rate: frames are
+// repeated to last the time until the next one. The header is written again
+// with the final sizes on Close.
+type aviEncoder struct {
+	w       io.WriteSeeker
+	fps     float64
+	quality int
+
+	width, height int
+	last          time.Time
+	lastFrame     []byte
+	moviSize      int
+	index         []aviIndexEntry
+	frames        int
+	pending       float64 // fraction of frame carried over to the next one
+}
+
+type aviIndexEntry struct {
+	offset, size uint32
+}
+
+const aviHeaderSize = 224
+
+func (e *aviEncoder) AddFrame(img image.Image, t time.Time) error {
+	if e.lastFrame == nil {
+		e.width, e.height = img.Bounds().Dx(), img.Bounds().Dy()
+		if err := e.writeHeader(); err != nil {
+			return err
+		}
+	} else if err := e.repeatFrame(t); err != nil {
+		return err
+	}
+	e.last = t
+	if b := img.Bounds(); b.Dx() != e.width || b.Dy() != e.height {
+		//all the frames have the size of the first one
+		canvas := image.NewRGBA(image.Rect(0, 0, e.width, e.height))
+		draw.Draw(canvas, canvas.Bounds(), img, b.Min, draw.Src)
+		img = canvas
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: e.quality}); err != nil {
+		return err
+	}
+	e.lastFrame = buf.Bytes()
+	return nil
+}
+
+// write the previous frame as many times as it lasts until t, at least once.
+func (e *aviEncoder) repeatFrame(t time.Time) error {
+	e.pending += t.Sub(e.last).Seconds() * e.fps
+	n := int(e.pending)
+	if n < 1 {
+		n = 1
+	}
+	e.pending -= float64(n)
+	for i := 0; i < n; i++ {
+		if err := e.writeChunk(e.lastFrame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *aviEncoder) writeChunk(data []byte) error {
+	//offsets are relative to the "movi" fourcc
+	e.index = append(e.index, aviIndexEntry{uint32(4 + e.moviSize), uint32(len(data))})
+	chunk := make([]byte, 8, 8+len(data)+1)
+	copy(chunk, "00dc")
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(data)))
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	e.moviSize += len(chunk)
+	e.frames++
+	_, err := e.w.Write(chunk)
+	return err
+}
+
+func (e *aviEncoder) Close(end time.Time) error {
+	if e.lastFrame == nil {
+		return errors.New("screencast: no frames recorded")
+	}
+	if err := e.repeatFrame(end); err != nil {
+		return err
+	}
+	index := make([]byte, 8, 8+16*len(e.index))
+	copy(index, "idx1")
+	binary.LittleEndian.PutUint32(index[4:], uint32(16*len(e.index)))
+	for _, entry := range e.index {
+		var b [16]byte
+		copy(b[:], "00dc")
+		binary.LittleEndian.PutUint32(b[4:], 0x10) // AVIIF_KEYFRAME
+		binary.LittleEndian.PutUint32(b[8:], entry.offset)
+		binary.LittleEndian.PutUint32(b[12:], entry.size)
+		index = append(index, b[:]...)
+	}
+	if _, err := e.w.Write(index); err != nil {
+		return err
+	}
+	if _, err := e.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return e.writeHeader()
+}
+
+// write the RIFF header, the stream format and the start of the movi list.
+func (e *aviEncoder) writeHeader() error {
+	h := &bytes.Buffer{}
+	le := func(vs ...interface{}) {
+		for _, v := range vs {
+			binary.Write(h, binary.LittleEndian, v)
+		}
+	}
+	riffSize := aviHeaderSize - 8 + e.moviSize + 8 + 16*len(e.index)
+	h.WriteString("RIFF")
+	le(uint32(riffSize))
+	h.WriteString("AVI LIST")
+	le(uint32(192))
+	h.WriteString("hdrlavih")
+	le(uint32(56),
+		uint32(1e6/e.fps), // microseconds per frame
+		uint32(0),         // max bytes per second
+		uint32(0),         // padding granularity
+		uint32(0x10),      // AVIF_HASINDEX
+		uint32(e.frames),  // total frames
+		uint32(0),         // initial frames
+		uint32(1),         // streams
+		uint32(0),         // suggested buffer size
+		uint32(e.width),   // width
+		uint32(e.height),  // height
+		[4]uint32{})       // reserved
+	h.WriteString("LIST")
+	le(uint32(116))
+	h.WriteString("strlstrh")
+	le(uint32(56))
+	h.WriteString("vidsMJPG")
+	le(uint32(0), // flags
+		uint16(0), uint16(0), // priority, language
+		uint32(0),          // initial frames
+		uint32(1000),       // scale
+		uint32(e.fps*1000), // rate
+		uint32(0),          // start
+		uint32(e.frames),   // length
+		uint32(0),          // suggested buffer size
+		int32(-1),          // quality
+		uint32(0),          // sample size
+		[4]int16{0, 0, int16(e.width), int16(e.height)})
+	h.WriteString("strf")
+	le(uint32(40),
+		uint32(40), int32(e.width), int32(e.height),
+		uint16(1), uint16(24)) // planes, bits per pixel
+	h.WriteString("MJPG")
+	le(uint32(e.width*e.height*3), [4]uint32{})
+	h.WriteString("LIST")
+	le(uint32(4 + e.moviSize))
+	h.WriteString("movi")
+	_, err := e.w.Write(h.Bytes())
+	return err
+}
@@ -0,0 +1,108 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScreencastGIF(t *testing.T) {
+	screenshot := testPNG(t)
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		writeValue(w, screenshot)
+	})
+	filename := filepath.Join(t.TempDir(), "run.gif")
+	c, err := fakeSession(d).StartScreencast(filename, ScreencastOptions{FPS: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Frames() < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the page never changes, the frames are merged
+	if len(anim.Image) != 1 || anim.Image[0].Bounds().Dx() != 40 {
+		t.Errorf("got %d frames of %v, want 1 of 40x30", len(anim.Image), anim.Image[0].Bounds())
+	}
+	if _, err := fakeSession(d).StartScreencast(filepath.Join(t.TempDir(), "run.mp4"), ScreencastOptions{}); err == nil {
+		t.Error("unsupported format accepted")
+	}
+}
+
+func TestAVIEncoder(t *testing.T) {
+	f, err := ioutil.TempFile(t.TempDir(), "*.avi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	e := &aviEncoder{w: f, fps: 10, quality: 75}
+	start := time.Now()
+	for i, c := range []color.Color{color.White, color.Black} {
+		img := image.NewRGBA(image.Rect(0, 0, 16, 8))
+		for p := 0; p < len(img.Pix); p += 4 {
+			r, g, b, _ := c.RGBA()
+			img.Pix[p], img.Pix[p+1], img.Pix[p+2], img.Pix[p+3] = uint8(r), uint8(g), uint8(b), 255
+		}
+		//the first frame lasts 3 frames at 10 fps
+		if err := e.AddFrame(img, start.Add(time.Duration(i)*300*time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Close(start.Add(400 * time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	u32 := func(offset int) int { return int(binary.LittleEndian.Uint32(data[offset:])) }
+	if string(data[:4]) != "RIFF" || string(data[8:12]) != "AVI " || u32(4) != len(data)-8 {
+		t.Fatalf("wrong RIFF header: %q, size %d of %d", data[:12], u32(4), len(data))
+	}
+	if frames := u32(48); frames != 4 {
+		t.Errorf("avih has %d frames, want 4", frames)
+	}
+	if length := u32(140); length != 4 {
+		t.Errorf("strh has length %d, want 4", length)
+	}
+	if string(data[220:224]) != "movi" || string(data[224:228]) != "00dc" {
+		t.Fatalf("wrong movi list: %q", data[212:232])
+	}
+	idx := 220 + u32(216)
+	if string(data[idx:idx+4]) != "idx1" || u32(idx+4) != 4*16 {
+		t.Fatalf("wrong index at %d: %q", idx, data[idx:idx+8])
+	}
+	offset, size := u32(idx+8+8), u32(idx+8+12)
+	img, err := jpeg.Decode(bytes.NewReader(data[220+offset+8 : 220+offset+8+size]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r < 0xf000 {
+		t.Errorf("first frame is not white")
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"time"
+)
+
+// Take a screenshot of the current page. The PNG image is returned as is.
+func (s *Session) Screenshot() ([]byte, error) {
+	var buf bytes.Buffer
+	err := s.WriteScreenshot(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Take a screenshot of the current page and write the PNG image to w.
+// The image is decoded while it is read from the driver.
+func (s *Session) WriteScreenshot(w io.Writer) error {
+	return s.doStream(func(value io.Reader) error {
+		_, err := io.Copy(w, base64.NewDecoder(base64.StdEncoding, value))
+		return err
+	}, nil, "GET", "/session/%s/screenshot", s.Id)
+}
+
+// Take a screenshot of the current page and decode it.
+func (s *Session) ScreenshotImage() (image.Image, error) {
+	var img image.Image
+	err := s.doStream(func(value io.Reader) (err error) {
+		img, err = png.Decode(base64.NewDecoder(base64.StdEncoding, value))
+		return
+	}, nil, "GET", "/session/%s/screenshot", s.Id)
+	return img, err
+}
+
+// Take a screenshot of the current page and save it as a PNG file.
+func (s *Session) SaveScreenshot(filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := s.WriteScreenshot(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Take a screenshot of the current page and crop it to r.
+// The rectangle is in screenshot pixels, which differ from CSS pixels when the
+// device pixel ratio is not 1.
+func (s *Session) ScreenshotRect(r image.Rectangle) (image.Image, error) {
+	img, err := s.ScreenshotImage()
+	if err != nil {
+		return nil, err
+	}
+	return cropImage(img, r), nil
+}
+
+// return the part of img inside r, sharing pixels with img when possible.
+func cropImage(img image.Image, r image.Rectangle) image.Image {
+	r = r.Intersect(img.Bounds())
+	if sub, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(r)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, r.Min, draw.Src)
+	return dst
+}
+
+// Take a screenshot of the area of the element. The PNG image is returned as is.
+// Drivers of the JSON Wire Protocol have no element screenshot: the element is
+// cropped from a screenshot of the page.
+func (e WebElement) Screenshot() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := e.WriteScreenshot(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Take a screenshot of the area of the element and write the PNG image to w.
+func (e WebElement) WriteScreenshot(w io.Writer) error {
+	if !e.s.Supports(FeatureElementScreenshot) {
+		img, err := e.ScreenshotImage()
+		if err != nil {
+			return err
+		}
+		return png.Encode(w, img)
+	}
+	return e.s.doStream(func(value io.Reader) error {
+		_, err := io.Copy(w, base64.NewDecoder(base64.StdEncoding, value))
+		return err
+	}, nil, "GET", "/session/%s/element/%s/screenshot", e.s.Id, e.id)
+}
+
+// Take a screenshot of the area of the element and decode it.
+func (e WebElement) ScreenshotImage() (image.Image, error) {
+	if e.s.Supports(FeatureElementScreenshot) {
+		var img image.Image
+		err := e.s.doStream(func(value io.Reader) (err error) {
+			img, err = png.Decode(base64.NewDecoder(base64.StdEncoding, value))
+			return
+		}, nil, "GET", "/session/%s/element/%s/screenshot", e.s.Id, e.id)
+		return img, err
+	}
+	r, err := e.screenshotRect()
+	if err != nil {
+		return nil, err
+	}
+	return e.s.ScreenshotRect(r)
+}
+
+// returns the area of the element in a screenshot of the page, in screenshot
+// pixels: the bounding box in the viewport scaled by the device pixel ratio.
+func (e WebElement) screenshotRect() (image.Rectangle, error) {
+	var box []float64
+	script := `var r = arguments[0].getBoundingClientRect(), d = window.devicePixelRatio || 1;
+return [r.left * d, r.top * d, r.right * d, r.bottom * d];`
+	if err := e.s.ExecuteScriptInto(script, []interface{}{e}, &box); err != nil {
+		return image.Rectangle{}, err
+	}
+	if len(box) != 4 {
+		return image.Rectangle{}, errors.New("screenshot: unexpected bounding box of the element")
+	}
+	return image.Rect(int(math.Floor(box[0])), int(math.Floor(box[1])), int(math.Ceil(box[2])), int(math.Ceil(box[3]))), nil
+}
+
+// Take a screenshot of the whole page, beyond the viewport. The PNG image is
+// returned as is.
+//
+// The browsers that support FeatureFullPageScreenshot, Firefox (geckodriver)
+// and Chromium based browsers, capture the page at once, with
+// /moz/screenshot/full and with CDP respectively. Other browsers are
+// captured by scrolling the page a viewport at a time and stitching the
+// screenshots: fixed and sticky elements are hidden after the first
+// screenshot, so that headers appear once, and the height of the page is
+// measured again after every scroll, so that content loaded lazily is
+// captured too. The stitched image stops after 100 viewports: a longer page,
+// e.g. one that loads content endlessly, is truncated with no error.
+func (s *Session) FullPageScreenshot() ([]byte, error) {
+	var nativeErr error
+	support, _ := s.Browser().support(FeatureFullPageScreenshot)
+	switch support.via {
+	case "moz":
+		var buf bytes.Buffer
+		err := s.doStream(func(value io.Reader) error {
+			_, err := io.Copy(&buf, base64.NewDecoder(base64.StdEncoding, value))
+			return err
+		}, nil, "GET", "/session/%s/moz/screenshot/full", s.Id)
+		if err == nil {
+			return buf.Bytes(), nil
+		}
+		debugprint(err)
+		nativeErr = err
+	case "cdp":
+		page := s.DevTools().Page
+		metrics, err := page.GetLayoutMetrics()
+		if err == nil {
+			size := metrics.CSSContentSize
+			var data []byte
+			data, err = page.CaptureScreenshot(CaptureScreenshotParams{
+				Clip:                  &Viewport{Width: size.Width, Height: size.Height, Scale: 1},
+				CaptureBeyondViewport: true,
+			})
+			if err == nil {
+				return data, nil
+			}
+		}
+		debugprint(err)
+		nativeErr = err
+	}
+	img, err := s.stitchScreenshots()
+	if err != nil {
+		if nativeErr != nil {
+			return nil, fmt.Errorf("%w (stitching failed too: %v)", nativeErr, err)
+		}
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+// Take a screenshot of the whole page and decode it.
+func (s *Session) FullPageScreenshotImage() (image.Image, error) {
+	data, err := s.FullPageScreenshot()
+	if err != nil {
+		return nil, err
+	}
+	return png.Decode(bytes.NewReader(data))
+}
+
+// the scroll position and the sizes of the page, in CSS pixels.
+type pageMetrics struct {
+	X, Y     float64 // scroll position
+	Viewport float64 // height of the viewport
+	Height   float64 // height of the page
+	Ratio    float64 // device pixel ratio
+}
+
+// scroll the page to y, if y is not negative, and return the metrics of the page.
+const pageMetricsScript = `if (arguments[0] >= 0) window.scrollTo(window.pageXOffset, arguments[0]);
+var e = document.documentElement, b = document.body;
+return {X: window.pageXOffset, Y: window.pageYOffset, Viewport: window.innerHeight,
+	Height: Math.max(e.scrollHeight, b ? b.scrollHeight : 0), Ratio: window.devicePixelRatio || 1};`
+
+// hide the fixed and sticky elements, or show again those hidden if the argument is true.
+const stickyScript = `if (arguments[0]) {
+	(window.__webdriverHidden || []).forEach(function(e) { e.style.visibility = e.__webdriverVisibility; });
+	window.__webdriverHidden = [];
+	return;
+}
+var hidden = [];
+document.querySelectorAll('body *').forEach(function(e) {
+	var position = getComputedStyle(e).position;
+	if ((position == 'fixed' || position == 'sticky') && e.style.visibility != 'hidden') {
+		e.__webdriverVisibility = e.style.visibility;
+		e.style.visibility = 'hidden';
+		hidden.push(e);
+	}
+});
+window.__webdriverHidden = hidden;`
+
+// time given to the page to load lazy content after a scroll.
+var stitchDelay = 200 * time.Millisecond
+
+// the longest page captured by scrolling, in viewports. The rest of the page
+// is left out, see FullPageScreenshot.
+const maxStitchedViewports = 100
+
+// capture the page by scrolling it and stitching the screenshots of the viewport.
+func (s *Session) stitchScreenshots() (image.Image, error) {
+	var start pageMetrics
+	if err := s.ExecuteScriptInto(pageMetricsScript, []interface{}{-1}, &start); err != nil {
+		return nil, err
+	}
+	defer s.ExecuteScript("window.scrollTo(arguments[0], arguments[1])", []interface{}{start.X, start.Y})
+	defer s.ExecuteScript(stickyScript, []interface{}{true})
+
+	type shot struct {
+		img image.Image
+		y   float64
+	}
+	var shots []shot
+	m := start
+	for y := 0.0; len(shots) < maxStitchedViewports; y = m.Y + m.Viewport {
+		if err := s.ExecuteScriptInto(pageMetricsScript, []interface{}{y}, &m); err != nil {
+			return nil, err
+		}
+		if len(shots) > 0 {
+			if m.Y <= shots[len(shots)-1].y {
+				//the page can't scroll further
+				break
+			}
+			time.Sleep(stitchDelay)
+			//lazy content may have changed the height of the page
+			if err := s.ExecuteScriptInto(pageMetricsScript, []interface{}{-1}, &m); err != nil {
+				return nil, err
+			}
+		}
+		img, err := s.ScreenshotImage()
+		if err != nil {
+			return nil, err
+		}
+		shots = append(shots, shot{img, m.Y})
+		if len(shots) == 1 {
+			if _, err := s.ExecuteScript(stickyScript, []interface{}{false}); err != nil {
+				return nil, err
+			}
+		}
+		if m.Y+m.Viewport >= m.Height {
+			break
+		}
+		if len(shots) == maxStitchedViewports {
+			debugprint(fmt.Sprintf("full page screenshot truncated at %d viewports", maxStitchedViewports))
+		}
+	}
+
+	ratio := m.Ratio
+	if ratio <= 0 {
+		ratio = 1
+	}
+	last := shots[len(shots)-1]
+	height := int(math.Ceil(last.y*ratio)) + last.img.Bounds().Dy()
+	page := image.NewRGBA(image.Rect(0, 0, shots[0].img.Bounds().Dx(), height))
+	//later screenshots overlap the bottom of the previous ones, draw them in reverse
+	//so that every part of the page comes from the screenshot it was first seen in
+	for i := len(shots) - 1; i >= 0; i-- {
+		b := shots[i].img.Bounds()
+		at := image.Pt(0, int(math.Round(shots[i].y*ratio)))
+		draw.Draw(page, b.Sub(b.Min).Add(at), shots[i].img, b.Min, draw.Src)
+	}
+	return page, nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testPNG(t *testing.T) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 30))
+	for x := 0; x < 40; x++ {
+		for y := 0; y < 30; y++ {
+			img.Set(x, y, color.NRGBA{uint8(x * 6), uint8(y * 8), 200, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestScreenshotDecode(t *testing.T) {
+	want := testPNG(t)
+	encoded := base64.StdEncoding.EncodeToString(want)
+	//escape slashes and wrap lines, as some drivers do
+	encoded = strings.Replace(encoded, "/", `\/`, -1)
+	encoded = encoded[:20] + `\n` + encoded[20:]
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"sessionId": "s1", "status": 0, "value" : "%s"}`, encoded)
+	})
+	s := fakeSession(d)
+	got, err := s.Screenshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("screenshot differs from the image sent")
+	}
+	img, err := s.ScreenshotRect(image.Rect(10, 10, 20, 15))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 10 || img.Bounds().Dy() != 5 {
+		t.Fatalf("wrong crop size: %v", img.Bounds())
+	}
+}
+
+func TestScreenshotDecodeError(t *testing.T) {
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, 404, "no such window")
+	})
+	_, err := fakeSession(d).Screenshot()
+	if err == nil || !strings.Contains(err.Error(), "no such window") {
+		t.Fatalf("got %v, want a no such window error", err)
+	}
+	d = newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"status": 13, "value": {"message": "boom"}}`)
+	})
+	_, err = fakeSession(d).Screenshot()
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Fatalf("got %v, want the legacy error", err)
+	}
+}
+
+func TestFullPageScreenshotStitch(t *testing.T) {
+	defer func(delay time.Duration) { stitchDelay = delay }(stitchDelay)
+	stitchDelay = 0
+	//a page of 250x40 CSS pixels, whose rows have the color of their y, seen
+	//through a viewport 100 pixels high with a device pixel ratio of 1
+	var mu sync.Mutex
+	y, hidden := 0, false
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/execute/sync"):
+			var p struct {
+				Script string
+				Args   []interface{}
+			}
+			json.NewDecoder(r.Body).Decode(&p)
+			switch {
+			case strings.Contains(p.Script, "__webdriverHidden"):
+				hidden = p.Args[0] == false
+				writeValue(w, nil)
+			case strings.Contains(p.Script, "scrollHeight"):
+				if arg := p.Args[0].(float64); arg >= 0 {
+					y = int(arg)
+					if y > 150 {
+						y = 150
+					}
+				}
+				writeValue(w, map[string]interface{}{"X": 0, "Y": y, "Viewport": 100, "Height": 250, "Ratio": 1})
+			default:
+				y = 0
+				writeValue(w, nil)
+			}
+		case strings.HasSuffix(r.URL.Path, "/screenshot"):
+			img := image.NewNRGBA(image.Rect(0, 0, 40, 100))
+			for row := 0; row < 100; row++ {
+				c := color.NRGBA{uint8(y + row), 0, 0, 255}
+				if row < 10 && !hidden {
+					//a sticky header
+					c = color.NRGBA{0, 0, 255, 255}
+				}
+				for x := 0; x < 40; x++ {
+					img.SetNRGBA(x, row, c)
+				}
+			}
+			var buf bytes.Buffer
+			png.Encode(&buf, img)
+			writeValue(w, buf.Bytes())
+		}
+	})
+	img, err := fakeSession(d).FullPageScreenshotImage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 40 || img.Bounds().Dy() != 250 {
+		t.Fatalf("wrong size %v, want 40x250", img.Bounds())
+	}
+	for row := 0; row < 250; row++ {
+		want := color.NRGBA{uint8(row), 0, 0, 255}
+		if row < 10 {
+			want = color.NRGBA{0, 0, 255, 255}
+		}
+		if got := color.NRGBAModel.Convert(img.At(5, row)); got != want {
+			t.Fatalf("row %d is %v, want %v", row, got, want)
+		}
+	}
+	if hidden {
+		t.Error("the sticky elements were not shown again")
+	}
+}
+
+func TestFullPageScreenshotFirefox(t *testing.T) {
+	want := testPNG(t)
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/session/s1/moz/screenshot/full" {
+			t.Errorf("unexpected command %s", r.URL.Path)
+		}
+		writeValue(w, want)
+	})
+	s := fakeSession(d)
+	s.Capabilities = Capabilities{"capabilities": map[string]interface{}{"browserName": "firefox"}}
+	got, err := s.FullPageScreenshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("screenshot differs from the image sent")
+	}
+}
+
+func TestFullPageScreenshotNativeError(t *testing.T) {
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/session/s1/moz/screenshot/full":
+			writeError(w, 404, "unknown command")
+		default:
+			writeError(w, 500, "javascript error")
+		}
+	})
+	s := fakeSession(d)
+	s.Capabilities = Capabilities{"capabilities": map[string]interface{}{"browserName": "firefox"}}
+	_, err := s.FullPageScreenshot()
+	var cerr *CommandError
+	if !errors.As(err, &cerr) || cerr.ErrorType != "unknown command" || !strings.Contains(err.Error(), "javascript error") {
+		t.Errorf("got %v, want the native error and the stitching error", err)
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Select wraps a SELECT element and provides helpers to pick its options.
+type Select struct {
+	WebElement
+	multiple bool
+}
+
+// NewSelect returns a Select for e. It fails if e is not a SELECT element.
+func NewSelect(e WebElement) (Select, error) {
+	tag, err := e.Name()
+	if err != nil {
+		return Select{}, err
+	}
+	if !strings.EqualFold(tag, "select") {
+		return Select{}, fmt.Errorf("element is a %s, not a select", tag)
+	}
+	multiple, err := e.GetAttribute("multiple")
+	if err != nil {
+		return Select{}, err
+	}
+	return Select{e, multiple != "" && multiple != "false"}, nil
+}
+
+// IsMultiple reports whether the element supports selecting more than one option at a time.
+func (s Select) IsMultiple() bool {
+	return s.multiple
+}
+
+// Options returns all the options of the element.
+func (s Select) Options() ([]WebElement, error) {
+	return s.FindElements(TagName, "option")
+}
+
+// SelectedOptions returns all the selected options of the element.
+func (s Select) SelectedOptions() ([]WebElement, error) {
+	options, err := s.Options()
+	if err != nil {
+		return nil, err
+	}
+	var selected []WebElement
+	for _, o := range options {
+		ok, err := o.IsSelected()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			selected = append(selected, o)
+		}
+	}
+	return selected, nil
+}
+
+// FirstSelectedOption returns the first selected option, which is the selected option of a single select.
+func (s Select) FirstSelectedOption() (WebElement, error) {
+	selected, err := s.SelectedOptions()
+	if err != nil {
+		return WebElement{}, err
+	}
+	if len(selected) == 0 {
+		return WebElement{}, errors.New("no option is selected")
+	}
+	return selected[0], nil
+}
+
+// SelectByVisibleText selects the options whose visible text is text.
+// Leading and trailing white space is ignored.
+func (s Select) SelectByVisibleText(text string) error {
+	return s.setByText(text, true)
+}
+
+// SelectByValue selects the options whose value attribute is value.
+func (s Select) SelectByValue(value string) error {
+	return s.setByValue(value, true)
+}
+
+// SelectByIndex selects the option at index, counting from 0.
+func (s Select) SelectByIndex(index int) error {
+	return s.setByIndex(index, true)
+}
+
+// DeselectByVisibleText deselects the options whose visible text is text.
+// Only valid for elements that support multiple selections.
+func (s Select) DeselectByVisibleText(text string) error {
+	if err := s.checkMultiple(); err != nil {
+		return err
+	}
+	return s.setByText(text, false)
+}
+
+// DeselectByValue deselects the options whose value attribute is value.
+// Only valid for elements that support multiple selections.
+func (s Select) DeselectByValue(value string) error {
+	if err := s.checkMultiple(); err != nil {
+		return err
+	}
+	return s.setByValue(value, false)
+}
+
+// DeselectByIndex deselects the option at index, counting from 0.
+// Only valid for elements that support multiple selections.
+func (s Select) DeselectByIndex(index int) error {
+	if err := s.checkMultiple(); err != nil {
+		return err
+	}
+	return s.setByIndex(index, false)
+}
+
+// DeselectAll clears all the selected options.
+// Only valid for elements that support multiple selections.
+func (s Select) DeselectAll() error {
+	if err := s.checkMultiple(); err != nil {
+		return err
+	}
+	options, err := s.Options()
+	if err != nil {
+		return err
+	}
+	for _, o := range options {
+		if err := s.setSelected(o, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s Select) checkMultiple() error {
+	if !s.multiple {
+		return errors.New("deselect is only supported by multi-select elements")
+	}
+	return nil
+}
+
+func (s Select) setByText(text string, selected bool) error {
+	options, err := s.Options()
+	if err != nil {
+		return err
+	}
+	text = strings.TrimSpace(text)
+	found := false
+	for _, o := range options {
+		t, err := o.Text()
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(t) != text {
+			continue
+		}
+		found = true
+		if err := s.setSelected(o, selected); err != nil {
+			return err
+		}
+		if !s.multiple {
+			return nil
+		}
+	}
+	if !found {
+		return fmt.Errorf("no option with visible text %q", text)
+	}
+	return nil
+}
+
+func (s Select) setByValue(value string, selected bool) error {
+	options, err := s.FindElements(CSS_Selector, "option[value="+cssQuote(value)+"]")
+	if err != nil {
+		return err
+	}
+	if len(options) == 0 {
+		return fmt.Errorf("no option with value %q", value)
+	}
+	for _, o := range options {
+		if err := s.setSelected(o, selected); err != nil {
+			return err
+		}
+		if !s.multiple {
+			break
+		}
+	}
+	return nil
+}
+
+func (s Select) setByIndex(index int, selected bool) error {
+	options, err := s.Options()
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(options) {
+		return fmt.Errorf("no option at index %d (%d options)", index, len(options))
+	}
+	return s.setSelected(options[index], selected)
+}
+
+// click the option if its selection state differs from selected. A disabled
+// option is an error only if it has to be clicked.
+func (s Select) setSelected(option WebElement, selected bool) error {
+	isSelected, err := option.IsSelected()
+	if err != nil {
+		return err
+	}
+	if isSelected == selected {
+		return nil
+	}
+	enabled, err := option.IsEnabled()
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		text, _ := option.Text()
+		return fmt.Errorf("option %q is disabled", strings.TrimSpace(text))
+	}
+	return option.Click()
+}
+
+// quote a string for use as a CSS attribute value. Newlines can't appear in a
+// CSS string, they are escaped by code point.
+func cssQuote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, `"`, `\"`, -1)
+	s = strings.Replace(s, "\n", `\a `, -1)
+	s = strings.Replace(s, "\r", `\d `, -1)
+	return `"` + s + `"`
+}
@@ -0,0 +1,133 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// a multi-select element "sel" of the options o0 to o3, none selected; o3 is
+// disabled.
+func fakeSelect(t *testing.T) Select {
+	options := []struct{ id, value, text string }{
+		{"o0", "1", "One"},
+		{"o1", "2", " Two "},
+		{"o2", "3", "Three"},
+		{"o3", "4", "Four"},
+	}
+	var mu sync.Mutex
+	selected := map[string]bool{}
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/session/s1/element/"), "/")
+		id, command := parts[0], strings.Join(parts[1:], "/")
+		switch command {
+		case "name":
+			writeValue(w, "select")
+		case "attribute/multiple":
+			writeValue(w, "true")
+		case "elements":
+			var p struct{ Using, Value string }
+			json.NewDecoder(r.Body).Decode(&p)
+			var found []map[string]string
+			for _, o := range options {
+				if p.Value == "option" || p.Value == "option[value="+cssQuote(o.value)+"]" {
+					found = append(found, map[string]string{webElementKey: o.id})
+				}
+			}
+			writeValue(w, found)
+		case "text":
+			for _, o := range options {
+				if o.id == id {
+					writeValue(w, o.text)
+				}
+			}
+		case "selected":
+			writeValue(w, selected[id])
+		case "enabled":
+			writeValue(w, id != "o3")
+		case "click":
+			if id == "o3" {
+				t.Error("disabled option clicked")
+			}
+			selected[id] = !selected[id]
+			writeValue(w, nil)
+		default:
+			t.Errorf("unexpected command %s %s", r.Method, r.URL.Path)
+			writeValue(w, nil)
+		}
+	})
+	s := fakeSession(d)
+	sel, err := NewSelect(WebElement{s, "sel"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sel
+}
+
+func TestSelectOptions(t *testing.T) {
+	sel := fakeSelect(t)
+	if !sel.IsMultiple() {
+		t.Fatal("multiple attribute not detected")
+	}
+	for _, step := range []struct {
+		name string
+		f    func() error
+		want string
+	}{
+		{"select by text", func() error { return sel.SelectByVisibleText("Two") }, "o1"},
+		{"select by value", func() error { return sel.SelectByValue("3") }, "o1 o2"},
+		{"select by index", func() error { return sel.SelectByIndex(0) }, "o0 o1 o2"},
+		{"deselect by text", func() error { return sel.DeselectByVisibleText("One") }, "o1 o2"},
+		{"deselect by value", func() error { return sel.DeselectByValue("2") }, "o2"},
+		{"deselect by index", func() error { return sel.DeselectByIndex(2) }, ""},
+	} {
+		if err := step.f(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		options, err := sel.SelectedOptions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, o := range options {
+			ids = append(ids, o.id)
+		}
+		if got := strings.Join(ids, " "); got != step.want {
+			t.Errorf("%s: selected %s, want %s", step.name, got, step.want)
+		}
+	}
+
+	//a disabled option can't be clicked, but it may already be as wanted
+	if err := sel.SelectByValue("4"); err == nil || !strings.Contains(err.Error(), "disabled") {
+		t.Errorf("selecting a disabled option: got %v", err)
+	}
+	if err := sel.DeselectByIndex(3); err != nil {
+		t.Errorf("deselecting a deselected disabled option: %v", err)
+	}
+	if err := sel.SelectByValue("5"); err == nil {
+		t.Error("selected a missing value")
+	}
+	if err := sel.SelectByIndex(4); err == nil {
+		t.Error("selected a missing index")
+	}
+}
+
+func TestCSSQuote(t *testing.T) {
+	for s, want := range map[string]string{
+		`a`:       `"a"`,
+		`a"b\c`:   `"a\"b\\c"`,
+		"a\nb\rc": `"a\a b\d c"`,
+	} {
+		if got := cssQuote(s); got != want {
+			t.Errorf("cssQuote(%q) = %s, want %s", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSessionTimeouts(t *testing.T) {
+	var mu sync.Mutex
+	var last map[string]interface{}
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/timeouts") {
+			var p map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&p)
+			if p["implicit"] == -1.0 {
+				writeError(w, 400, "invalid argument")
+				return
+			}
+			mu.Lock()
+			last = p
+			mu.Unlock()
+		}
+		writeValue(w, nil)
+	})
+	s := fakeSession(d)
+	s.Capabilities = Capabilities{"capabilities": map[string]interface{}{
+		"browserName": "firefox",
+		"timeouts":    map[string]interface{}{"script": 30000.0, "pageLoad": 120000.0, "implicit": 0.0},
+	}}
+	//the timeouts the session was created with are kept
+	if err := s.SetTimeouts("script", 1000); err != nil {
+		t.Fatal(err)
+	}
+	//the timeouts set by the first call are sent again with the second
+	if err := s.SetTimeouts("implicit", 500); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"script": 1000.0, "implicit": 500.0, "pageLoad": 120000.0}
+	if !reflect.DeepEqual(last, want) {
+		t.Errorf("got timeouts %v, want %v", last, want)
+	}
+	//a rejected timeout is not sent again
+	if err := s.SetTimeouts("implicit", -1); err == nil {
+		t.Fatal("invalid timeout accepted")
+	}
+	if err := s.SetTimeouts("script", 2000); err != nil {
+		t.Fatal(err)
+	}
+	want["script"] = 2000.0
+	if !reflect.DeepEqual(last, want) {
+		t.Errorf("after an error got timeouts %v, want %v", last, want)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(ms int) {
+			defer wg.Done()
+			if err := s.SetTimeouts("page load", ms); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	//the last timeouts sent are those cached
+	if last["pageLoad"] != float64(s.timeouts["pageLoad"].(int)) {
+		t.Errorf("sent page load timeout %v, cached %v", last["pageLoad"], s.timeouts["pageLoad"])
+	}
+}
+
+func TestSessionFrames(t *testing.T) {
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/frame") {
+			var p map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&p)
+			if p["id"] == "missing" {
+				writeError(w, 404, "no such frame")
+				return
+			}
+		}
+		writeValue(w, nil)
+	})
+	s := fakeSession(d)
+	s.FocusOnFrame("outer")
+	s.FocusOnFrame(1)
+	if err := s.FocusOnFrame("missing"); err == nil {
+		t.Fatal("focus on a missing frame succeeded")
+	}
+	if got := s.CurrentFrame(); !reflect.DeepEqual(got, []interface{}{"outer", 1}) {
+		t.Errorf("got frame path %v, want [outer 1]", got)
+	}
+	s.FocusParentFrame()
+	if got := s.CurrentFrame(); !reflect.DeepEqual(got, []interface{}{"outer"}) {
+		t.Errorf("got frame path %v after the parent frame, want [outer]", got)
+	}
+	s.Refresh()
+	if got := s.CurrentFrame(); len(got) != 0 {
+		t.Errorf("got frame path %v after a refresh, want none", got)
+	}
+}
+
+func TestSendKeys(t *testing.T) {
+	var got map[string]interface{}
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		got = nil
+		json.NewDecoder(r.Body).Decode(&got)
+		writeValue(w, nil)
+	})
+	w3c := fakeSession(d)
+	if err := (WebElement{w3c, "e1"}).SendKeys("héllo"); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"text": "héllo"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("W3C session sent %v, want %v", got, want)
+	}
+	//the JSON Wire Protocol takes the keys one by one
+	legacy := &Session{Id: "s1", Capabilities: Capabilities{"browserName": "chrome"}, wd: d}
+	if err := (WebElement{legacy, "e1"}).SendKeys("héllo"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"value": []interface{}{"h", "é", "l", "l", "o"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("legacy session sent %v, want %v", got, want)
+	}
+	if err := legacy.SendKeysOnActiveElement("né"); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"value": []interface{}{"n", "é"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("keys of the active element: sent %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,368 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A trace is the record of the commands sent to a driver, as JSON lines: a
+// header followed by one TraceCommand per line. Record a run by setting the
+// Trace field of the driver:
+//
+//	trace, err := webdriver.CreateTrace("run.trace")
+//	driver.Trace = trace
+//	defer trace.Close()
+//
+// and replay it without a browser with a ReplayDriver:
+//
+//	commands, err := webdriver.OpenTrace("run.trace")
+//	driver := webdriver.NewReplayDriver(commands)
+
+// TraceVersion is the version of the trace format written by TraceRecorder.
+const TraceVersion = 1
+
+// TraceCommand is a command sent to the driver and its response.
+type TraceCommand struct {
+	Seq      int           `json:"seq"`
+	Time     time.Time     `json:"time"`
+	Duration time.Duration `json:"duration"`
+	Method   string        `json:"method"`
+	// Path is the URL of the command relative to the driver, e.g. "/session/1234/url".
+	Path   string          `json:"path"`
+	Params json.RawMessage `json:"params,omitempty"`
+	// Status is the HTTP status code of the response.
+	Status   int             `json:"status,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	// ResponseText is the body of a response that is not JSON.
+	ResponseText string `json:"responseText,omitempty"`
+	// Error is the transport error of a command that got no response.
+	Error string `json:"error,omitempty"`
+	// Attempt is the number of the retry of the command, see RetryPolicy.
+	Attempt int `json:"attempt,omitempty"`
+}
+
+func (c TraceCommand) String() string {
+	s := fmt.Sprintf("#%d %s %s", c.Seq, c.Method, c.Path)
+	if c.Attempt > 0 {
+		s += fmt.Sprintf(" (retry %d)", c.Attempt)
+	}
+	if c.Error != "" {
+		return s + ": " + c.Error
+	}
+	return fmt.Sprintf("%s: %d (%v)", s, c.Status, c.Duration.Round(time.Millisecond))
+}
+
+type traceHeader struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+}
+
+// TraceRecorder writes the commands of a driver to a trace. It is safe for
+// concurrent use.
+type TraceRecorder struct {
+	// Keep is the number of the last commands kept in memory for Last. Default: 0.
+	Keep int
+
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+	seq    int
+	last   []TraceCommand
+	err    error
+}
+
+// NewTraceRecorder returns a recorder that writes the trace to w.
+func NewTraceRecorder(w io.Writer) (*TraceRecorder, error) {
+	r := &TraceRecorder{w: w}
+	if err := r.writeLine(traceHeader{TraceVersion, time.Now()}); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// CreateTrace returns a recorder that writes the trace to filename.
+func CreateTrace(filename string) (*TraceRecorder, error) {
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	r, err := NewTraceRecorder(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	r.closer = f
+	return r, nil
+}
+
+func (r *TraceRecorder) writeLine(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = r.w.Write(append(data, '\n'))
+	return err
+}
+
+// Record appends c to the trace, numbering it. Write errors are returned by Close.
+func (r *TraceRecorder) Record(c TraceCommand) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	c.Seq = r.seq
+	if r.Keep > 0 {
+		r.last = append(r.last, c)
+		if len(r.last) > r.Keep {
+			r.last = r.last[len(r.last)-r.Keep:]
+		}
+	}
+	if r.err == nil {
+		r.err = r.writeLine(c)
+	}
+}
+
+// Last returns the last Keep commands, oldest first.
+func (r *TraceRecorder) Last() []TraceCommand {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]TraceCommand(nil), r.last...)
+}
+
+// Close the trace file, if the recorder was created with CreateTrace, and
+// return the first error met while writing.
+func (r *TraceRecorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.err
+	if r.closer != nil {
+		if cerr := r.closer.Close(); err == nil {
+			err = cerr
+		}
+		r.closer = nil
+	}
+	return err
+}
+
+// record the round trip of a request, once the body of the response is read.
+func (r *TraceRecorder) trace(method, path string, params []byte, attempt int, start time.Time, response *http.Response, err error) {
+	c := TraceCommand{Time: start, Method: method, Path: path, Attempt: attempt}
+	if len(params) > 0 {
+		c.Params = params
+	}
+	if err != nil {
+		c.Duration = time.Since(start)
+		if uerr, ok := err.(*url.Error); ok {
+			//the request is in the command already
+			err = uerr.Err
+		}
+		c.Error = err.Error()
+		r.Record(c)
+		return
+	}
+	c.Status = response.StatusCode
+	buf := &bytes.Buffer{}
+	response.Body = &traceBody{response.Body, io.TeeReader(response.Body, buf), func() {
+		c.Duration = time.Since(start)
+		if body := bytes.TrimSpace(buf.Bytes()); json.Valid(body) {
+			c.Response = body
+		} else {
+			c.ResponseText = buf.String()
+		}
+		r.Record(c)
+	}}
+}
+
+// a response body that copies what is read and records the command when closed.
+type traceBody struct {
+	io.ReadCloser
+	r    io.Reader
+	done func()
+}
+
+func (b *traceBody) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+func (b *traceBody) Close() error {
+	//drain what the caller didn't read, to record the whole response
+	io.Copy(ioutil.Discard, b.r)
+	err := b.ReadCloser.Close()
+	if b.done != nil {
+		b.done()
+		b.done = nil
+	}
+	return err
+}
+
+// ReadTrace reads the commands of a trace.
+func ReadTrace(r io.Reader) ([]TraceCommand, error) {
+	scanner := bufio.NewScanner(r)
+	//screenshots make long lines
+	scanner.Buffer(nil, 1<<30)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("trace: empty trace")
+	}
+	var header traceHeader
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, errors.New("trace: invalid header: " + err.Error())
+	}
+	if header.Version != TraceVersion {
+		return nil, fmt.Errorf("trace: unsupported version %d (supported: %d)", header.Version, TraceVersion)
+	}
+	var commands []TraceCommand
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var c TraceCommand
+		if err := json.Unmarshal(scanner.Bytes(), &c); err != nil {
+			return nil, fmt.Errorf("trace: invalid command %d: %v", len(commands)+1, err)
+		}
+		commands = append(commands, c)
+	}
+	return commands, scanner.Err()
+}
+
+// OpenTrace reads the commands of the trace file filename.
+func OpenTrace(filename string) ([]TraceCommand, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadTrace(f)
+}
+
+// ReplayDriver is a WebDriver that answers the commands with the responses
+// of a trace, without a browser. Commands must be sent in the order they were
+// recorded, with the same parameters; a command that doesn't match fails.
+type ReplayDriver struct {
+	WebDriverCore
+
+	mu       sync.Mutex
+	commands []TraceCommand
+	next     int
+}
+
+// NewReplayDriver returns a driver that replays commands.
+func NewReplayDriver(commands []TraceCommand) *ReplayDriver {
+	d := &ReplayDriver{commands: commands}
+	d.url = "http://replay"
+	d.registry = newSessionRegistry()
+	d.transport = d
+	return d
+}
+
+func (d *ReplayDriver) NewSession(desired, required Capabilities) (*Session, error) {
+	session, err := d.newSession(desired, required)
+	if err != nil {
+		return nil, err
+	}
+	session.wd = d
+	return session, nil
+}
+
+func (d *ReplayDriver) Sessions() ([]*Session, error) {
+	sessions, err := d.sessions()
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].wd = d
+	}
+	return sessions, nil
+}
+
+// Done returns an error if some recorded commands were not replayed.
+func (d *ReplayDriver) Done() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.next < len(d.commands) {
+		return fmt.Errorf("replay: %d commands not replayed, next is %s %s",
+			len(d.commands)-d.next, d.commands[d.next].Method, d.commands[d.next].Path)
+	}
+	return nil
+}
+
+// RoundTrip answers request with the next recorded command.
+func (d *ReplayDriver) RoundTrip(request *http.Request) (*http.Response, error) {
+	var params []byte
+	if request.Body != nil {
+		var err error
+		if params, err = ioutil.ReadAll(request.Body); err != nil {
+			return nil, err
+		}
+		request.Body.Close()
+	}
+	path := strings.TrimPrefix(request.URL.String(), d.url)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.next >= len(d.commands) {
+		return nil, fmt.Errorf("replay: unexpected command %s %s, the trace is over", request.Method, path)
+	}
+	c := d.commands[d.next]
+	if c.Method != request.Method || c.Path != path {
+		return nil, fmt.Errorf("replay: command #%d is %s %s, got %s %s", c.Seq, c.Method, c.Path, request.Method, path)
+	}
+	if !sameJSON(c.Params, params) {
+		return nil, fmt.Errorf("replay: command #%d %s %s was sent with %s, got %s", c.Seq, c.Method, c.Path, c.Params, params)
+	}
+	d.next++
+	if c.Error != "" {
+		return nil, errors.New(c.Error)
+	}
+	body := []byte(c.Response)
+	if c.ResponseText != "" {
+		body = []byte(c.ResponseText)
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	if c.Status == http.StatusFound || c.Status == http.StatusSeeOther {
+		//the redirect of POST /session, whose target is the following command
+		if len(d.commands) > d.next {
+			header.Set("Location", d.url+d.commands[d.next].Path)
+		}
+	}
+	return &http.Response{
+		Status:        http.StatusText(c.Status),
+		StatusCode:    c.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       request,
+	}, nil
+}
+
+// compare two JSON documents regardless of the order of the keys and of the spacing.
+func sameJSON(a, b []byte) bool {
+	if len(bytes.TrimSpace(a)) == 0 || len(bytes.TrimSpace(b)) == 0 {
+		return len(bytes.TrimSpace(a)) == len(bytes.TrimSpace(b))
+	}
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return bytes.Equal(a, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,105 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"bytes"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTraceReplay(t *testing.T) {
+	png := testPNG(t)
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/session":
+			writeValue(w, map[string]interface{}{"sessionId": "s1", "capabilities": map[string]interface{}{"browserName": "fake"}})
+		case strings.HasSuffix(r.URL.Path, "/url"):
+			writeValue(w, nil)
+		case strings.HasSuffix(r.URL.Path, "/title"):
+			writeValue(w, "Home")
+		case strings.HasSuffix(r.URL.Path, "/screenshot"):
+			writeValue(w, png)
+		default:
+			writeError(w, 404, "no such element")
+		}
+	})
+	filename := filepath.Join(t.TempDir(), "run.trace")
+	trace, err := CreateTrace(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace.Keep = 2
+	d.Trace = trace
+
+	run := func(d WebDriver) (title string, screenshot []byte, err error) {
+		s, err := d.NewSession(Capabilities{"browserName": "fake"}, nil)
+		if err != nil {
+			return "", nil, err
+		}
+		if err := s.Url("http://example.com/"); err != nil {
+			return "", nil, err
+		}
+		if title, err = s.Title(); err != nil {
+			return "", nil, err
+		}
+		if screenshot, err = s.Screenshot(); err != nil {
+			return "", nil, err
+		}
+		_, err = s.FindElement(ID, "missing")
+		return title, screenshot, err
+	}
+	title, screenshot, findErr := run(d)
+	if findErr == nil {
+		t.Fatal("FindElement of a missing element succeeded")
+	}
+	if last := trace.Last(); len(last) != 2 || last[1].Seq != 5 || last[1].Status != 404 {
+		t.Errorf("wrong last commands: %v", last)
+	}
+	if err := trace.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	commands, err := OpenTrace(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commands) != 5 || commands[1].Method != "POST" || commands[1].Path != "/session/s1/url" {
+		t.Fatalf("wrong trace: %v", commands)
+	}
+	replay := NewReplayDriver(commands)
+	rtitle, rscreenshot, rfindErr := run(replay)
+	if rtitle != title || !bytes.Equal(rscreenshot, screenshot) {
+		t.Errorf("replayed %q, %d bytes, want %q, %d bytes", rtitle, len(rscreenshot), title, len(screenshot))
+	}
+	if rfindErr == nil || rfindErr.Error() != findErr.Error() {
+		t.Errorf("replayed error %v, want %v", rfindErr, findErr)
+	}
+	if err := replay.Done(); err != nil {
+		t.Error(err)
+	}
+
+	//a command that diverges from the trace fails
+	replay = NewReplayDriver(commands)
+	s, err := replay.NewSession(Capabilities{"browserName": "fake"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Url("http://example.org/"); err == nil || !strings.Contains(err.Error(), "replay: command #2") {
+		t.Errorf("diverging command: %v", err)
+	}
+	if err := replay.Done(); err == nil {
+		t.Error("Done didn't report the commands not replayed")
+	}
+}
+
+func TestReadTraceVersion(t *testing.T) {
+	_, err := ReadTrace(strings.NewReader(`{"version":99}` + "\n"))
+	if err == nil || !strings.Contains(err.Error(), "unsupported version 99") {
+		t.Errorf("got %v", err)
+	}
+}
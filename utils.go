@@ -10,6 +10,7 @@ import (
 	"net"
 	"os"
 	"runtime"
+	"sync"
 	"time"
 )
 
@@ -49,3 +50,54 @@ func probePort(port int, timeout time.Duration) error {
 	}
 	return nil
 }
+
+//the size of the output of a driver process kept in memory, see WebDriver.Logs.
+const driverLogSize = 1 << 20
+
+//ringBuffer keeps the last bytes written to it, up to its size. It is safe for concurrent use.
+type ringBuffer struct {
+	mu   sync.Mutex
+	buf  []byte
+	size int
+	//the position of the oldest byte, once the buffer is full
+	start int
+}
+
+func newRingBuffer(size int) *ringBuffer {
+	return &ringBuffer{size: size}
+}
+
+func (r *ringBuffer) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(p)
+	if len(p) > r.size {
+		p = p[len(p)-r.size:]
+	}
+	if room := r.size - len(r.buf); room > 0 {
+		c := len(p)
+		if c > room {
+			c = room
+		}
+		r.buf = append(r.buf, p[:c]...)
+		p = p[c:]
+	}
+	for len(p) > 0 {
+		c := copy(r.buf[r.start:], p)
+		p = p[c:]
+		r.start = (r.start + c) % r.size
+	}
+	return n, nil
+}
+
+//Bytes returns a copy of the content of the buffer, oldest first. It returns nil for a nil buffer.
+func (r *ringBuffer) Bytes() []byte {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]byte, 0, len(r.buf))
+	out = append(out, r.buf[r.start:]...)
+	return append(out, r.buf[:r.start]...)
+}
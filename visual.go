@@ -0,0 +1,268 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+)
+
+// Visual regression testing compares screenshots with baseline PNG files:
+//
+//	opts := webdriver.VisualOptions{Dir: "testdata/visual", MaxMismatch: 0.001,
+//		IgnoreElements: []webdriver.Locator{webdriver.By.ID("clock")}}
+//	if _, err := session.CheckScreenshot("home", opts); err != nil {
+//		t.Error(err)
+//	}
+//
+// Baselines are written, instead of compared, when VisualOptions.Update is set
+// or the environment variable WEBDRIVER_UPDATE_BASELINES is not empty.
+
+// VisualOptions configures the comparison of screenshots with baselines.
+type VisualOptions struct {
+	// Dir holds the baselines, named after the checkpoint: Dir/name.png.
+	// Default: "testdata/visual".
+	Dir string
+	// DiffDir is where the diff image (name.diff.png) and the screenshot
+	// (name.actual.png) of a failed comparison are written. Default: Dir.
+	DiffDir string
+	// Tolerance is the largest difference of a color channel (0-255) between
+	// two pixels that are still considered equal. Default: 0.
+	Tolerance uint8
+	// MaxMismatch is the ratio of the pixels that may differ, from 0 to 1. Default: 0.
+	MaxMismatch float64
+	// Ignore are areas that are not compared, in screenshot pixels.
+	Ignore []image.Rectangle
+	// IgnoreElements are the elements that are not compared, e.g. a clock.
+	// They are located when the screenshot is taken, missing elements are skipped.
+	IgnoreElements []Locator
+	// Update replaces the baselines with the screenshots.
+	Update bool
+}
+
+// ImageDiff is the result of the comparison of two images.
+type ImageDiff struct {
+	// Pixels is the number of the pixels that differ.
+	Pixels int
+	// Total is the number of the pixels compared.
+	Total int
+	// Image shows the pixels that differ in red over a faded copy of the screenshot.
+	Image *image.NRGBA
+}
+
+// Ratio returns the ratio of the pixels that differ.
+func (d *ImageDiff) Ratio() float64 {
+	if d.Total == 0 {
+		return 0
+	}
+	return float64(d.Pixels) / float64(d.Total)
+}
+
+// VisualError is returned when a screenshot doesn't match its baseline.
+type VisualError struct {
+	Name     string
+	Baseline string
+	// Diff is the diff image written, empty if the baseline is missing.
+	Diff string
+	// Mismatch is the ratio of the pixels that differ.
+	Mismatch float64
+}
+
+func (e *VisualError) Error() string {
+	if e.Diff == "" {
+		return fmt.Sprintf("visual: no baseline %s for %q, update the baselines to create it", e.Baseline, e.Name)
+	}
+	return fmt.Sprintf("visual: %q differs from %s in %.3f%% of the pixels, see %s", e.Name, e.Baseline, e.Mismatch*100, e.Diff)
+}
+
+// CompareImages compares actual with baseline pixel by pixel. Pixels inside
+// ignore are not compared. Images of different sizes differ in all the pixels
+// that are not in both.
+func CompareImages(baseline, actual image.Image, tolerance uint8, ignore []image.Rectangle) *ImageDiff {
+	bb, ab := baseline.Bounds(), actual.Bounds()
+	bounds := image.Rect(0, 0, maxInt(bb.Dx(), ab.Dx()), maxInt(bb.Dy(), ab.Dy()))
+	diff := &ImageDiff{Image: image.NewNRGBA(bounds)}
+	t := uint32(tolerance) * 0x101
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			p := image.Pt(x, y)
+			if inRectangles(p, ignore) {
+				diff.Image.SetNRGBA(x, y, color.NRGBA{0, 0, 255, 48})
+				continue
+			}
+			diff.Total++
+			bp, ap := p.Add(bb.Min), p.Add(ab.Min)
+			if !bp.In(bb) || !ap.In(ab) {
+				diff.Pixels++
+				diff.Image.SetNRGBA(x, y, color.NRGBA{255, 0, 0, 255})
+				continue
+			}
+			c1, c2 := baseline.At(bp.X, bp.Y), actual.At(ap.X, ap.Y)
+			if !sameColor(c1, c2, t) {
+				diff.Pixels++
+				diff.Image.SetNRGBA(x, y, color.NRGBA{255, 0, 0, 255})
+				continue
+			}
+			//a faded gray copy, to locate the differences
+			gray := color.GrayModel.Convert(c2).(color.Gray)
+			v := 192 + gray.Y/4
+			diff.Image.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	return diff
+}
+
+func sameColor(c1, c2 color.Color, tolerance uint32) bool {
+	r1, g1, b1, a1 := c1.RGBA()
+	r2, g2, b2, a2 := c2.RGBA()
+	return absDiff(r1, r2) <= tolerance && absDiff(g1, g2) <= tolerance &&
+		absDiff(b1, b2) <= tolerance && absDiff(a1, a2) <= tolerance
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func inRectangles(p image.Point, rs []image.Rectangle) bool {
+	for _, r := range rs {
+		if p.In(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckScreenshot compares a screenshot of the page with the baseline name.
+// It returns the differences found, and a *VisualError if they exceed
+// opts.MaxMismatch. In update mode the baseline is written and the diff is nil.
+func (s *Session) CheckScreenshot(name string, opts VisualOptions) (*ImageDiff, error) {
+	ignore, err := s.ignoreRects(opts.IgnoreElements, image.Point{})
+	if err != nil {
+		return nil, err
+	}
+	img, err := s.ScreenshotImage()
+	if err != nil {
+		return nil, err
+	}
+	return checkImage(name, img, append(ignore, opts.Ignore...), opts)
+}
+
+// CheckScreenshot compares a screenshot of the element with the baseline name,
+// as Session.CheckScreenshot does. opts.Ignore is relative to the element.
+func (e WebElement) CheckScreenshot(name string, opts VisualOptions) (*ImageDiff, error) {
+	var ignore []image.Rectangle
+	if len(opts.IgnoreElements) > 0 {
+		r, err := e.screenshotRect()
+		if err != nil {
+			return nil, err
+		}
+		if ignore, err = e.s.ignoreRects(opts.IgnoreElements, r.Min); err != nil {
+			return nil, err
+		}
+	}
+	img, err := e.ScreenshotImage()
+	if err != nil {
+		return nil, err
+	}
+	return checkImage(name, img, append(ignore, opts.Ignore...), opts)
+}
+
+// the areas of the elements located by ls, relative to origin.
+func (s *Session) ignoreRects(ls []Locator, origin image.Point) ([]image.Rectangle, error) {
+	var rs []image.Rectangle
+	for _, l := range ls {
+		elements, err := s.FindAll(l)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range elements {
+			r, err := e.screenshotRect()
+			if err != nil {
+				return nil, err
+			}
+			rs = append(rs, r.Sub(origin))
+		}
+	}
+	return rs, nil
+}
+
+func checkImage(name string, img image.Image, ignore []image.Rectangle, opts VisualOptions) (*ImageDiff, error) {
+	if opts.Dir == "" {
+		opts.Dir = filepath.Join("testdata", "visual")
+	}
+	if opts.DiffDir == "" {
+		opts.DiffDir = opts.Dir
+	}
+	baselineFile := filepath.Join(opts.Dir, name+".png")
+	actualFile := filepath.Join(opts.DiffDir, name+".actual.png")
+	diffFile := filepath.Join(opts.DiffDir, name+".diff.png")
+	if opts.Update || os.Getenv("WEBDRIVER_UPDATE_BASELINES") != "" {
+		os.Remove(actualFile)
+		os.Remove(diffFile)
+		return nil, writePNG(baselineFile, img)
+	}
+	baseline, err := readPNG(baselineFile)
+	if errors.Is(err, os.ErrNotExist) {
+		if err := writePNG(actualFile, img); err != nil {
+			return nil, err
+		}
+		return nil, &VisualError{Name: name, Baseline: baselineFile}
+	}
+	if err != nil {
+		return nil, err
+	}
+	diff := CompareImages(baseline, img, opts.Tolerance, ignore)
+	if diff.Pixels == 0 || diff.Ratio() <= opts.MaxMismatch {
+		os.Remove(actualFile)
+		os.Remove(diffFile)
+		return diff, nil
+	}
+	if err := writePNG(actualFile, img); err != nil {
+		return diff, err
+	}
+	if err := writePNG(diffFile, diff.Image); err != nil {
+		return diff, err
+	}
+	return diff, &VisualError{Name: name, Baseline: baselineFile, Diff: diffFile, Mismatch: diff.Ratio()}
+}
+
+func readPNG(filename string) (image.Image, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writePNG(filename string, img image.Image) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,113 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompareImages(t *testing.T) {
+	a := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	b := image.NewNRGBA(image.Rect(0, 0, 10, 10))
+	for i := range a.Pix {
+		a.Pix[i], b.Pix[i] = 100, 100
+	}
+	b.SetNRGBA(1, 1, color.NRGBA{103, 100, 100, 100})
+	b.SetNRGBA(8, 8, color.NRGBA{0, 0, 0, 100})
+
+	diff := CompareImages(a, b, 0, nil)
+	if diff.Pixels != 2 || diff.Total != 100 {
+		t.Errorf("got %d/%d different pixels, want 2/100", diff.Pixels, diff.Total)
+	}
+	if diff.Image.NRGBAAt(8, 8) != (color.NRGBA{255, 0, 0, 255}) {
+		t.Errorf("difference not highlighted: %v", diff.Image.NRGBAAt(8, 8))
+	}
+	diff = CompareImages(a, b, 3, []image.Rectangle{image.Rect(5, 5, 10, 10)})
+	if diff.Pixels != 0 || diff.Total != 75 {
+		t.Errorf("got %d/%d different pixels with tolerance and ignore, want 0/75", diff.Pixels, diff.Total)
+	}
+	diff = CompareImages(a, image.NewNRGBA(image.Rect(0, 0, 10, 5)), 255, nil)
+	if diff.Pixels != 50 {
+		t.Errorf("got %d different pixels with a smaller image, want 50", diff.Pixels)
+	}
+}
+
+func TestCheckScreenshot(t *testing.T) {
+	screenshot := testPNG(t)
+	d := newFakeDriver(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/screenshot"):
+			writeValue(w, screenshot)
+		case strings.HasSuffix(r.URL.Path, "/elements"):
+			writeValue(w, []map[string]string{{webElementKey: "clock"}})
+		case strings.HasSuffix(r.URL.Path, "/execute/sync"):
+			writeValue(w, []float64{0, 0, 20.5, 10})
+		}
+	})
+	s := fakeSession(d)
+	dir := t.TempDir()
+	opts := VisualOptions{Dir: dir}
+
+	_, err := s.CheckScreenshot("home", opts)
+	var verr *VisualError
+	if !errors.As(err, &verr) || verr.Diff != "" {
+		t.Fatalf("missing baseline: got %v", err)
+	}
+	opts.Update = true
+	if _, err := s.CheckScreenshot("home", opts); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "home.actual.png")); err == nil {
+		t.Error("the screenshot of the missing baseline was not removed by the update")
+	}
+	opts.Update = false
+	if diff, err := s.CheckScreenshot("home", opts); err != nil || diff.Pixels != 0 {
+		t.Fatalf("same screenshot: got %v, %v", diff, err)
+	}
+
+	//change the top left corner of the baseline
+	baseline, err := readPNG(filepath.Join(dir, "home.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := image.NewNRGBA(baseline.Bounds())
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			changed.Set(x, y, baseline.At(x, y))
+		}
+	}
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 20; x++ {
+			changed.Set(x, y, color.Black)
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, changed)
+	screenshot = buf.Bytes()
+
+	_, err = s.CheckScreenshot("home", opts)
+	if !errors.As(err, &verr) || verr.Mismatch != 200.0/1200 {
+		t.Fatalf("changed screenshot: got %v", err)
+	}
+	if _, err := readPNG(verr.Diff); err != nil {
+		t.Errorf("diff image: %v", err)
+	}
+	opts.IgnoreElements = []Locator{By.ID("clock")}
+	if diff, err := s.CheckScreenshot("home", opts); err != nil || diff.Total != 1200-210 {
+		t.Fatalf("ignored element: got %+v, %v", diff, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "home.diff.png")); err == nil {
+		t.Error("the diff image of the failed comparison was not removed")
+	}
+}
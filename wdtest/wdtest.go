@@ -0,0 +1,276 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package wdtest runs browser sessions from Go tests.
+//
+// New starts a driver, creates a session and deletes both when the test
+// ends. Tests can run in parallel, every test gets its own driver:
+//
+//	func TestLogin(t *testing.T) {
+//		t.Parallel()
+//		session := wdtest.New(t, wdtest.Options{Browser: "chrome"})
+//		if err := session.Url("http://localhost:8080/login"); err != nil {
+//			t.Fatal(err)
+//		}
+//	}
+//
+// When a test fails, a screenshot, the page source, the current URL, the
+// browser console log, the last commands sent and the driver log are saved
+// in a directory named after the test, under Options.ArtifactsDir.
+package wdtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/tooolbox/webdriver"
+)
+
+// Options configures New. The zero value starts chromedriver from PATH.
+type Options struct {
+	// Browser is "chrome", "firefox", "edge", "safari" or "ie11".
+	// Default: $WDTEST_BROWSER, or "chrome".
+	Browser string
+	// DriverPath is the path of the driver executable.
+	// Default: $WDTEST_DRIVER, or the driver found by webdriver.FindDriver,
+	// which fails the test if it doesn't match the version of the browser.
+	DriverPath string
+	// Driver, if set, is a started driver shared between tests, used instead
+	// of starting one. It is not stopped when the test ends.
+	Driver webdriver.WebDriver
+	// Trace is the trace of a shared Driver, with Keep set, that the last
+	// commands are read from. Commands are recorded only if it is set.
+	Trace *webdriver.TraceRecorder
+	// Capabilities of the session.
+	Capabilities webdriver.Capabilities
+	// ArtifactsDir is where the artifacts of the failed tests are saved.
+	// Default: $WDTEST_ARTIFACTS, or "wdtest-artifacts".
+	ArtifactsDir string
+	// Commands is the number of the last commands saved. Default: 50.
+	Commands int
+}
+
+var driverNames = map[string]string{
+	"chrome":  "chromedriver",
+	"firefox": "geckodriver",
+	"edge":    "msedgedriver",
+	"safari":  "safaridriver",
+	"ie11":    "IEDriverServer",
+}
+
+// New returns a new session for the test t, deleted when the test ends. It
+// fails t if the driver or the session can't be started.
+func New(t testing.TB, opts Options) *webdriver.Session {
+	t.Helper()
+	if opts.Commands <= 0 {
+		opts.Commands = 50
+	}
+	if opts.ArtifactsDir == "" {
+		opts.ArtifactsDir = os.Getenv("WDTEST_ARTIFACTS")
+	}
+	if opts.ArtifactsDir == "" {
+		opts.ArtifactsDir = "wdtest-artifacts"
+	}
+
+	driver, trace := opts.Driver, opts.Trace
+	if driver == nil {
+		trace, _ = webdriver.NewTraceRecorder(ioutil.Discard)
+		trace.Keep = opts.Commands
+		//the output is saved from driver.Logs, keep it off the terminal
+		driverLog := filepath.Join(t.TempDir(), "driver.log")
+		var err error
+		if driver, err = startDriver(opts, trace, driverLog); err != nil {
+			t.Fatal("wdtest: " + err.Error())
+		}
+		t.Cleanup(func() { driver.Stop() })
+	}
+
+	session, err := driver.NewSession(opts.Capabilities, nil)
+	if err != nil {
+		t.Fatal("wdtest: new session: " + err.Error())
+	}
+	t.Cleanup(func() {
+		if t.Failed() {
+			dir := filepath.Join(opts.ArtifactsDir, dirName(t.Name()))
+			if err := saveArtifacts(dir, session, lastCommands(trace, session.Id), driver.Logs()); err != nil {
+				t.Logf("wdtest: saving artifacts: %v", err)
+			} else {
+				t.Logf("wdtest: artifacts saved in %s", dir)
+			}
+		}
+		if err := session.Delete(); err != nil {
+			t.Logf("wdtest: delete session: %v", err)
+		}
+	})
+	return session
+}
+
+// start the driver of opts.Browser on a free port.
+func startDriver(opts Options, trace *webdriver.TraceRecorder, logFile string) (webdriver.WebDriver, error) {
+	browser := opts.Browser
+	if browser == "" {
+		browser = os.Getenv("WDTEST_BROWSER")
+	}
+	if browser == "" {
+		browser = "chrome"
+	}
+	path := opts.DriverPath
+	if path == "" {
+		path = os.Getenv("WDTEST_DRIVER")
+	}
+	if path == "" && browser != "ie11" {
+		info, err := webdriver.FindDriver(browser, webdriver.DiscoverOptions{})
+		var mismatch *webdriver.VersionMismatchError
+		if errors.As(err, &mismatch) {
+			return nil, err
+		}
+		path = info.Path
+	}
+	if path == "" {
+		path = driverNames[browser]
+	}
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	var driver webdriver.WebDriver
+	switch browser {
+	case "chrome":
+		d := webdriver.NewChromeDriver(path)
+		d.Port, d.LogFile, d.Trace = port, logFile, trace
+		driver = d
+	case "firefox":
+		d := webdriver.NewFirefoxDriver(path)
+		d.Port, d.LogFile, d.Trace = port, logFile, trace
+		driver = d
+	case "edge":
+		d := webdriver.NewEdgeDriver(path)
+		d.Port, d.LogFile, d.Trace = port, logFile, trace
+		driver = d
+	case "safari":
+		d := webdriver.NewSafariDriver(path)
+		d.Port, d.LogFile, d.Trace = port, logFile, trace
+		driver = d
+	case "ie11":
+		d := webdriver.NewIE11Driver(path)
+		d.Port, d.LogFile, d.Trace = port, logFile, trace
+		driver = d
+	default:
+		return nil, fmt.Errorf("unknown browser %q", browser)
+	}
+	if err := driver.Start(); err != nil {
+		return nil, err
+	}
+	return driver, nil
+}
+
+func freePort() (int, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port, nil
+}
+
+var unsafeChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// the directory of the artifacts of the test name, e.g. "TestLogin/bad password"
+// is saved in "TestLogin/bad_password".
+func dirName(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = unsafeChars.ReplaceAllString(part, "_")
+	}
+	return filepath.Join(parts...)
+}
+
+// the last commands of the session, or nil if they are not recorded.
+func lastCommands(trace *webdriver.TraceRecorder, sessionID string) []webdriver.TraceCommand {
+	if trace == nil {
+		return nil
+	}
+	var commands []webdriver.TraceCommand
+	for _, c := range trace.Last() {
+		if c.Path == "/session" || strings.HasPrefix(c.Path, "/session/"+sessionID) {
+			commands = append(commands, c)
+		}
+	}
+	return commands
+}
+
+// save the state of the session in dir. A missing artifact doesn't prevent
+// saving the others, the first error is returned.
+func saveArtifacts(dir string, session *webdriver.Session, commands []webdriver.TraceCommand, driverLog []byte) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var firstErr error
+	save := func(name string, content func(w io.Writer) error) {
+		f, err := os.Create(filepath.Join(dir, name))
+		if err == nil {
+			err = content(f)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	if commands != nil {
+		save("commands.log", func(w io.Writer) error {
+			for _, c := range commands {
+				if _, err := fmt.Fprintln(w, c); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	save("screenshot.png", session.WriteScreenshot)
+	save("source.html", func(w io.Writer) error {
+		source, err := session.Source()
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, source)
+		return err
+	})
+	save("url.txt", func(w io.Writer) error {
+		url, err := session.GetUrl()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, url)
+		return err
+	})
+	save("console.log", func(w io.Writer) error {
+		entries, err := session.Log("browser")
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if _, err := fmt.Fprintf(w, "%s %s %s\n", e.Time().Format("15:04:05.000"), e.Level, e.Message); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if driverLog != nil {
+		save("driver.log", func(w io.Writer) error {
+			_, err := w.Write(driverLog)
+			return err
+		})
+	}
+	return firstErr
+}
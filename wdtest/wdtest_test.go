@@ -0,0 +1,122 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package wdtest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tooolbox/webdriver"
+)
+
+// fakeT records what New does with a test, to fail it without failing the real one.
+type fakeT struct {
+	testing.TB
+	name     string
+	failed   bool
+	cleanups []func()
+	logs     []string
+}
+
+func (t *fakeT) Helper()                                   {}
+func (t *fakeT) Name() string                              { return t.name }
+func (t *fakeT) Failed() bool                              { return t.failed }
+func (t *fakeT) Cleanup(f func())                          { t.cleanups = append(t.cleanups, f) }
+func (t *fakeT) Logf(format string, args ...interface{})   { t.logs = append(t.logs, format) }
+func (t *fakeT) Fatal(args ...interface{})                 { panic(args) }
+func (t *fakeT) Errorf(format string, args ...interface{}) { t.failed = true }
+
+func (t *fakeT) cleanup() {
+	for i := len(t.cleanups) - 1; i >= 0; i-- {
+		t.cleanups[i]()
+	}
+}
+
+func command(method, path, params string, value interface{}) webdriver.TraceCommand {
+	response, _ := json.Marshal(map[string]interface{}{"value": value})
+	c := webdriver.TraceCommand{Method: method, Path: path, Status: 200, Response: response}
+	if params != "" {
+		c.Params = json.RawMessage(params)
+	}
+	return c
+}
+
+func TestArtifacts(t *testing.T) {
+	driver := webdriver.NewReplayDriver([]webdriver.TraceCommand{
+		command("POST", "/session", `{"capabilities":{"alwaysMatch":{}},"desiredCapabilities":{},"requiredCapabilities":null}`,
+			map[string]interface{}{"sessionId": "s1", "capabilities": map[string]interface{}{"browserName": "fake"}}),
+		command("GET", "/session/s1/title", "", "Login"),
+		//saved by the cleanup
+		command("GET", "/session/s1/screenshot", "", "iVBORw0KGgo="),
+		command("GET", "/session/s1/source", "", "<html></html>"),
+		command("GET", "/session/s1/url", "", "http://example.com/login"),
+		command("POST", "/session/s1/log", `{"type":"browser"}`, []map[string]interface{}{
+			{"level": "SEVERE", "message": "Uncaught Error: boom", "timestamp": 1700000000000},
+		}),
+		command("DELETE", "/session/s1", "", nil),
+	})
+	trace, err := webdriver.NewTraceRecorder(ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace.Keep = 10
+	driver.Trace = trace
+
+	dir := t.TempDir()
+	ft := &fakeT{name: "TestLogin/bad password"}
+	session := New(ft, Options{Driver: driver, Trace: trace, ArtifactsDir: dir})
+	if _, err := session.Title(); err != nil {
+		t.Fatal(err)
+	}
+	ft.failed = true
+	ft.cleanup()
+	if err := driver.Done(); err != nil {
+		t.Fatal(err)
+	}
+
+	artifacts := filepath.Join(dir, "TestLogin", "bad_password")
+	want := map[string]string{
+		"screenshot.png": "\x89PNG\r\n\x1a\n",
+		"source.html":    "<html></html>",
+		"url.txt":        "http://example.com/login\n",
+		"console.log":    "SEVERE Uncaught Error: boom\n",
+		"commands.log":   "#2 GET /session/s1/title: 200",
+	}
+	for name, content := range want {
+		data, err := ioutil.ReadFile(filepath.Join(artifacts, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !strings.Contains(string(data), content) {
+			t.Errorf("%s = %q, want %q", name, data, content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(artifacts, "driver.log")); err == nil {
+		t.Error("driver log saved for a shared driver")
+	}
+}
+
+func TestPassedTestHasNoArtifacts(t *testing.T) {
+	driver := webdriver.NewReplayDriver([]webdriver.TraceCommand{
+		command("POST", "/session", `{"capabilities":{"alwaysMatch":{}},"desiredCapabilities":{},"requiredCapabilities":null}`,
+			map[string]interface{}{"sessionId": "s1", "capabilities": map[string]interface{}{}}),
+		command("DELETE", "/session/s1", "", nil),
+	})
+	dir := t.TempDir()
+	ft := &fakeT{name: "TestOK"}
+	New(ft, Options{Driver: driver, ArtifactsDir: dir})
+	ft.cleanup()
+	if err := driver.Done(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("artifacts saved for a passed test")
+	}
+}
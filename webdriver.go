@@ -5,14 +5,14 @@
 package webdriver
 
 import (
-	"bytes"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"strconv"
-	"strings"
+	"io"
+	"reflect"
+	"sync"
+	"time"
 	//	"fmt"
 	//	"net/http"
 )
@@ -27,9 +27,15 @@ type WebDriver interface {
 	//Create a new session.
 	NewSession(desired, required Capabilities) (*Session, error)
 	//Returns a list of the currently active sessions.
-	Sessions() ([]Session, error)
+	Sessions() ([]*Session, error)
+	//Delete all the sessions created with NewSession.
+	DeleteAllSessions() error
+	//Returns the last output of the driver process, up to 1 MiB; nil if the driver is not a local process.
+	Logs() []byte
 
-	do(params interface{}, method, urlFormat string, urlParams ...interface{}) (string, []byte, error)
+	do(ctx context.Context, params interface{}, method, urlFormat string, urlParams ...interface{}) (string, []byte, error)
+	doStream(ctx context.Context, fn func(value io.Reader) error, params interface{}, method, urlFormat string, urlParams ...interface{}) error
+	forget(id string)
 }
 
 //typing saver
@@ -59,11 +65,21 @@ type OS struct {
 type Capabilities map[string]interface{}
 
 //A session.
+//A Session is a handle shared by the values derived from it (elements, windows): its state, such as the timeouts and the current frame, persists between calls.
+//It is safe for concurrent use; commands sent from several goroutines are run by the driver in the order it receives them.
 type Session struct {
 	Id           string
 	Capabilities Capabilities
 	wd           WebDriver
-	timeouts     params
+
+	mu      sync.Mutex
+	ctx     context.Context
+	browser *BrowserCapabilities
+	frames  []interface{}
+
+	//held across the timeouts command, so the driver ends with the timeouts cached last
+	timeoutsMu sync.Mutex
+	timeouts   params
 }
 
 type WindowHandle struct {
@@ -102,15 +118,47 @@ const (
 	XPath = FindElementStrategy("xpath")
 )
 
-type element struct {
-	ELEMENT string
-}
+//The key that identifies a web element reference in the W3C dialect; the JSON Wire Protocol uses "ELEMENT".
+const webElementKey = "element-6066-11e4-a52e-4f735466cecf"
 
 type WebElement struct {
 	s  *Session
 	id string
 }
 
+//Encode the element as a web element reference, using the key expected by the dialect of its session.
+func (e WebElement) MarshalJSON() ([]byte, error) {
+	key := "ELEMENT"
+	if e.s != nil && e.s.w3c() {
+		key = webElementKey
+	}
+	return json.Marshal(map[string]string{key: e.id})
+}
+
+//Decode a web element reference of either dialect. The element is not bound to a session.
+func (e *WebElement) UnmarshalJSON(data []byte) error {
+	var ref map[string]interface{}
+	if err := json.Unmarshal(data, &ref); err != nil {
+		return err
+	}
+	id, ok := elementReference(ref)
+	if !ok {
+		return errors.New("not a web element reference: " + string(data))
+	}
+	e.id = id
+	return nil
+}
+
+//returns the id of the element referenced by m, if m is a web element reference.
+func elementReference(m map[string]interface{}) (string, bool) {
+	for _, key := range []string{webElementKey, "ELEMENT"} {
+		if id, ok := m[key].(string); ok {
+			return id, true
+		}
+	}
+	return "", false
+}
+
 type Cookie struct {
 	Name   string
 	Value  string
@@ -138,9 +186,15 @@ const (
 )
 
 type LogEntry struct {
-	TimeStamp int //TODO timestamp number type?
+	TimeStamp int //milliseconds since the epoch
 	Level     string
 	Message   string
+	Source    string //e.g. "javascript", "console-api", "network" (chromedriver only)
+}
+
+//Time returns the timestamp of the entry.
+func (e LogEntry) Time() time.Time {
+	return time.Unix(0, int64(e.TimeStamp)*int64(time.Millisecond))
 }
 
 type HTML5CacheStatus int
@@ -160,96 +214,134 @@ const (
 // https://code.google.com/p/selenium/wiki/JsonWireProtocol
 ////////////////////////////////////////////////////////////////////////////////
 
+//returns true if the session was created by a driver that speaks the W3C dialect.
+func (s *Session) w3c() bool {
+	_, ok := s.Capabilities["capabilities"].(map[string]interface{})
+	return ok
+}
+
+//returns the capability named name, as returned by the driver in either dialect.
+func (s *Session) capability(name string) interface{} {
+	if capabilities, ok := s.Capabilities["capabilities"].(map[string]interface{}); ok {
+		return capabilities[name]
+	}
+	return s.Capabilities[name]
+}
+
+//SetContext sets the context of the commands of the session: they are canceled when ctx is done and are observed as part of it (see CommandObserver), e.g. under the span of the running test.
+func (s *Session) SetContext(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx = ctx
+}
+
+//Context returns the context of the commands of the session, context.Background() by default.
+func (s *Session) Context() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+//send a command of the session to its driver.
+func (s *Session) do(params interface{}, method, urlFormat string, urlParams ...interface{}) (string, []byte, error) {
+	return s.wd.do(s.Context(), params, method, urlFormat, urlParams...)
+}
+
+//send a command of the session whose response value is streamed to fn.
+func (s *Session) doStream(fn func(value io.Reader) error, params interface{}, method, urlFormat string, urlParams ...interface{}) error {
+	return s.wd.doStream(s.Context(), fn, params, method, urlFormat, urlParams...)
+}
+
 //Retrieve the capabilities of the specified session.
-func (s Session) GetCapabilities() Capabilities {
+func (s *Session) GetCapabilities() Capabilities {
 	// GET /session/:sessionId
 	// I have the capabilities stored in Session already
 	return s.Capabilities
 }
 
 //Delete the session.
-func (s Session) Delete() error {
-	_, _, err := s.wd.do(nil, "DELETE", "/session/%s", s.Id)
+func (s *Session) Delete() error {
+	_, _, err := s.do(nil, "DELETE", "/session/%s", s.Id)
+	if err == nil || isNoSession(err) {
+		s.wd.forget(s.Id)
+	}
 	return err
 }
 
 //Configure the amount of time that a particular type of operation can execute for before they are aborted and a |Timeout| error is returned to the client.  Valid values are: "script" for script timeouts, "implicit" for modifying the implicit wait timeout and "page load" for setting a page load timeout.
-func (s Session) SetTimeouts(typ string, ms int) error {
+func (s *Session) SetTimeouts(typ string, ms int) error {
+	if !s.Supports(FeatureW3CTimeouts) {
+		_, _, err := s.do(params{"type": typ, "ms": ms}, "POST", "/session/%s/timeouts", s.Id)
+		return err
+	}
+	//the W3C timeouts command sets all the timeouts at once: those set before, starting from those the session was created with, are sent again
+	s.timeoutsMu.Lock()
+	defer s.timeoutsMu.Unlock()
 	p := params{}
-	if capabilities, ok := s.Capabilities["capabilities"].(map[string]interface{}); ok {
-		browserName := capabilities["browserName"].(string)
-		browserVersion := capabilities["browserVersion"].(string)
-		bv, _ := strconv.ParseFloat(browserVersion, 64)
-		if browserName == "firefox" || (browserName == "Safari" && bv >= 12.0) {
-			if s.timeouts == nil {
-				s.timeouts = params{
-					"script":   30_000,
-					"pageLoad": 300_000,
-					"implicit": 0,
-				}
-			}
-			switch typ {
-			case "script", "implicit":
-				s.timeouts[typ] = ms
-			case "page load":
-				s.timeouts["pageLoad"] = ms
-			}
-			for k, v := range s.timeouts {
+	if s.timeouts == nil {
+		if created, ok := s.capability("timeouts").(map[string]interface{}); ok {
+			for k, v := range created {
 				p[k] = v
 			}
 		}
-	} else {
-		p = params{"type": typ, "ms": ms}
 	}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/timeouts", s.Id)
-	return err
+	for k, v := range s.timeouts {
+		p[k] = v
+	}
+	switch typ {
+	case "script", "implicit":
+		p[typ] = ms
+	case "page load":
+		p["pageLoad"] = ms
+	}
+	if _, _, err := s.do(p, "POST", "/session/%s/timeouts", s.Id); err != nil {
+		return err
+	}
+	s.timeouts = p
+	return nil
 }
 
 //Set the amount of time, in milliseconds, that asynchronous scripts executed by ExecuteScriptAsync() are permitted to run before they are aborted and a |Timeout| error is returned to the client.
-func (s Session) SetTimeoutsAsyncScript(ms int) error {
+func (s *Session) SetTimeoutsAsyncScript(ms int) error {
 	p := params{"ms": ms}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/timeouts/async_script", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/timeouts/async_script", s.Id)
 	return err
 }
 
 //Set the amount of time the driver should wait when searching for elements. When searching for a single element, the driver should poll the page until an element is found or the timeout expires, whichever occurs first. When searching for multiple elements, the driver should poll the page until at least one element is found or the timeout expires, at which point it should return an empty list.
 //If this command is never sent, the driver should default to an implicit wait of 0ms.
-func (s Session) SetTimeoutsImplicitWait(ms int) error {
+func (s *Session) SetTimeoutsImplicitWait(ms int) error {
 	p := params{"ms": ms}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/timeouts/implicit_wait", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/timeouts/implicit_wait", s.Id)
 	return err
 }
 
-func (s Session) GetCurrentWindowHandle() WindowHandle {
-	return WindowHandle{&s, "current"}
+func (s *Session) GetCurrentWindowHandle() WindowHandle {
+	return WindowHandle{s, "current"}
 }
 
 //Retrieve the current window handle.
-func (s Session) WindowHandle() (WindowHandle, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/window_handle", s.Id)
+func (s *Session) WindowHandle() (WindowHandle, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/window_handle", s.Id)
 	if err != nil {
 		return WindowHandle{}, err
 	}
 	var handle string
 	err = json.Unmarshal(data, &handle)
-	return WindowHandle{&s, handle}, err
+	return WindowHandle{s, handle}, err
 }
 
 //Retrieve the list of all window handles available to the session.
-func (s Session) WindowHandles() ([]WindowHandle, error) {
-	var browserName string
-	var browserVersion float64
-	if capabilities, ok := s.Capabilities["capabilities"].(map[string]interface{}); ok {
-		browserName = capabilities["browserName"].(string)
-		bv := capabilities["browserVersion"].(string)
-		browserVersion, _ = strconv.ParseFloat(bv, 64)
-	}
+func (s *Session) WindowHandles() ([]WindowHandle, error) {
 	var err error
 	var data []byte
-	if browserName == "Safari" && browserVersion >= 12.0 {
-		_, data, err = s.wd.do(nil, "GET", "/session/%s/window/handles", s.Id)
+	if s.Supports(FeatureW3CWindowHandles) {
+		_, data, err = s.do(nil, "GET", "/session/%s/window/handles", s.Id)
 	} else {
-		_, data, err = s.wd.do(nil, "GET", "/session/%s/window_handles", s.Id)
+		_, data, err = s.do(nil, "GET", "/session/%s/window_handles", s.Id)
 	}
 	if err != nil {
 		return nil, err
@@ -261,14 +353,14 @@ func (s Session) WindowHandles() ([]WindowHandle, error) {
 	}
 	var handles = make([]WindowHandle, len(hv))
 	for i, h := range hv {
-		handles[i] = WindowHandle{&s, h}
+		handles[i] = WindowHandle{s, h}
 	}
 	return handles, nil
 }
 
 //Retrieve the URL of the current page.
-func (s Session) GetUrl() (string, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/url", s.Id)
+func (s *Session) GetUrl() (string, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/url", s.Id)
 	if err != nil {
 		return "", err
 	}
@@ -278,49 +370,47 @@ func (s Session) GetUrl() (string, error) {
 }
 
 //Navigate to a new URL.
-func (s Session) Url(url string) error {
+func (s *Session) Url(url string) error {
 	p := params{"url": url}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/url", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/url", s.Id)
+	s.setFrames(nil, err)
 	return err
 }
 
 //Navigate forwards in the browser history, if possible.
-func (s Session) Forward() error {
-	_, _, err := s.wd.do(nil, "POST", "/session/%s/forward", s.Id)
+func (s *Session) Forward() error {
+	_, _, err := s.do(nil, "POST", "/session/%s/forward", s.Id)
+	s.setFrames(nil, err)
 	return err
 }
 
 //Navigate backwards in the browser history, if possible.
-func (s Session) Back() error {
-	_, _, err := s.wd.do(nil, "POST", "/session/%s/back", s.Id)
+func (s *Session) Back() error {
+	_, _, err := s.do(nil, "POST", "/session/%s/back", s.Id)
+	s.setFrames(nil, err)
 	return err
 }
 
 //Refresh the current page.
-func (s Session) Refresh() error {
-	_, _, err := s.wd.do(nil, "POST", "/session/%s/refresh", s.Id)
+func (s *Session) Refresh() error {
+	_, _, err := s.do(nil, "POST", "/session/%s/refresh", s.Id)
+	s.setFrames(nil, err)
 	return err
 }
 
 // Inject a snippet of JavaScript into the page for execution in the context of the currently selected frame. The executed script is assumed to be synchronous and the result of evaluating the script is returned to the client.
 // The script argument defines the script to execute in the form of a function body. The value returned by that function will be returned to the client. The function will be invoked with the provided args array and the values may be accessed via the arguments object in the order specified.
 // Arguments may be any JSON-primitive, array, or JSON object. JSON objects that define a WebElement reference will be converted to the corresponding DOM element. Likewise, any WebElements in the script result will be returned to the client as WebElement JSON objects.
-func (s Session) ExecuteScript(script string, args []interface{}) ([]byte, error) {
+func (s *Session) ExecuteScript(script string, args []interface{}) ([]byte, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
 	p := params{"script": script, "args": args}
-	var data []byte
-	var err error
-	if capabilities, ok := s.Capabilities["capabilities"].(map[string]interface{}); ok {
-		browserName := capabilities["browserName"].(string)
-		var browserVersion float64
-		if val, ok := capabilities["browserVersion"]; ok {
-			browserVersion, _ = strconv.ParseFloat(val.(string), 64)
-		}
-		if browserName == "firefox" || (browserName == "Safari" && browserVersion >= 12.0) {
-			_, data, err = s.wd.do(p, "POST", "/session/%s/execute/sync", s.Id)
-		}
-	} else {
-		_, data, err = s.wd.do(p, "POST", "/session/%s/execute", s.Id)
+	if s.w3c() {
+		_, data, err := s.do(p, "POST", "/session/%s/execute/sync", s.Id)
+		return data, err
 	}
+	_, data, err := s.do(p, "POST", "/session/%s/execute", s.Id)
 	return data, err
 }
 
@@ -328,30 +418,101 @@ func (s Session) ExecuteScript(script string, args []interface{}) ([]byte, error
 // Asynchronous script commands may not span page loads. If an unload event is fired while waiting for a script result, an error should be returned to the client.
 // The script argument defines the script to execute in teh form of a function body. The function will be invoked with the provided args array and the values may be accessed via the arguments object in the order specified. The final argument will always be a callback function that must be invoked to signal that the script has finished.
 // Arguments may be any JSON-primitive, array, or JSON object. JSON objects that define a WebElement reference will be converted to the corresponding DOM element. Likewise, any WebElements in the script result will be returned to the client as WebElement JSON objects.
-func (s Session) ExecuteScriptAsync(script string, args []interface{}) ([]byte, error) {
+func (s *Session) ExecuteScriptAsync(script string, args []interface{}) ([]byte, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
 	p := params{"script": script, "args": args}
-	_, data, err := s.wd.do(p, "POST", "/session/%s/execute_async", s.Id)
+	if s.w3c() {
+		_, data, err := s.do(p, "POST", "/session/%s/execute/async", s.Id)
+		return data, err
+	}
+	_, data, err := s.do(p, "POST", "/session/%s/execute_async", s.Id)
 	return data, err
 }
 
-//Take a screenshot of the current page.
-func (s Session) Screenshot() ([]byte, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/screenshot", s.Id)
+//Execute a synchronous script (see ExecuteScript) and decode its result into v.
+//Element references in the result, including those nested in arrays and objects, are decoded as WebElements bound to the session. This applies to fields of type WebElement as well as to values decoded into an interface{}.
+func (s *Session) ExecuteScriptInto(script string, args []interface{}, v interface{}) error {
+	data, err := s.ExecuteScript(script, args)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	return s.decodeResult(data, v)
+}
+
+//Execute an asynchronous script (see ExecuteScriptAsync) and decode its result into v as ExecuteScriptInto does.
+func (s *Session) ExecuteScriptAsyncInto(script string, args []interface{}, v interface{}) error {
+	data, err := s.ExecuteScriptAsync(script, args)
+	if err != nil {
+		return err
 	}
+	return s.decodeResult(data, v)
+}
 
-	stringData := string(data)
-	stringDataFixed := strings.Replace(stringData, `\`, ``, -1)
-	stringDataBytes := []byte(stringDataFixed)
-	reader := bytes.NewBuffer(stringDataBytes[1 : len(stringDataBytes)-1])
-	decoder := base64.NewDecoder(base64.StdEncoding, reader)
-	return ioutil.ReadAll(decoder)
+//decode a command result into v and bind every element reference found in it to the session.
+func (s *Session) decodeResult(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	s.bindElements(reflect.ValueOf(v))
+	return nil
+}
+
+//walk v setting the session of every WebElement and replacing element references held in interface values with WebElements.
+func (s *Session) bindElements(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			s.bindElements(v.Elem())
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		if m, ok := v.Interface().(map[string]interface{}); ok {
+			if id, ok := elementReference(m); ok && v.CanSet() {
+				v.Set(reflect.ValueOf(WebElement{s, id}))
+				return
+			}
+		}
+		//values held by an interface are not addressable, work on a copy
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		s.bindElements(elem)
+		if v.CanSet() {
+			v.Set(elem)
+		}
+	case reflect.Struct:
+		if v.Type() == reflect.TypeOf(WebElement{}) {
+			if v.CanAddr() {
+				v.Addr().Interface().(*WebElement).s = s
+			}
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Field(i).CanSet() {
+				s.bindElements(v.Field(i))
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			s.bindElements(v.Index(i))
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(iter.Value().Type()).Elem()
+			elem.Set(iter.Value())
+			s.bindElements(elem)
+			v.SetMapIndex(iter.Key(), elem)
+		}
+	}
 }
 
 //List all available engines on the machine.
-func (s Session) IMEAvailableEngines() ([]string, error) {
-	_, data, err := s.wd.do(nil, "GET", "session/%s/ime/available_engines", s.Id)
+func (s *Session) IMEAvailableEngines() ([]string, error) {
+	_, data, err := s.do(nil, "GET", "session/%s/ime/available_engines", s.Id)
 	if err != nil {
 		return nil, err
 	}
@@ -361,8 +522,8 @@ func (s Session) IMEAvailableEngines() ([]string, error) {
 }
 
 //Get the name of the active IME engine.
-func (s Session) IMEActiveEngine() (string, error) {
-	_, data, err := s.wd.do(nil, "GET", "session/%s/ime/active_engine", s.Id)
+func (s *Session) IMEActiveEngine() (string, error) {
+	_, data, err := s.do(nil, "GET", "session/%s/ime/active_engine", s.Id)
 	if err != nil {
 		return "", err
 	}
@@ -372,8 +533,8 @@ func (s Session) IMEActiveEngine() (string, error) {
 }
 
 //Indicates whether IME input is active at the moment (not if it's available).
-func (s Session) IsIMEActivated() (bool, error) {
-	_, data, err := s.wd.do(nil, "GET", "session/%s/ime/activated", s.Id)
+func (s *Session) IsIMEActivated() (bool, error) {
+	_, data, err := s.do(nil, "GET", "session/%s/ime/activated", s.Id)
 	if err != nil {
 		return false, err
 	}
@@ -383,20 +544,20 @@ func (s Session) IsIMEActivated() (bool, error) {
 }
 
 //De-activates the currently-active IME engine.
-func (s Session) IMEDeactivate() error {
-	_, _, err := s.wd.do(nil, "GET", "session/%s/ime/deactivate", s.Id)
+func (s *Session) IMEDeactivate() error {
+	_, _, err := s.do(nil, "GET", "session/%s/ime/deactivate", s.Id)
 	return err
 }
 
 //Make an engines that is available (appears on the list returned by getAvailableEngines) active.
-func (s Session) IMEActivate(engine string) error {
+func (s *Session) IMEActivate(engine string) error {
 	p := params{"engine": engine}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/ime/activate", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/ime/activate", s.Id)
 	return err
 }
 
 //Change focus to another frame on the page.
-func (s Session) FocusOnFrame(frameId interface{}) error {
+func (s *Session) FocusOnFrame(frameId interface{}) error {
 	if frameId != nil {
 		switch frameId.(type) {
 		case string:
@@ -407,27 +568,51 @@ func (s Session) FocusOnFrame(frameId interface{}) error {
 		}
 	}
 	p := params{"id": frameId}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/frame", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/frame", s.Id)
+	if frameId == nil {
+		s.setFrames(nil, err)
+	} else if err == nil {
+		s.mu.Lock()
+		s.frames = append(s.frames, frameId)
+		s.mu.Unlock()
+	}
 	return err
 }
 
 // Change focus back to parent frame
-func (s Session) FocusParentFrame() error {
-	_, _, err := s.wd.do(nil, "POST", "/session/%s/frame/parent", s.Id)
+func (s *Session) FocusParentFrame() error {
+	_, _, err := s.do(nil, "POST", "/session/%s/frame/parent", s.Id)
+	if err == nil {
+		s.mu.Lock()
+		if n := len(s.frames); n > 0 {
+			s.frames = s.frames[:n-1]
+		}
+		s.mu.Unlock()
+	}
 	return err
 }
 
-//Change focus to another window. The window to change focus to may be specified by its server assigned window handle, or by the value of its name attribute.
-func (s Session) FocusOnWindow(name string) error {
-	var browserName string
-	var browserVersion float64
-	if capabilities, ok := s.Capabilities["capabilities"].(map[string]interface{}); ok {
-		browserName = capabilities["browserName"].(string)
-		if bv, ok := capabilities["browserVersion"]; ok {
-			browserVersion, _ = strconv.ParseFloat(bv.(string), 64)
-		}
+//CurrentFrame returns the path of the frame with the focus, from the outermost frame, as passed to FocusOnFrame. It is empty for the top-level browsing context.
+//The path is reset by a change of window or a navigation of the session, but not by a navigation started by the page.
+func (s *Session) CurrentFrame() []interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]interface{}(nil), s.frames...)
+}
+
+//setFrames sets the frame path after a command that changes it, unless the command failed.
+func (s *Session) setFrames(frames []interface{}, err error) {
+	if err != nil {
+		return
 	}
-	if browserName == "Safari" && browserVersion >= 12.0 {
+	s.mu.Lock()
+	s.frames = frames
+	s.mu.Unlock()
+}
+
+//Change focus to another window. The window to change focus to may be specified by its server assigned window handle, or by the value of its name attribute.
+func (s *Session) FocusOnWindow(name string) error {
+	if !s.Supports(FeatureSwitchWindowByName) {
 		handles, err := s.WindowHandles()
 		if err != nil {
 			return fmt.Errorf("FocusOnWindow failed to get handles: %w", err)
@@ -461,27 +646,29 @@ func (s Session) FocusOnWindow(name string) error {
 		return fmt.Errorf("FocusOnWindow found no window matching `%s`", name)
 	} else {
 		p := params{"name": name}
-		_, _, err := s.wd.do(p, "POST", "/session/%s/window", s.Id)
+		_, _, err := s.do(p, "POST", "/session/%s/window", s.Id)
+		s.setFrames(nil, err)
 		return err
 	}
 }
 
 //Close the current window.
-func (s Session) CloseCurrentWindow() error {
-	_, _, err := s.wd.do(nil, "DELETE", "/session/%s/window", s.Id)
+func (s *Session) CloseCurrentWindow() error {
+	_, _, err := s.do(nil, "DELETE", "/session/%s/window", s.Id)
+	s.setFrames(nil, err)
 	return err
 }
 
 //Change the size of the specified window.
 func (w WindowHandle) SetSize(size Size) error {
 	p := params{"width": size.Width, "height": size.Height}
-	_, _, err := w.s.wd.do(p, "POST", "/session/%s/window/%s/size", w.s.Id, w.id)
+	_, _, err := w.s.do(p, "POST", "/session/%s/window/%s/size", w.s.Id, w.id)
 	return err
 }
 
 //Get the size of the specified window.
 func (w WindowHandle) GetSize() (Size, error) {
-	_, data, err := w.s.wd.do(nil, "GET", "/session/%s/window/%s/size", w.s.Id, w.id)
+	_, data, err := w.s.do(nil, "GET", "/session/%s/window/%s/size", w.s.Id, w.id)
 	if err != nil {
 		return Size{}, err
 	}
@@ -493,13 +680,13 @@ func (w WindowHandle) GetSize() (Size, error) {
 //Change the position of the specified window.
 func (w WindowHandle) SetPosition(position Position) error {
 	p := params{"x": position.X, "y": position.Y}
-	_, _, err := w.s.wd.do(p, "POST", "/session/%s/window/%s/position", w.s.Id, w.id)
+	_, _, err := w.s.do(p, "POST", "/session/%s/window/%s/position", w.s.Id, w.id)
 	return err
 }
 
 //Get the position of the specified window.
 func (w WindowHandle) GetPosition() (Position, error) {
-	_, data, err := w.s.wd.do(nil, "GET", "/session/%s/window/%s/position", w.s.Id, w.id)
+	_, data, err := w.s.do(nil, "GET", "/session/%s/window/%s/position", w.s.Id, w.id)
 	if err != nil {
 		return Position{}, err
 	}
@@ -510,14 +697,15 @@ func (w WindowHandle) GetPosition() (Position, error) {
 
 //Maximize the specified window if not already maximized.
 func (w WindowHandle) MaximizeWindow() error {
-	_, _, err := w.s.wd.do(nil, "POST", "/session/%s/window/%s/maximize", w.s.Id, w.id)
+	_, _, err := w.s.do(nil, "POST", "/session/%s/window/%s/maximize", w.s.Id, w.id)
 	return err
 }
 
 //Maximize the specified window if not already maximized.
 func (w WindowHandle) SwitchTo() error {
 	p := params{"handle": w.id}
-	_, _, err := w.s.wd.do(p, "POST", "/session/%s/window", w.s.Id)
+	_, _, err := w.s.do(p, "POST", "/session/%s/window", w.s.Id)
+	w.s.setFrames(nil, err)
 	return err
 }
 
@@ -526,8 +714,8 @@ func (w WindowHandle) Id() string {
 }
 
 //Retrieve all cookies visible to the current page.
-func (s Session) GetCookies() ([]Cookie, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/cookie", s.Id)
+func (s *Session) GetCookies() ([]Cookie, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/cookie", s.Id)
 	if err != nil {
 		return nil, err
 	}
@@ -537,27 +725,27 @@ func (s Session) GetCookies() ([]Cookie, error) {
 }
 
 //Set a cookie.
-func (s Session) SetCookie(cookie Cookie) error {
+func (s *Session) SetCookie(cookie Cookie) error {
 	p := params{"cookie": cookie}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/cookie", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/cookie", s.Id)
 	return err
 }
 
 //Delete all cookies visible to the current page.
-func (s Session) DeleteCookies() error {
-	_, _, err := s.wd.do(nil, "DELETE", "/session/%s/cookie", s.Id)
+func (s *Session) DeleteCookies() error {
+	_, _, err := s.do(nil, "DELETE", "/session/%s/cookie", s.Id)
 	return err
 }
 
 //Delete the cookie with the given name.
-func (s Session) DeleteCookieByName(name string) error {
-	_, _, err := s.wd.do(nil, "DELETE", "/session/%s/cookie/%s", s.Id, name)
+func (s *Session) DeleteCookieByName(name string) error {
+	_, _, err := s.do(nil, "DELETE", "/session/%s/cookie/%s", s.Id, name)
 	return err
 }
 
 //Get the current page source.
-func (s Session) Source() (string, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/source", s.Id)
+func (s *Session) Source() (string, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/source", s.Id)
 	if err != nil {
 		return "", err
 	}
@@ -567,8 +755,8 @@ func (s Session) Source() (string, error) {
 }
 
 //Get the current page title.
-func (s Session) Title() (string, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/title", s.Id)
+func (s *Session) Title() (string, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/title", s.Id)
 	if err != nil {
 		return "", err
 	}
@@ -577,59 +765,46 @@ func (s Session) Title() (string, error) {
 	return title, err
 }
 
-func (s Session) WebElementFromId(id string) WebElement {
-	return WebElement{&s, id}
+func (s *Session) WebElementFromId(id string) WebElement {
+	return WebElement{s, id}
 }
 
 //Search for an element on the page, starting from the document root.
-func (s Session) FindElement(using FindElementStrategy, value string) (WebElement, error) {
+func (s *Session) FindElement(using FindElementStrategy, value string) (WebElement, error) {
 	p := params{"using": using, "value": value}
-	_, data, err := s.wd.do(p, "POST", "/session/%s/element", s.Id)
+	_, data, err := s.do(p, "POST", "/session/%s/element", s.Id)
 	if err != nil {
 		return WebElement{}, err
 	}
-	var elem element
-	err = json.Unmarshal(data, &elem)
-	var elem2 map[string]string
-	if len(elem.ELEMENT) == 0 {
-		err = json.Unmarshal(data, &elem2)
-		elemElement := ""
-		for _, val := range elem2 {
-			elemElement = val
-		}
-		return WebElement{&s, elemElement}, err
-	}
-	return WebElement{&s, elem.ELEMENT}, err
+	return s.decodeElement(data)
 }
 
 //Search for multiple elements on the page, starting from the document root.
-func (s Session) FindElements(using FindElementStrategy, value string) ([]WebElement, error) {
+func (s *Session) FindElements(using FindElementStrategy, value string) ([]WebElement, error) {
 	p := params{"using": using, "value": value}
-	_, data, err := s.wd.do(p, "POST", "/session/%s/elements", s.Id)
+	_, data, err := s.do(p, "POST", "/session/%s/elements", s.Id)
 	if err != nil {
 		return nil, err
 	}
-	var v []element
-	err = json.Unmarshal(data, &v)
-	if err != nil {
-		return nil, err
-	}
-	elements := make([]WebElement, len(v))
-	for i, elem := range v {
-		elements[i] = WebElement{&s, elem.ELEMENT}
-	}
+	var elements []WebElement
+	err = s.decodeResult(data, &elements)
 	return elements, err
 }
 
 //Get the element on the page that currently has focus.
-func (s Session) GetActiveElement() (WebElement, error) {
-	_, data, err := s.wd.do(nil, "POST", "/session/%s/element/active", s.Id)
+func (s *Session) GetActiveElement() (WebElement, error) {
+	_, data, err := s.do(nil, "POST", "/session/%s/element/active", s.Id)
 	if err != nil {
 		return WebElement{}, err
 	}
-	var elem element
-	err = json.Unmarshal(data, &elem)
-	return WebElement{&s, elem.ELEMENT}, err
+	return s.decodeElement(data)
+}
+
+//decode the element reference returned by a find command.
+func (s *Session) decodeElement(data []byte) (WebElement, error) {
+	var elem WebElement
+	err := s.decodeResult(data, &elem)
+	return elem, err
 }
 
 //Describe the identified element. This command is reserved for future use; its return type is currently undefined.
@@ -640,49 +815,40 @@ func (s Session) GetActiveElement() (WebElement, error) {
 //Search for an element on the page, starting from the identified element.
 func (e WebElement) FindElement(using FindElementStrategy, value string) (WebElement, error) {
 	p := params{"using": using, "value": value}
-	_, data, err := e.s.wd.do(p, "POST", "/session/%s/element/%s/element", e.s.Id, e.id)
+	_, data, err := e.s.do(p, "POST", "/session/%s/element/%s/element", e.s.Id, e.id)
 	if err != nil {
 		return WebElement{}, err
 	}
-	var elem element
-	err = json.Unmarshal(data, &elem)
-	return WebElement{e.s, elem.ELEMENT}, err
+	return e.s.decodeElement(data)
 }
 
 //Search for multiple elements on the page, starting from the identified element.
 func (e WebElement) FindElements(using FindElementStrategy, value string) ([]WebElement, error) {
 	p := params{"using": using, "value": value}
-	_, data, err := e.s.wd.do(p, "POST", "/session/%s/element/%s/elements", e.s.Id, e.id)
+	_, data, err := e.s.do(p, "POST", "/session/%s/element/%s/elements", e.s.Id, e.id)
 	if err != nil {
 		return nil, err
 	}
-	var v []element
-	err = json.Unmarshal(data, &v)
-	if err != nil {
-		return nil, err
-	}
-	elements := make([]WebElement, len(v))
-	for i, z := range v {
-		elements[i] = WebElement{e.s, z.ELEMENT}
-	}
+	var elements []WebElement
+	err = e.s.decodeResult(data, &elements)
 	return elements, err
 }
 
 //Click on an element.
 func (e WebElement) Click() error {
-	_, _, err := e.s.wd.do(nil, "POST", "/session/%s/element/%s/click", e.s.Id, e.id)
+	_, _, err := e.s.do(nil, "POST", "/session/%s/element/%s/click", e.s.Id, e.id)
 	return err
 }
 
 //Submit a FORM element.
 func (e WebElement) Submit() error {
-	_, _, err := e.s.wd.do(nil, "POST", "/session/%s/element/%s/submit", e.s.Id, e.id)
+	_, _, err := e.s.do(nil, "POST", "/session/%s/element/%s/submit", e.s.Id, e.id)
 	return err
 }
 
 //Returns the visible text for the element.
 func (e WebElement) Text() (string, error) {
-	_, data, err := e.s.wd.do(nil, "GET", "/session/%s/element/%s/text", e.s.Id, e.id)
+	_, data, err := e.s.do(nil, "GET", "/session/%s/element/%s/text", e.s.Id, e.id)
 	if err != nil {
 		return "", err
 	}
@@ -691,54 +857,43 @@ func (e WebElement) Text() (string, error) {
 	err = json.Unmarshal(data, &text)
 	if err != nil {
 		err = json.Unmarshal(data, &text2)
-		return text2["message"].(string), err
+		text, _ = text2["message"].(string)
+		return text, err
 	}
 	return text, err
 }
 
 //Send a sequence of key strokes to an element.
 func (e WebElement) SendKeys(sequence string) error {
-	capabilities := map[string]interface{}{}
-	if val, ok := e.s.Capabilities["capabilities"]; ok {
-		capabilities = val.(map[string]interface{})
-	}
-	var browserName string
-	if val, ok := capabilities["browserName"]; ok {
-		browserName = val.(string)
-	}
-	var browserVersion float64
-	if val, ok := capabilities["browserVersion"]; ok {
-		browserVersion, _ = strconv.ParseFloat(val.(string), 64)
-	}
-	if browserName == "firefox" || (browserName == "Safari" && browserVersion >= 12.0) {
+	if e.s.Supports(FeatureSendKeysText) {
 		p := params{"text": sequence}
-		_, _, err := e.s.wd.do(p, "POST", "/session/%s/element/%s/value", e.s.Id, e.id)
+		_, _, err := e.s.do(p, "POST", "/session/%s/element/%s/value", e.s.Id, e.id)
 		return err
 	} else {
-		keys := make([]string, len(sequence))
-		for i, k := range sequence {
-			keys[i] = string(k)
+		keys := []string{}
+		for _, k := range sequence {
+			keys = append(keys, string(k))
 		}
 		p := params{"value": keys}
-		_, _, err := e.s.wd.do(p, "POST", "/session/%s/element/%s/value", e.s.Id, e.id)
+		_, _, err := e.s.do(p, "POST", "/session/%s/element/%s/value", e.s.Id, e.id)
 		return err
 	}
 }
 
 //Send a sequence of key strokes to the active element.
-func (s Session) SendKeysOnActiveElement(sequence string) error {
-	keys := make([]string, len(sequence))
-	for i, k := range sequence {
-		keys[i] = string(k)
+func (s *Session) SendKeysOnActiveElement(sequence string) error {
+	keys := []string{}
+	for _, k := range sequence {
+		keys = append(keys, string(k))
 	}
 	p := params{"value": keys}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/keys", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/keys", s.Id)
 	return err
 }
 
 //Query for an element's tag name.
 func (e WebElement) Name() (string, error) {
-	_, data, err := e.s.wd.do(nil, "GET", "/session/%s/element/%s/name", e.s.Id, e.id)
+	_, data, err := e.s.do(nil, "GET", "/session/%s/element/%s/name", e.s.Id, e.id)
 	if err != nil {
 		return "", err
 	}
@@ -749,13 +904,13 @@ func (e WebElement) Name() (string, error) {
 
 //Clear a TEXTAREA or text INPUT element's value.
 func (e WebElement) Clear() error {
-	_, _, err := e.s.wd.do(nil, "POST", "/session/%s/element/%s/clear", e.s.Id, e.id)
+	_, _, err := e.s.do(nil, "POST", "/session/%s/element/%s/clear", e.s.Id, e.id)
 	return err
 }
 
 //Determine if an OPTION element, or an INPUT element of type checkbox or radiobutton is currently selected.
 func (e WebElement) IsSelected() (bool, error) {
-	_, data, err := e.s.wd.do(nil, "GET", "/session/%s/element/%s/value", e.s.Id, e.id)
+	_, data, err := e.s.do(nil, "GET", "/session/%s/element/%s/selected", e.s.Id, e.id)
 	if err != nil {
 		return false, err
 	}
@@ -766,7 +921,7 @@ func (e WebElement) IsSelected() (bool, error) {
 
 //Determine if an element is currently enabled.
 func (e WebElement) IsEnabled() (bool, error) {
-	_, data, err := e.s.wd.do(nil, "GET", "/session/%s/element/%s/enabled", e.s.Id, e.id)
+	_, data, err := e.s.do(nil, "GET", "/session/%s/element/%s/enabled", e.s.Id, e.id)
 	if err != nil {
 		return false, err
 	}
@@ -777,7 +932,7 @@ func (e WebElement) IsEnabled() (bool, error) {
 
 //Get the value of an element's attribute.
 func (e WebElement) GetAttribute(name string) (string, error) {
-	_, data, err := e.s.wd.do(nil, "GET", "/session/%s/element/%s/attribute/%s", e.s.Id, e.id, name)
+	_, data, err := e.s.do(nil, "GET", "/session/%s/element/%s/attribute/%s", e.s.Id, e.id, name)
 	if err != nil {
 		return "", err
 	}
@@ -789,7 +944,7 @@ func (e WebElement) GetAttribute(name string) (string, error) {
 
 //Test if two element IDs refer to the same DOM element.
 func (e WebElement) Equal(element WebElement) (bool, error) {
-	_, data, err := e.s.wd.do(nil, "GET", "/session/%s/element/%s/equal/%s", e.s.Id, e.id, element.id)
+	_, data, err := e.s.do(nil, "GET", "/session/%s/element/%s/equal/%s", e.s.Id, e.id, element.id)
 	if err != nil {
 		return false, err
 	}
@@ -800,7 +955,7 @@ func (e WebElement) Equal(element WebElement) (bool, error) {
 
 //Determine if an element is currently displayed.
 func (e WebElement) IsDisplayed() (bool, error) {
-	_, data, err := e.s.wd.do(nil, "GET", "/session/%s/element/%s/displayed", e.s.Id, e.id)
+	_, data, err := e.s.do(nil, "GET", "/session/%s/element/%s/displayed", e.s.Id, e.id)
 	if err != nil {
 		return false, err
 	}
@@ -812,7 +967,7 @@ func (e WebElement) IsDisplayed() (bool, error) {
 //Determine an element's location on the page.
 //The point (0, 0) refers to the upper-left corner of the page. The element's coordinates are returned as a JSON object with x and y properties.
 func (e WebElement) GetLocation() (Position, error) {
-	_, data, err := e.s.wd.do(nil, "GET", "/session/%s/element/%s/location", e.s.Id, e.id)
+	_, data, err := e.s.do(nil, "GET", "/session/%s/element/%s/location", e.s.Id, e.id)
 	if err != nil {
 		return Position{}, err
 	}
@@ -825,7 +980,7 @@ func (e WebElement) GetLocation() (Position, error) {
 //
 //Note: This is considered an internal command and should only be used to determine an element's location for correctly generating native events.
 func (e WebElement) GetLocationInView() (Position, error) {
-	_, data, err := e.s.wd.do(nil, "GET", "/session/%s/element/%s/location_in_view", e.s.Id, e.id)
+	_, data, err := e.s.do(nil, "GET", "/session/%s/element/%s/location_in_view", e.s.Id, e.id)
 	if err != nil {
 		return Position{}, err
 	}
@@ -836,7 +991,7 @@ func (e WebElement) GetLocationInView() (Position, error) {
 
 //Determine an element's size in pixels.
 func (e WebElement) Size() (Size, error) {
-	_, data, err := e.s.wd.do(nil, "GET", "/session/%s/element/%s/size", e.s.Id, e.id)
+	_, data, err := e.s.do(nil, "GET", "/session/%s/element/%s/size", e.s.Id, e.id)
 	if err != nil {
 		return Size{}, err
 	}
@@ -847,7 +1002,7 @@ func (e WebElement) Size() (Size, error) {
 
 //Query the value of an element's computed CSS property.
 func (e WebElement) GetCssProperty(name string) (string, error) {
-	_, data, err := e.s.wd.do(nil, "GET", "/session/%s/element/%s/css/%s", e.s.Id, e.id, name)
+	_, data, err := e.s.do(nil, "GET", "/session/%s/element/%s/css/%s", e.s.Id, e.id, name)
 	if err != nil {
 		return "", err
 	}
@@ -865,8 +1020,8 @@ const (
 )
 
 //Get the current browser orientation.
-func (s Session) GetOrientation() (ScreenOrientation, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/orientation", s.Id)
+func (s *Session) GetOrientation() (ScreenOrientation, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/orientation", s.Id)
 	if err != nil {
 		return "", err
 	}
@@ -876,15 +1031,15 @@ func (s Session) GetOrientation() (ScreenOrientation, error) {
 }
 
 //Set the browser orientation.
-func (s Session) SetOrientation(orientation ScreenOrientation) error {
+func (s *Session) SetOrientation(orientation ScreenOrientation) error {
 	p := params{"orientation": orientation}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/orientation", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/orientation", s.Id)
 	return err
 }
 
 //Gets the text of the currently displayed JavaScript alert(), confirm(), or prompt() dialog.
-func (s Session) GetAlertText() (string, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/alert_text", s.Id)
+func (s *Session) GetAlertText() (string, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/alert_text", s.Id)
 	if err != nil {
 		return "", err
 	}
@@ -894,29 +1049,29 @@ func (s Session) GetAlertText() (string, error) {
 }
 
 //Sends keystrokes to a JavaScript prompt() dialog.
-func (s Session) SetAlertText(text string) error {
+func (s *Session) SetAlertText(text string) error {
 	p := params{"text": text}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/alert_text", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/alert_text", s.Id)
 	return err
 }
 
 //Accepts the currently displayed alert dialog.
-func (s Session) AcceptAlert() error {
-	_, _, err := s.wd.do(nil, "POST", "/session/%s/accept_alert", s.Id)
+func (s *Session) AcceptAlert() error {
+	_, _, err := s.do(nil, "POST", "/session/%s/accept_alert", s.Id)
 	return err
 }
 
 //Dismisses the currently displayed alert dialog.
-func (s Session) DismissAlert() error {
-	_, _, err := s.wd.do(nil, "POST", "/session/%s/dismiss_alert", s.Id)
+func (s *Session) DismissAlert() error {
+	_, _, err := s.do(nil, "POST", "/session/%s/dismiss_alert", s.Id)
 	return err
 }
 
 //Move the mouse by an offset of the specificed element.
 //If no element is specified, the move is relative to the current mouse cursor. If an element is provided but no offset, the mouse will be moved to the center of the element. If the element is not visible, it will be scrolled into view.
-func (s Session) MoveTo(element WebElement, xoffset, yoffset int) error {
+func (s *Session) MoveTo(element WebElement, xoffset, yoffset int) error {
 	p := params{"element": element.id, "xoffset": xoffset, "yoffset": yoffset}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/moveto", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/moveto", s.Id)
 	return err
 }
 
@@ -931,100 +1086,100 @@ const (
 //Click any mouse button (at the coordinates set by the last moveto command).
 //
 //Note that calling this command after calling buttondown and before calling button up (or any out-of-order interactions sequence) will yield undefined behaviour).
-func (s Session) Click(button MouseButton) error {
+func (s *Session) Click(button MouseButton) error {
 	p := params{"button": button}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/click", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/click", s.Id)
 	return err
 }
 
 //Click and hold the left mouse button (at the coordinates set by the last moveto command).
-func (s Session) ButtonDown(button MouseButton) error {
+func (s *Session) ButtonDown(button MouseButton) error {
 	p := params{"button": button}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/buttondown", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/buttondown", s.Id)
 	return err
 }
 
 //Releases the mouse button previously held (where the mouse is currently at).
-func (s Session) ButtonUp(button MouseButton) error {
+func (s *Session) ButtonUp(button MouseButton) error {
 	p := params{"button": button}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/buttonup", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/buttonup", s.Id)
 	return err
 }
 
 //Double-clicks at the current mouse coordinates (set by moveto).
-func (s Session) DoubleClick() error {
-	_, _, err := s.wd.do(nil, "POST", "/session/%s/doubleclick", s.Id)
+func (s *Session) DoubleClick() error {
+	_, _, err := s.do(nil, "POST", "/session/%s/doubleclick", s.Id)
 	return err
 }
 
 //Single tap on the touch enabled device.
-func (s Session) TouchClick(element WebElement) error {
+func (s *Session) TouchClick(element WebElement) error {
 	p := params{"element": element.id}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/touch/click", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/touch/click", s.Id)
 	return err
 }
 
 //Finger down on the screen.
-func (s Session) TouchDown(x, y int) error {
+func (s *Session) TouchDown(x, y int) error {
 	p := params{"x": x, "y": y}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/touch/down", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/touch/down", s.Id)
 	return err
 }
 
 //Finger up on the screen.
-func (s Session) TouchUp(x, y int) error {
+func (s *Session) TouchUp(x, y int) error {
 	p := params{"x": x, "y": y}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/touch/up", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/touch/up", s.Id)
 	return err
 }
 
 //Finger move on the screen.
-func (s Session) TouchMove(x, y int) error {
+func (s *Session) TouchMove(x, y int) error {
 	p := params{"x": x, "y": y}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/touch/move", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/touch/move", s.Id)
 	return err
 }
 
 //Scroll on the touch screen using finger based motion events.
-func (s Session) TouchScroll(element WebElement, xoffset, yoffset int) error {
+func (s *Session) TouchScroll(element WebElement, xoffset, yoffset int) error {
 	p := params{"element": element.id, "xoffset": xoffset, "yoffset": yoffset}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/touch/scroll", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/touch/scroll", s.Id)
 	return err
 }
 
 //Double tap on the touch screen using finger motion events.
-func (s Session) TouchDoubleClick(element WebElement) error {
+func (s *Session) TouchDoubleClick(element WebElement) error {
 	p := params{"element": element.id}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/touch/doubleclick", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/touch/doubleclick", s.Id)
 	return err
 }
 
 //Long press on the touch screen using finger motion events.
-func (s Session) TouchLongClick(element WebElement) error {
+func (s *Session) TouchLongClick(element WebElement) error {
 	p := params{"element": element.id}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/touch/longclick", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/touch/longclick", s.Id)
 	return err
 }
 
 //Flick on the touch screen using finger motion events.
 //This flickcommand starts at a particulat screen location.
-func (s Session) TouchFlick(element WebElement, xoffset, yoffset, speed int) error {
+func (s *Session) TouchFlick(element WebElement, xoffset, yoffset, speed int) error {
 	p := params{"element": element.id, "xoffset": xoffset, "yoffset": yoffset, "speed": speed}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/touch/flick", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/touch/flick", s.Id)
 	return err
 }
 
 //Flick on the touch screen using finger motion events.
 //Use this flick command if you don't care where the flick starts on the screen.
-func (s Session) TouchFlickAnywhere(xspeed, yspeed int) error {
+func (s *Session) TouchFlickAnywhere(xspeed, yspeed int) error {
 	p := params{"xspeed": xspeed, "yspeed": yspeed}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/touch/flick", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/touch/flick", s.Id)
 	return err
 }
 
 //Get the current geo location.
-func (s Session) GetGeoLocation() (GeoLocation, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/location", s.Id)
+func (s *Session) GetGeoLocation() (GeoLocation, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/location", s.Id)
 	if err != nil {
 		return GeoLocation{}, err
 	}
@@ -1034,15 +1189,15 @@ func (s Session) GetGeoLocation() (GeoLocation, error) {
 }
 
 //Set the current geo location.
-func (s Session) SetGeoLocation(location GeoLocation) error {
+func (s *Session) SetGeoLocation(location GeoLocation) error {
 	p := params{"location": location}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/location", s.Id)
+	_, _, err := s.do(p, "POST", "/session/%s/location", s.Id)
 	return err
 }
 
 //helper functions, storageType can be "local_storage" or "session_storage"
-func (s Session) storageGetKeys(storageType string) ([]string, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/%s", s.Id, storageType)
+func (s *Session) storageGetKeys(storageType string) ([]string, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/%s", s.Id, storageType)
 	if err != nil {
 		return nil, err
 	}
@@ -1051,20 +1206,20 @@ func (s Session) storageGetKeys(storageType string) ([]string, error) {
 	return keys, err
 }
 
-func (s Session) storageSetKey(storageType, key, value string) error {
+func (s *Session) storageSetKey(storageType, key, value string) error {
 	p := params{"key": key, "value": value}
-	_, _, err := s.wd.do(p, "POST", "/session/%s/%s", s.Id, storageType)
+	_, _, err := s.do(p, "POST", "/session/%s/%s", s.Id, storageType)
 	return err
 }
 
-func (s Session) storageClear(storageType string) error {
-	_, _, err := s.wd.do(nil, "DELETE", "/session/%s/%s", s.Id, storageType)
+func (s *Session) storageClear(storageType string) error {
+	_, _, err := s.do(nil, "DELETE", "/session/%s/%s", s.Id, storageType)
 	return err
 }
 
 //TODO protocol specification doesn't specify what is returned, I guess a string
-func (s Session) storageGetKey(storageType, key string) (string, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/%s/key/%s", s.Id, storageType, key)
+func (s *Session) storageGetKey(storageType, key string) (string, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/%s/key/%s", s.Id, storageType, key)
 	if err != nil {
 		return "", err
 	}
@@ -1073,14 +1228,14 @@ func (s Session) storageGetKey(storageType, key string) (string, error) {
 	return value, err
 }
 
-func (s Session) storageRemoveKey(storageType string, key string) error {
-	_, _, err := s.wd.do(nil, "DELETE", "/session/%s/%s/key/%s", s.Id, storageType, key)
+func (s *Session) storageRemoveKey(storageType string, key string) error {
+	_, _, err := s.do(nil, "DELETE", "/session/%s/%s/key/%s", s.Id, storageType, key)
 	return err
 }
 
 //Get the number of items in the storage.
-func (s Session) storageSize(storageType string) (int, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/%s/size", s.Id, storageType)
+func (s *Session) storageSize(storageType string) (int, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/%s/size", s.Id, storageType)
 	if err != nil {
 		return -1, err
 	}
@@ -1090,69 +1245,69 @@ func (s Session) storageSize(storageType string) (int, error) {
 }
 
 //Get all keys of the storage.
-func (s Session) LocalStorageGetKeys() ([]string, error) {
+func (s *Session) LocalStorageGetKeys() ([]string, error) {
 	return s.storageGetKeys("local_storage")
 }
 
 //Set the storage item for the given key.
-func (s Session) LocalStorageSetKey(key, value string) error {
+func (s *Session) LocalStorageSetKey(key, value string) error {
 	return s.storageSetKey("local_storage", key, value)
 }
 
 //Clear the storage.
-func (s Session) LocalStorageClear() error {
+func (s *Session) LocalStorageClear() error {
 	return s.storageClear("local_storage")
 }
 
 //Get the storage item for the given key.
-func (s Session) LocalStorageGetKey(key string) (string, error) {
+func (s *Session) LocalStorageGetKey(key string) (string, error) {
 	return s.storageGetKey("local_storage", key)
 }
 
 //Remove the storage item for the given key.
-func (s Session) LocalStorageRemoveKey(key string) error {
+func (s *Session) LocalStorageRemoveKey(key string) error {
 	return s.storageRemoveKey("local_storage", key)
 }
 
 //Get the number of items in the storage.
-func (s Session) LocalStorageSize() (int, error) {
+func (s *Session) LocalStorageSize() (int, error) {
 	return s.storageSize("local_storage")
 }
 
 //Get all keys of the storage.
-func (s Session) SessionStorageGetKeys() ([]string, error) {
+func (s *Session) SessionStorageGetKeys() ([]string, error) {
 	return s.storageGetKeys("session_storage")
 }
 
 //Set the storage item for the given key.
-func (s Session) SessionStorageSetKey(key, value string) error {
+func (s *Session) SessionStorageSetKey(key, value string) error {
 	return s.storageSetKey("session_storage", key, value)
 }
 
 //Clear the storage.
-func (s Session) SessionStorageClear() error {
+func (s *Session) SessionStorageClear() error {
 	return s.storageClear("session_storage")
 }
 
 //Get the storage item for the given key.
-func (s Session) SessionStorageGetKey(key string) (string, error) {
+func (s *Session) SessionStorageGetKey(key string) (string, error) {
 	return s.storageGetKey("session_storage", key)
 }
 
 //Remove the storage item for the given key.
-func (s Session) SessionStorageRemoveKey(key string) error {
+func (s *Session) SessionStorageRemoveKey(key string) error {
 	return s.storageRemoveKey("session_storage", key)
 }
 
 //Get the number of items in the storage.
-func (s Session) SessionStorageSize() (int, error) {
+func (s *Session) SessionStorageSize() (int, error) {
 	return s.storageSize("session_storage")
 }
 
 //Get the log for a given log type.
-func (s Session) Log(logType string) ([]LogEntry, error) {
+func (s *Session) Log(logType string) ([]LogEntry, error) {
 	p := params{"type": logType}
-	_, data, err := s.wd.do(p, "POST", "/session/%s/log", s.Id)
+	_, data, err := s.do(p, "POST", "/session/%s/log", s.Id)
 	if err != nil {
 		return nil, err
 	}
@@ -1162,8 +1317,8 @@ func (s Session) Log(logType string) ([]LogEntry, error) {
 }
 
 //Get available log types.
-func (s Session) LogTypes() ([]string, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/log/types", s.Id)
+func (s *Session) LogTypes() ([]string, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/log/types", s.Id)
 	if err != nil {
 		return nil, err
 	}
@@ -1173,8 +1328,8 @@ func (s Session) LogTypes() ([]string, error) {
 }
 
 //Get the status of the html5 application cache.
-func (s Session) GetHTML5CacheStatus() (HTML5CacheStatus, error) {
-	_, data, err := s.wd.do(nil, "GET", "/session/%s/application_cache/status", s.Id)
+func (s *Session) GetHTML5CacheStatus() (HTML5CacheStatus, error) {
+	_, data, err := s.do(nil, "GET", "/session/%s/application_cache/status", s.Id)
 	if err != nil {
 		return 0, err
 	}
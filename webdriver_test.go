@@ -6,6 +6,7 @@ package webdriver
 
 import (
 	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"image/png"
@@ -49,6 +50,20 @@ var pages = [][]string{
   <h3>This is a heading</h3>
   <p>This is a <a href="http://golang.com">longwordlinktogolang</a> to a page served by a go server.</p>
 </div>
+</body></html>`},
+
+	{"select", `<!DOCTYPE html><html><body>
+<select id="single">
+  <option value="a">Alpha</option>
+  <option value="b" selected>Beta</option>
+  <option value="c" disabled>Gamma</option>
+</select>
+<select id="multi" multiple>
+  <option value="1">One</option>
+  <option value="2">Two</option>
+  <option value="3">Three</option>
+  <option value="4" disabled>Four</option>
+</select>
 </body></html>`},
 }
 
@@ -116,7 +131,7 @@ func startChromedriver(t *testing.T) WebDriver {
 }
 
 func startFirefoxdriver(t *testing.T) WebDriver {
-	firefoxdriver := NewFirefoxDriver("firefox", *wdpath)
+	firefoxdriver := NewFirefoxDriver(*wdpath)
 	if *wdlog != "" {
 		dir := filepath.Dir(*wdlog)
 		logfile := filepath.Join(dir, "firefoxdriver.log")
@@ -128,7 +143,7 @@ func startFirefoxdriver(t *testing.T) WebDriver {
 		if err != nil {
 			t.Fatal(err)
 		}
-		firefoxdriver.SetLogPath(logfile)
+		firefoxdriver.LogPath = logfile
 	}
 	err := firefoxdriver.Start()
 	if err != nil {
@@ -320,6 +335,58 @@ func TestScreenshot(t *testing.T) {
 	}
 }
 
+func TestWebElementJSON(t *testing.T) {
+	legacy := &Session{Capabilities: Capabilities{}}
+	w3c := &Session{Capabilities: Capabilities{"capabilities": map[string]interface{}{}}}
+	for _, c := range []struct {
+		s    *Session
+		want string
+	}{
+		{legacy, `{"ELEMENT":"e1"}`},
+		{w3c, `{"` + webElementKey + `":"e1"}`},
+	} {
+		b, err := json.Marshal([]interface{}{WebElement{c.s, "e1"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "["+c.want+"]" {
+			t.Errorf("got %s, want [%s]", b, c.want)
+		}
+	}
+
+	data := []byte(`{"a":{"` + webElementKey + `":"e1"},"b":[1,{"ELEMENT":"e2"}],"c":"x"}`)
+	var v interface{}
+	if err := w3c.decodeResult(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	m := v.(map[string]interface{})
+	if e, ok := m["a"].(WebElement); !ok || e.id != "e1" || e.s != w3c {
+		t.Errorf("nested object not decoded as element: %#v", m["a"])
+	}
+	if e, ok := m["b"].([]interface{})[1].(WebElement); !ok || e.id != "e2" || e.s != w3c {
+		t.Errorf("nested array not decoded as element: %#v", m["b"])
+	}
+
+	var typed struct {
+		A WebElement
+		B []interface{}
+		M map[string]WebElement
+	}
+	data = []byte(`{"A":{"ELEMENT":"e1"},"B":[{"ELEMENT":"e2"}],"M":{"k":{"ELEMENT":"e3"}}}`)
+	if err := legacy.decodeResult(data, &typed); err != nil {
+		t.Fatal(err)
+	}
+	if typed.A.id != "e1" || typed.A.s != legacy {
+		t.Errorf("struct field not bound: %#v", typed.A)
+	}
+	if e, ok := typed.B[0].(WebElement); !ok || e.id != "e2" {
+		t.Errorf("slice value not decoded as element: %#v", typed.B[0])
+	}
+	if e := typed.M["k"]; e.id != "e3" || e.s != legacy {
+		t.Errorf("map value not bound: %#v", e)
+	}
+}
+
 func xTestIME(t *testing.T) {
 	checkSession(t)
 	// TODO IMEAvailableEngines
@@ -426,6 +493,82 @@ func TestElements(t *testing.T) {
 	// TODO element.Size
 }
 
+func TestSelect(t *testing.T) {
+	checkSession(t)
+	err := session.Url(getUrl("select"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	we, err := session.FindElement(ID, "single")
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, err := NewSelect(we)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if single.IsMultiple() {
+		t.Fatal("single select reported as multiple")
+	}
+	if err = single.SelectByVisibleText("Alpha"); err != nil {
+		t.Fatal(err)
+	}
+	opt, err := single.FirstSelectedOption()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := opt.GetAttribute("value"); v != "a" {
+		t.Fatal("wrong option selected: " + v)
+	}
+	if err = single.SelectByValue("c"); err == nil {
+		t.Fatal("selected a disabled option")
+	}
+	if err = single.DeselectAll(); err == nil {
+		t.Fatal("deselected a single select")
+	}
+
+	we, err = session.FindElement(ID, "multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	multi, err := NewSelect(we)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !multi.IsMultiple() {
+		t.Fatal("multi select not reported as multiple")
+	}
+	if err = multi.SelectByIndex(0); err != nil {
+		t.Fatal(err)
+	}
+	if err = multi.SelectByValue("3"); err != nil {
+		t.Fatal(err)
+	}
+	selected, err := multi.SelectedOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(selected) != 2 {
+		t.Fatalf("%d options selected instead of 2", len(selected))
+	}
+	if err = multi.SelectByValue("4"); err == nil {
+		t.Fatal("selected a disabled option")
+	}
+	if err = multi.DeselectByValue("4"); err != nil {
+		t.Fatal(err)
+	}
+	if err = multi.DeselectByIndex(0); err != nil {
+		t.Fatal(err)
+	}
+	if err = multi.DeselectAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = NewSelect(opt); err == nil {
+		t.Fatal("option accepted as a select")
+	}
+}
+
 func xTestCssProperty(t *testing.T) {
 	checkSession(t)
 	// TODO GetCssProperty
@@ -0,0 +1,439 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal WebSocket (RFC 6455) client, enough to speak the JSON based
+// protocols of the browsers: text messages, ping/pong and close.
+
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xa
+)
+
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsConn is a WebSocket connection. Reads must come from a single goroutine,
+// writes may come from any.
+type wsConn struct {
+	conn   net.Conn
+	r      *bufio.Reader
+	wmu    sync.Mutex
+	client bool
+}
+
+// open a WebSocket connection to rawurl (ws:// or wss://).
+func dialWebSocket(rawurl string) (*wsConn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	switch u.Scheme {
+	case "ws", "http":
+		if u.Port() == "" {
+			host += ":80"
+		}
+		conn, err = dialer.Dial("tcp", host)
+	case "wss", "https":
+		if u.Port() == "" {
+			host += ":443"
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, errors.New("websocket: unsupported scheme " + u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	request := "GET " + u.RequestURI() + " HTTP/1.1\r\n" +
+		"Host: " + u.Host + "\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := io.WriteString(conn, request); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	r := bufio.NewReader(conn)
+	response, err := http.ReadResponse(r, nil)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if response.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, fmt.Errorf("websocket: handshake failed: %s", response.Status)
+	}
+	if response.Header.Get("Sec-WebSocket-Accept") != wsAccept(key) {
+		conn.Close()
+		return nil, errors.New("websocket: handshake failed: invalid Sec-WebSocket-Accept")
+	}
+	return &wsConn{conn: conn, r: r, client: true}, nil
+}
+
+// the Sec-WebSocket-Accept value expected for key.
+func wsAccept(key string) string {
+	h := sha1.Sum([]byte(key + wsGUID))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// read the next text or binary message, answering pings on the way.
+func (c *wsConn) ReadMessage() ([]byte, error) {
+	var message []byte
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			c.writeFrame(wsClose, payload)
+			return nil, io.EOF
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+// send a text message.
+func (c *wsConn) WriteMessage(data []byte) error {
+	return c.writeFrame(wsText, data)
+}
+
+// send a close frame and close the connection.
+func (c *wsConn) Close() error {
+	c.writeFrame(wsClose, []byte{0x03, 0xe8}) // 1000: normal closure
+	return c.conn.Close()
+}
+
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.r, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.r, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.r, mask[:]); err != nil {
+			return
+		}
+	}
+	if length > 1<<30 {
+		err = errors.New("websocket: frame too large")
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.r, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// write a single, final frame. Frames sent by a client are masked.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	frame := []byte{0x80 | opcode, 0}
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame[1] = maskBit | byte(n)
+	case n <= 0xffff:
+		frame[1] = maskBit | 126
+		frame = append(frame, byte(n>>8), byte(n))
+	default:
+		frame[1] = maskBit | 127
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(n))
+		frame = append(frame, ext[:]...)
+	}
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		frame = append(frame, mask[:]...)
+		masked := make([]byte, len(payload))
+		for i := range payload {
+			masked[i] = payload[i] ^ mask[i%4]
+		}
+		payload = masked
+	}
+	_, err := c.conn.Write(append(frame, payload...))
+	return err
+}
+
+// rpcMessage is a command, a response or an event of the JSON protocols
+// spoken over WebSocket: Chrome DevTools Protocol and WebDriver BiDi.
+type rpcMessage struct {
+	ID     int64           `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  json.RawMessage `json:"error,omitempty"`
+	// BiDi only
+	Type    string `json:"type,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// rpcConn correlates commands with their responses by id and dispatches
+// events to handlers. Handlers run one at a time on a dedicated goroutine,
+// so they may send commands on the same connection.
+type rpcConn struct {
+	ws *wsConn
+	// build the error of a failed response, nil if the response succeeded
+	responseError func(m *rpcMessage) error
+
+	mu       sync.Mutex
+	nextID   int64
+	pending  map[int64]chan *rpcMessage
+	handlers []rpcHandler
+	nextSub  int
+	err      error
+
+	queue  []*rpcMessage
+	queued *sync.Cond
+}
+
+type rpcHandler struct {
+	id     int
+	method string
+	fn     func(params json.RawMessage)
+}
+
+func newRPCConn(ws *wsConn, responseError func(m *rpcMessage) error) *rpcConn {
+	c := &rpcConn{
+		ws:            ws,
+		responseError: responseError,
+		pending:       map[int64]chan *rpcMessage{},
+	}
+	c.queued = sync.NewCond(&c.mu)
+	go c.read()
+	go c.dispatch()
+	return c
+}
+
+// the time a command sent with call waits for its response.
+var rpcTimeout = 60 * time.Second
+
+// send a command and wait for its response, up to rpcTimeout. result may be nil.
+func (c *rpcConn) call(method string, params, result interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+	return c.callContext(ctx, method, params, result)
+}
+
+// send a command and wait for its response until ctx is done. result may be nil.
+func (c *rpcConn) callContext(ctx context.Context, method string, params, result interface{}) error {
+	if params == nil {
+		params = struct{}{}
+	}
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	ch := make(chan *rpcMessage, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = ch
+	c.mu.Unlock()
+	forget := func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}
+
+	data, err := json.Marshal(rpcMessage{ID: id, Method: method, Params: rawParams})
+	if err != nil {
+		forget()
+		return err
+	}
+	debugprint(">> ws " + string(data))
+	if err := c.ws.WriteMessage(data); err != nil {
+		forget()
+		return err
+	}
+	var m *rpcMessage
+	var ok bool
+	select {
+	case m, ok = <-ch:
+	case <-ctx.Done():
+		//a late response finds no channel and is dropped
+		forget()
+		return fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+	if !ok {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.err
+	}
+	if err := c.responseError(m); err != nil {
+		return err
+	}
+	if result == nil || len(m.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(m.Result, result)
+}
+
+// register fn for the events named method. The returned function removes it.
+func (c *rpcConn) on(method string, fn func(params json.RawMessage)) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextSub++
+	id := c.nextSub
+	c.handlers = append(c.handlers, rpcHandler{id, method, fn})
+	return func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		for i, h := range c.handlers {
+			if h.id == id {
+				c.handlers = append(c.handlers[:i:i], c.handlers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func (c *rpcConn) close() error {
+	return c.ws.Close()
+}
+
+// read messages until the connection fails, routing responses and queueing events.
+func (c *rpcConn) read() {
+	var err error
+	for {
+		var data []byte
+		if data, err = c.ws.ReadMessage(); err != nil {
+			break
+		}
+		debugprint("<< ws " + string(data))
+		m := &rpcMessage{}
+		if err := json.Unmarshal(data, m); err != nil {
+			continue
+		}
+		c.mu.Lock()
+		if m.ID != 0 && m.Method == "" {
+			ch := c.pending[m.ID]
+			delete(c.pending, m.ID)
+			if ch != nil {
+				ch <- m
+			}
+		} else if m.Method != "" {
+			c.queue = append(c.queue, m)
+			c.queued.Signal()
+		}
+		c.mu.Unlock()
+	}
+	if err == io.EOF || strings.Contains(err.Error(), "use of closed network connection") {
+		err = errors.New("websocket: connection closed")
+	}
+	c.mu.Lock()
+	c.err = err
+	for id, ch := range c.pending {
+		close(ch)
+		delete(c.pending, id)
+	}
+	c.queued.Signal()
+	c.mu.Unlock()
+}
+
+// deliver the queued events to handlers, in order, until the connection is closed.
+func (c *rpcConn) dispatch() {
+	for {
+		c.mu.Lock()
+		for len(c.queue) == 0 && c.err == nil {
+			c.queued.Wait()
+		}
+		if len(c.queue) == 0 {
+			c.mu.Unlock()
+			return
+		}
+		m := c.queue[0]
+		c.queue = c.queue[1:]
+		var fns []func(json.RawMessage)
+		for _, h := range c.handlers {
+			if h.method == m.Method {
+				fns = append(fns, h.fn)
+			}
+		}
+		c.mu.Unlock()
+		for _, fn := range fns {
+			fn(m.Params)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright 2013 Federico Sogaro. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package webdriver
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// serveWebSocket starts a WebSocket server that runs handler for each
+// connection and returns its ws:// URL.
+func serveWebSocket(t *testing.T, handler func(c *wsConn)) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Sec-WebSocket-Key")
+		if key == "" {
+			http.Error(w, "not a websocket handshake", http.StatusBadRequest)
+			return
+		}
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+			"Upgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + wsAccept(key) + "\r\n\r\n")
+		rw.Flush()
+		c := &wsConn{conn: conn, r: bufio.NewReader(rw)}
+		defer conn.Close()
+		handler(c)
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestWebSocketEcho(t *testing.T) {
+	url := serveWebSocket(t, func(c *wsConn) {
+		for {
+			m, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			c.WriteMessage(m)
+		}
+	})
+	c, err := dialWebSocket(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, msg := range []string{"hello", strings.Repeat("x", 300), strings.Repeat("y", 70000)} {
+		if err := c.WriteMessage([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != msg {
+			t.Fatalf("got %d bytes back, sent %d", len(got), len(msg))
+		}
+	}
+}
+
+func TestRPCTimeout(t *testing.T) {
+	//a browser that reads the commands and never answers
+	url := serveWebSocket(t, func(c *wsConn) {
+		for {
+			if _, err := c.ReadMessage(); err != nil {
+				return
+			}
+		}
+	})
+	ws, err := dialWebSocket(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newRPCConn(ws, cdpResponseError)
+	defer c.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := c.callContext(ctx, "Page.stopScreencast", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the error of the context", err)
+	}
+	defer func(timeout time.Duration) { rpcTimeout = timeout }(rpcTimeout)
+	rpcTimeout = 50 * time.Millisecond
+	if err := c.call("Page.enable", nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want a timeout", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.pending) != 0 {
+		t.Errorf("%d commands still pending", len(c.pending))
+	}
+}